@@ -8,23 +8,59 @@ import (
 )
 
 const (
-	LogLevelFlag                     = "log-level"
-	LogFormatFlag                    = "log-format"
-	CloudProviderFlag                = "cloud-provider"
-	InitialDelayFlag                 = "initial-delay"
-	DrainDelayFlag                   = "drain-delay"
-	CloudTerminationDelayFlag        = "cloud-termination-delay"
-	CloudPrepareTerminationDelayFlag = "cloud-prepare-termination-delay"
-	PortFlag                         = "port"
-	NodeInitialThresholdFlag         = "node-initial-threshold"
-	NodeLeaseNamespaceFlag           = "node-lease-namespace"
-	NamespaceFlag                    = "namespace"
-	LeaseLockNameFlag                = "lease-lock-name"
-	LeaseLockNamespaceFlag           = "lease-lock-namespace"
-	LogFormatJson                    = "json"
-	LogFormatText                    = "text"
-	NodeSelectorFlag                 = "node-selector"
-	NotificationsSlackWebhookFlag    = "notifications-slack-webhook"
+	LogLevelFlag                        = "log-level"
+	LogFormatFlag                       = "log-format"
+	CloudProviderFlag                   = "cloud-provider"
+	InitialDelayFlag                    = "initial-delay"
+	DrainDelayFlag                      = "drain-delay"
+	CloudTerminationDelayFlag           = "cloud-termination-delay"
+	CloudPrepareTerminationDelayFlag    = "cloud-prepare-termination-delay"
+	PortFlag                            = "port"
+	NodeInitialThresholdFlag            = "node-initial-threshold"
+	NodeLeaseNamespaceFlag              = "node-lease-namespace"
+	NamespaceFlag                       = "namespace"
+	LeaseLockNameFlag                   = "lease-lock-name"
+	LeaseLockNamespaceFlag              = "lease-lock-namespace"
+	LogFormatJson                       = "json"
+	LogFormatText                       = "text"
+	NodeSelectorFlag                    = "node-selector"
+	NotificationsSlackWebhookFlag       = "notifications-slack-webhook"
+	DryRunFlag                          = "dry-run"
+	MaxDisruptedNodesFlag               = "max-disrupted-nodes"
+	MaxDisruptedNodesPercentageFlag     = "max-disrupted-nodes-percentage"
+	CircuitBreakerThresholdFlag         = "circuit-breaker-threshold"
+	CircuitBreakerWindowFlag            = "circuit-breaker-window"
+	CircuitBreakerCooldownFlag          = "circuit-breaker-cooldown"
+	DrainTimeoutFlag                    = "drain-timeout"
+	DrainGracePeriodFlag                = "drain-grace-period"
+	DrainDisableEvictionAfterFlag       = "drain-disable-eviction-after-attempts"
+	DrainPodSelectorFlag                = "drain-pod-selector"
+	DrainSkipWaitForDeleteFlag          = "drain-skip-wait-for-delete-timeout"
+	ForceDeleteTerminatingPodsAfterFlag = "force-delete-terminating-pods-after"
+	DeleteVolumeAttachmentsFlag         = "delete-volume-attachments"
+	OutOfServiceTaintDelayFlag          = "out-of-service-taint-delay"
+	PoliciesFileFlag                    = "policies-file"
+	PolicyResourcesFlag                 = "policy-resources"
+	RemediationResourcesFlag            = "remediation-resources"
+	HealthChecksFlag                    = "health-checks"
+	HealthChecksModeFlag                = "health-checks-mode"
+	HealthChecksQuorumFlag              = "health-checks-quorum"
+	UnhealthyConditionsFlag             = "unhealthy-conditions"
+	UnhealthyConditionsThresholdFlag    = "unhealthy-conditions-threshold"
+	HTTPCheckPortFlag                   = "http-check-port"
+	HTTPCheckPathFlag                   = "http-check-path"
+	HTTPCheckTimeoutFlag                = "http-check-timeout"
+	HTTPCheckFailureThresholdFlag       = "http-check-failure-threshold"
+	HTTPCheckConcurrencyFlag            = "http-check-concurrency"
+	NodeLeasesFlag                      = "node-leases"
+	NodeLeasesModeFlag                  = "node-leases-mode"
+	WorkersFlag                         = "workers"
+	InformerResyncFlag                  = "informer-resync"
+	RollbackTerminationFlag             = "rollback-termination"
+	MaintenanceWindowsFlag              = "maintenance-windows"
+	TerminationMaxAttemptsFlag          = "termination-max-attempts"
+	TerminationRetryBackoffFlag         = "termination-retry-backoff"
+	TerminationFailedTimeoutFlag        = "termination-failed-timeout"
 )
 
 func SetupFlags(cmd *cobra.Command) error {
@@ -38,7 +74,7 @@ func SetupFlags(cmd *cobra.Command) error {
 	if err != nil {
 		return err
 	}
-	cmd.PersistentFlags().String(CloudProviderFlag, "aws", "Cloud provider name. Default: 'aws'. Possible values: aws,kwok,kind. Can be set using CLOUD_PROVIDER env variable")
+	cmd.PersistentFlags().String(CloudProviderFlag, "aws", "Cloud provider name. Default: 'aws'. Possible values: aws,karpenter,kwok,kind. Can be set using CLOUD_PROVIDER env variable")
 	err = viper.BindPFlag(CloudProviderFlag, cmd.PersistentFlags().Lookup(CloudProviderFlag))
 	if err != nil {
 		return err
@@ -50,7 +86,7 @@ func SetupFlags(cmd *cobra.Command) error {
 	}
 	cmd.PersistentFlags().Int(CloudTerminationDelayFlag, 300, "Terminate unhealthy node after number of seconds after starting termination preparation (env: CLOUD_TERMINATION_DELAY)")
 	err = viper.BindPFlag(CloudTerminationDelayFlag, cmd.PersistentFlags().Lookup(CloudTerminationDelayFlag))
-	cmd.PersistentFlags().Int(CloudPrepareTerminationDelayFlag, 300, "Prepare termination of unhealthy node after number of seconds after starting drain (env: CLOUD_PREPARE_TERMINATION_DELAY)")
+	cmd.PersistentFlags().Int(CloudPrepareTerminationDelayFlag, 300, "Prepare termination of unhealthy node when drain finishes or at latest after number of seconds after starting drain (env: CLOUD_PREPARE_TERMINATION_DELAY)")
 	err = viper.BindPFlag(CloudPrepareTerminationDelayFlag, cmd.PersistentFlags().Lookup(CloudPrepareTerminationDelayFlag))
 	if err != nil {
 		return err
@@ -101,6 +137,186 @@ func SetupFlags(cmd *cobra.Command) error {
 	if err != nil {
 		return err
 	}
+	cmd.PersistentFlags().Bool(DryRunFlag, false, "Only simulate node state transitions - nodes and cloud provider resources are not modified. Default: 'false'. Can be set using DRY_RUN env variable")
+	err = viper.BindPFlag(DryRunFlag, cmd.PersistentFlags().Lookup(DryRunFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(MaxDisruptedNodesFlag, 0, "Maximum number of nodes that can be tainted, drained or terminated at the same time. Default: '0' - no limit. Can be set using MAX_DISRUPTED_NODES env variable")
+	err = viper.BindPFlag(MaxDisruptedNodesFlag, cmd.PersistentFlags().Lookup(MaxDisruptedNodesFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(MaxDisruptedNodesPercentageFlag, 0, "Maximum percentage of watched nodes that can be tainted, drained or terminated at the same time. Default: '0' - no limit. Can be set using MAX_DISRUPTED_NODES_PERCENTAGE env variable")
+	err = viper.BindPFlag(MaxDisruptedNodesPercentageFlag, cmd.PersistentFlags().Lookup(MaxDisruptedNodesPercentageFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(CircuitBreakerThresholdFlag, 0, "Freeze all node remediation when more than this percentage of watched nodes turns stale within circuit breaker window. Default: '0' - circuit breaker disabled. Can be set using CIRCUIT_BREAKER_THRESHOLD env variable")
+	err = viper.BindPFlag(CircuitBreakerThresholdFlag, cmd.PersistentFlags().Lookup(CircuitBreakerThresholdFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(CircuitBreakerWindowFlag, 300, "Sliding window (in seconds) in which stale nodes are counted by circuit breaker. Default: '300'. Can be set using CIRCUIT_BREAKER_WINDOW env variable")
+	err = viper.BindPFlag(CircuitBreakerWindowFlag, cmd.PersistentFlags().Lookup(CircuitBreakerWindowFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(CircuitBreakerCooldownFlag, 1800, "Number of seconds after which open circuit breaker is closed. Default: '1800'. '0' means it can be closed only manually. Can be set using CIRCUIT_BREAKER_COOLDOWN env variable")
+	err = viper.BindPFlag(CircuitBreakerCooldownFlag, cmd.PersistentFlags().Lookup(CircuitBreakerCooldownFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(DrainTimeoutFlag, 300, "Timeout (in seconds) of a single drain attempt. Default: '300'. '0' means no timeout. Can be set using DRAIN_TIMEOUT env variable")
+	err = viper.BindPFlag(DrainTimeoutFlag, cmd.PersistentFlags().Lookup(DrainTimeoutFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(DrainGracePeriodFlag, -1, "Grace period (in seconds) given to pods evicted during drain. Default: '-1' - pod's terminationGracePeriodSeconds is used. Can be set using DRAIN_GRACE_PERIOD env variable")
+	err = viper.BindPFlag(DrainGracePeriodFlag, cmd.PersistentFlags().Lookup(DrainGracePeriodFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(DrainDisableEvictionAfterFlag, 0, "Delete pods instead of evicting them (bypassing PodDisruptionBudgets) after this number of failed drain attempts. Default: '0' - pods are always evicted. Can be set using DRAIN_DISABLE_EVICTION_AFTER_ATTEMPTS env variable")
+	err = viper.BindPFlag(DrainDisableEvictionAfterFlag, cmd.PersistentFlags().Lookup(DrainDisableEvictionAfterFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(DrainPodSelectorFlag, "", "Label selector of pods to evict during drain - pods not matching it are left on the node. Default: '' - all pods. Can be set using DRAIN_POD_SELECTOR env variable")
+	err = viper.BindPFlag(DrainPodSelectorFlag, cmd.PersistentFlags().Lookup(DrainPodSelectorFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(DrainSkipWaitForDeleteFlag, 0, "Don't wait for deletion of pods whose deletion timestamp is older than this number of seconds (i.e. pods on dead kubelets). Default: '0' - always wait. Can be set using DRAIN_SKIP_WAIT_FOR_DELETE_TIMEOUT env variable")
+	err = viper.BindPFlag(DrainSkipWaitForDeleteFlag, cmd.PersistentFlags().Lookup(DrainSkipWaitForDeleteFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(ForceDeleteTerminatingPodsAfterFlag, 0, "After drain force delete pods bound to unhealthy node that are terminating longer than this number of seconds. Default: '0' - disabled. Can be set using FORCE_DELETE_TERMINATING_PODS_AFTER env variable")
+	err = viper.BindPFlag(ForceDeleteTerminatingPodsAfterFlag, cmd.PersistentFlags().Lookup(ForceDeleteTerminatingPodsAfterFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Bool(DeleteVolumeAttachmentsFlag, false, "After drain delete VolumeAttachments of unhealthy node, so volumes can be attached to other nodes. Default: 'false'. Can be set using DELETE_VOLUME_ATTACHMENTS env variable")
+	err = viper.BindPFlag(DeleteVolumeAttachmentsFlag, cmd.PersistentFlags().Lookup(DeleteVolumeAttachmentsFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(OutOfServiceTaintDelayFlag, 0, "Apply node.kubernetes.io/out-of-service taint to drained node when it is unhealthy for this number of seconds. Default: '0' - disabled. Can be set using OUT_OF_SERVICE_TAINT_DELAY env variable")
+	err = viper.BindPFlag(OutOfServiceTaintDelayFlag, cmd.PersistentFlags().Lookup(OutOfServiceTaintDelayFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(PoliciesFileFlag, "", "Path to yaml file with list of per node group policies. Default: '' - no policies. Can be set using POLICIES_FILE env variable")
+	err = viper.BindPFlag(PoliciesFileFlag, cmd.PersistentFlags().Lookup(PoliciesFileFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Bool(PolicyResourcesFlag, false, "Watch NodeRemediationPolicy resources and apply them as per node group policies. Default: 'false'. Can be set using POLICY_RESOURCES env variable")
+	err = viper.BindPFlag(PolicyResourcesFlag, cmd.PersistentFlags().Lookup(PolicyResourcesFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Bool(RemediationResourcesFlag, false, "Record remediation of each unhealthy node in NodeRemediation resource. Default: 'false'. Can be set using REMEDIATION_RESOURCES env variable")
+	err = viper.BindPFlag(RemediationResourcesFlag, cmd.PersistentFlags().Lookup(RemediationResourcesFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(HealthChecksFlag, "lease", "Comma separated list of health checks: lease, kubelet-lease, condition, http. Default: 'lease'. Can be set using HEALTH_CHECKS env variable")
+	err = viper.BindPFlag(HealthChecksFlag, cmd.PersistentFlags().Lookup(HealthChecksFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(HealthChecksModeFlag, "any", "How results of health checks are combined: any - node is unhealthy when any check fails, all - node is unhealthy when all checks fail, quorum - node is unhealthy when at least health-checks-quorum checks fail. Default: 'any'. Can be set using HEALTH_CHECKS_MODE env variable")
+	err = viper.BindPFlag(HealthChecksModeFlag, cmd.PersistentFlags().Lookup(HealthChecksModeFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(HealthChecksQuorumFlag, 1, "Number of health checks that have to fail to consider node unhealthy in quorum mode. Default: '1'. Can be set using HEALTH_CHECKS_QUORUM env variable")
+	err = viper.BindPFlag(HealthChecksQuorumFlag, cmd.PersistentFlags().Lookup(HealthChecksQuorumFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(UnhealthyConditionsFlag, "Ready=False,Ready=Unknown", "Comma separated list of node conditions (Type=Status) that make node unhealthy in condition health check. Default: 'Ready=False,Ready=Unknown'. Can be set using UNHEALTHY_CONDITIONS env variable")
+	err = viper.BindPFlag(UnhealthyConditionsFlag, cmd.PersistentFlags().Lookup(UnhealthyConditionsFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(UnhealthyConditionsThresholdFlag, 300, "Number of seconds unhealthy condition has to hold to fail condition health check. Default: '300'. Can be set using UNHEALTHY_CONDITIONS_THRESHOLD env variable")
+	err = viper.BindPFlag(UnhealthyConditionsThresholdFlag, cmd.PersistentFlags().Lookup(UnhealthyConditionsThresholdFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(HTTPCheckPortFlag, 8080, "Port probed on node's InternalIP by http health check. Default: '8080'. Can be set using HTTP_CHECK_PORT env variable")
+	err = viper.BindPFlag(HTTPCheckPortFlag, cmd.PersistentFlags().Lookup(HTTPCheckPortFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(HTTPCheckPathFlag, "/healthz", "Path probed by http health check. Default: '/healthz'. Can be set using HTTP_CHECK_PATH env variable")
+	err = viper.BindPFlag(HTTPCheckPathFlag, cmd.PersistentFlags().Lookup(HTTPCheckPathFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(HTTPCheckTimeoutFlag, 5, "Timeout (in seconds) of a single http probe. Default: '5'. Can be set using HTTP_CHECK_TIMEOUT env variable")
+	err = viper.BindPFlag(HTTPCheckTimeoutFlag, cmd.PersistentFlags().Lookup(HTTPCheckTimeoutFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(HTTPCheckFailureThresholdFlag, 3, "Number of consecutive failed http probes after which http health check fails. Default: '3'. Can be set using HTTP_CHECK_FAILURE_THRESHOLD env variable")
+	err = viper.BindPFlag(HTTPCheckFailureThresholdFlag, cmd.PersistentFlags().Lookup(HTTPCheckFailureThresholdFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(HTTPCheckConcurrencyFlag, 10, "Maximum number of http probes running at the same time. Default: '10'. Can be set using HTTP_CHECK_CONCURRENCY env variable")
+	err = viper.BindPFlag(HTTPCheckConcurrencyFlag, cmd.PersistentFlags().Lookup(HTTPCheckConcurrencyFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(NodeLeasesFlag, "", "Comma separated list of namespaces with node leases, each with optional lease name template, e.g. 'kube-node-lease,apps:{{.NodeName}}-app'. Default: '' - node-lease-namespace is used. Can be set using NODE_LEASES env variable")
+	err = viper.BindPFlag(NodeLeasesFlag, cmd.PersistentFlags().Lookup(NodeLeasesFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(NodeLeasesModeFlag, "all", "all - node is healthy when all its leases are fresh, any - node is healthy when any of its leases is fresh. Default: 'all'. Can be set using NODE_LEASES_MODE env variable")
+	err = viper.BindPFlag(NodeLeasesModeFlag, cmd.PersistentFlags().Lookup(NodeLeasesModeFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(WorkersFlag, 4, "Number of nodes processed at the same time. Default: 4. Can be set using WORKERS env variable")
+	err = viper.BindPFlag(WorkersFlag, cmd.PersistentFlags().Lookup(WorkersFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(InformerResyncFlag, 60, "Interval in seconds in which all nodes and leases are processed again, even if they weren't updated. 0 disables resync. Default: 60. Can be set using INFORMER_RESYNC env variable")
+	err = viper.BindPFlag(InformerResyncFlag, cmd.PersistentFlags().Lookup(InformerResyncFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Bool(RollbackTerminationFlag, false, "Roll back termination preparation when node becomes healthy before it is terminated - node is registered back in load balancers, untainted and uncordoned. Default: 'false'. Can be set using ROLLBACK_TERMINATION env variable")
+	err = viper.BindPFlag(RollbackTerminationFlag, cmd.PersistentFlags().Lookup(RollbackTerminationFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().String(MaintenanceWindowsFlag, "", "Semicolon separated list of maintenance windows during which nodes aren't moved towards termination. Each window is a cron expression of its start (UTC) followed by duration, e.g. '0 22 * * 5 60h;0 2 * * 1-4 2h'. Default: ''. Can be set using MAINTENANCE_WINDOWS env variable")
+	err = viper.BindPFlag(MaintenanceWindowsFlag, cmd.PersistentFlags().Lookup(MaintenanceWindowsFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(TerminationMaxAttemptsFlag, 5, "Number of failed terminations after which node is labeled termination_failed. 0 - retry without limit. Default: 5. Can be set using TERMINATION_MAX_ATTEMPTS env variable")
+	err = viper.BindPFlag(TerminationMaxAttemptsFlag, cmd.PersistentFlags().Lookup(TerminationMaxAttemptsFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(TerminationRetryBackoffFlag, 30, "Number of seconds after which failed termination is retried, doubled after each failed attempt (up to 1 hour). Default: 30. Can be set using TERMINATION_RETRY_BACKOFF env variable")
+	err = viper.BindPFlag(TerminationRetryBackoffFlag, cmd.PersistentFlags().Lookup(TerminationRetryBackoffFlag))
+	if err != nil {
+		return err
+	}
+	cmd.PersistentFlags().Int(TerminationFailedTimeoutFlag, 0, "Number of seconds after which termination of node labeled termination_failed is retried. Default: '0' - only after manual intervention. Can be set using TERMINATION_FAILED_TIMEOUT env variable")
+	err = viper.BindPFlag(TerminationFailedTimeoutFlag, cmd.PersistentFlags().Lookup(TerminationFailedTimeoutFlag))
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/aws/smithy-go"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
@@ -26,10 +27,12 @@ type AwsCloudProvider struct {
 }
 
 const (
-	TerminationEventActionFailed           = "Instance Termination Failed"
-	TerminationEventActionSucceeded        = "Instance Terminated"
-	PrepareTerminationEventActionFailed    = "Instance Preparation For Termination Failed"
-	PrepareTerminationEventActionSucceeded = "Instance Prepared For Termination "
+	TerminationEventActionFailed            = "Instance Termination Failed"
+	TerminationEventActionSucceeded         = "Instance Terminated"
+	PrepareTerminationEventActionFailed     = "Instance Preparation For Termination Failed"
+	PrepareTerminationEventActionSucceeded  = "Instance Prepared For Termination "
+	RollbackTerminationEventActionFailed    = "Instance Termination Rollback Failed"
+	RollbackTerminationEventActionSucceeded = "Instance Termination Rolled Back"
 )
 
 func CreateCloudProvider(ctx context.Context) (AwsCloudProvider, error) {
@@ -58,29 +61,42 @@ func (p AwsCloudProvider) TerminateNode(ctx context.Context, cloudProviderNodeId
 	return TerminationEventActionSucceeded, nil
 }
 
-func (p AwsCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, error) {
+func (p AwsCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, []cloudproviders.TrafficSource, error) {
 	instanceId, err := awscloudproviderv1.KubernetesInstanceID(cloudProviderNodeId).MapToAWSInstanceID()
 	if err != nil {
-		return PrepareTerminationEventActionFailed, err
+		return PrepareTerminationEventActionFailed, nil, err
 	}
 	asgName, err := p.getAsgForInstance(ctx, string(instanceId))
 	if err != nil {
-		return PrepareTerminationEventActionFailed, err
+		return PrepareTerminationEventActionFailed, nil, err
 	}
+	detached := []cloudproviders.TrafficSource{}
 	if asgName != nil {
 		ts, err := p.getTrafficSourcesForAsg(ctx, asgName)
 		if err != nil {
-			return PrepareTerminationEventActionFailed, err
+			return PrepareTerminationEventActionFailed, nil, err
 		}
 		if len(ts) > 0 {
-			err := p.detachInstanceFromTrafficSources(ctx, ts, string(instanceId))
+			detached, err = p.detachInstanceFromTrafficSources(ctx, ts, string(instanceId))
 			if err != nil {
-				return PrepareTerminationEventActionFailed, err
+				return PrepareTerminationEventActionFailed, detached, err
 			}
 
 		}
 	}
-	return PrepareTerminationEventActionSucceeded, nil
+	return PrepareTerminationEventActionSucceeded, detached, nil
+}
+
+func (p AwsCloudProvider) RollbackTermination(ctx context.Context, cloudProviderNodeId string, sources []cloudproviders.TrafficSource) (string, error) {
+	instanceId, err := awscloudproviderv1.KubernetesInstanceID(cloudProviderNodeId).MapToAWSInstanceID()
+	if err != nil {
+		return RollbackTerminationEventActionFailed, err
+	}
+	err = p.attachInstanceToTrafficSources(ctx, sources, string(instanceId))
+	if err != nil {
+		return RollbackTerminationEventActionFailed, err
+	}
+	return RollbackTerminationEventActionSucceeded, nil
 }
 
 func (p AwsCloudProvider) terminateInstance(ctx context.Context, instanceId string) error {
@@ -147,7 +163,8 @@ func (p AwsCloudProvider) getTrafficSourcesForAsg(ctx context.Context, asgName *
 	return ret, err
 }
 
-func (p AwsCloudProvider) detachInstanceFromTrafficSources(ctx context.Context, sources []autoscalingtypes.TrafficSourceState, instanceId string) error {
+func (p AwsCloudProvider) detachInstanceFromTrafficSources(ctx context.Context, sources []autoscalingtypes.TrafficSourceState, instanceId string) ([]cloudproviders.TrafficSource, error) {
+	detached := []cloudproviders.TrafficSource{}
 	for i := range sources {
 		log.Debugf("Detaching instance %s from %s %s", instanceId, *sources[i].Type, *sources[i].Identifier)
 
@@ -160,7 +177,7 @@ func (p AwsCloudProvider) detachInstanceFromTrafficSources(ctx context.Context,
 			}
 			_, err := p.ElbClient.DeregisterInstancesFromLoadBalancer(ctx, &input)
 			if err != nil {
-				return err
+				return detached, err
 			}
 		} else if *sources[i].Type == "elbv2" {
 			input := elasticloadbalancingv2.DeregisterTargetsInput{
@@ -170,6 +187,40 @@ func (p AwsCloudProvider) detachInstanceFromTrafficSources(ctx context.Context,
 				},
 			}
 			_, err := p.Elbv2Client.DeregisterTargets(ctx, &input)
+			if err != nil {
+				return detached, err
+			}
+		} else {
+			continue
+		}
+		detached = append(detached, cloudproviders.TrafficSource{Type: *sources[i].Type, Identifier: *sources[i].Identifier})
+	}
+	return detached, nil
+}
+
+func (p AwsCloudProvider) attachInstanceToTrafficSources(ctx context.Context, sources []cloudproviders.TrafficSource, instanceId string) error {
+	for i := range sources {
+		log.Debugf("Attaching instance %s to %s %s", instanceId, sources[i].Type, sources[i].Identifier)
+
+		if sources[i].Type == "elb" {
+			input := elasticloadbalancing.RegisterInstancesWithLoadBalancerInput{
+				LoadBalancerName: &sources[i].Identifier,
+				Instances: []elasticloadbalancingtypes.Instance{
+					{InstanceId: &instanceId},
+				},
+			}
+			_, err := p.ElbClient.RegisterInstancesWithLoadBalancer(ctx, &input)
+			if err != nil {
+				return err
+			}
+		} else if sources[i].Type == "elbv2" {
+			input := elasticloadbalancingv2.RegisterTargetsInput{
+				TargetGroupArn: &sources[i].Identifier,
+				Targets: []elasticloadbalancingv2types.TargetDescription{
+					{Id: &instanceId},
+				},
+			}
+			_, err := p.Elbv2Client.RegisterTargets(ctx, &input)
 			if err != nil {
 				return err
 			}
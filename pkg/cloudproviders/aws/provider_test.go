@@ -10,6 +10,7 @@ import (
 	elasticloadbalancingtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing/types"
 	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
 	elasticloadbalancingv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	mockaws "github.com/dbschenker/node-undertaker/pkg/cloudproviders/aws/mocks"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -102,9 +103,10 @@ func TestPrepareTerminationNodeNotInLB(t *testing.T) {
 		ElbClient:   elbClient,
 	}
 
-	res, err := cloudProvider.PrepareTermination(context.TODO(), "aws://nonexistant/"+instanceId)
+	res, detached, err := cloudProvider.PrepareTermination(context.TODO(), "aws://nonexistant/"+instanceId)
 	assert.NoError(t, err)
 	assert.Equal(t, PrepareTerminationEventActionSucceeded, res)
+	assert.Empty(t, detached)
 }
 
 func TestPrepareTerminationNodeInMultipleLB(t *testing.T) {
@@ -190,9 +192,67 @@ func TestPrepareTerminationNodeInMultipleLB(t *testing.T) {
 		ElbClient:   elbClient,
 	}
 
-	res, err := cloudProvider.PrepareTermination(context.TODO(), "aws://nonexistant/"+instanceId)
+	res, detached, err := cloudProvider.PrepareTermination(context.TODO(), "aws://nonexistant/"+instanceId)
 	assert.NoError(t, err)
 	assert.Equal(t, PrepareTerminationEventActionSucceeded, res)
+	assert.Equal(t, []cloudproviders.TrafficSource{
+		{Type: trafficSourceType1, Identifier: trafficSourceIdentifier1},
+		{Type: trafficSourceType2, Identifier: trafficSourceIdentifier2},
+	}, detached)
+}
+
+func TestRollbackTermination(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	elbClient := mockaws.NewMockELBCLIENT(mockCtrl)
+	elbv2Client := mockaws.NewMockELBV2CLIENT(mockCtrl)
+
+	instanceId := "i-12312313"
+	lbName := "lb-name1"
+	targetGroupArn := "arn:aws:elbv2"
+
+	expectedInput1 := elasticloadbalancing.RegisterInstancesWithLoadBalancerInput{
+		LoadBalancerName: &lbName,
+		Instances: []elasticloadbalancingtypes.Instance{
+			{InstanceId: &instanceId},
+		},
+	}
+	elbClient.EXPECT().RegisterInstancesWithLoadBalancer(gomock.Any(), &expectedInput1).Return(nil, nil).Times(1)
+
+	expectedInput2 := elasticloadbalancingv2.RegisterTargetsInput{
+		TargetGroupArn: &targetGroupArn,
+		Targets: []elasticloadbalancingv2types.TargetDescription{
+			{Id: &instanceId},
+		},
+	}
+	elbv2Client.EXPECT().RegisterTargets(gomock.Any(), &expectedInput2).Return(nil, nil).Times(1)
+
+	cloudProvider := AwsCloudProvider{
+		Elbv2Client: elbv2Client,
+		ElbClient:   elbClient,
+	}
+
+	res, err := cloudProvider.RollbackTermination(context.TODO(), "aws://nonexistant/"+instanceId, []cloudproviders.TrafficSource{
+		{Type: "elb", Identifier: lbName},
+		{Type: "elbv2", Identifier: targetGroupArn},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, RollbackTerminationEventActionSucceeded, res)
+}
+
+func TestRollbackTerminationError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	elbv2Client := mockaws.NewMockELBV2CLIENT(mockCtrl)
+	elbv2Client.EXPECT().RegisterTargets(gomock.Any(), gomock.Any()).Return(nil, errors.New("test error")).Times(1)
+
+	cloudProvider := AwsCloudProvider{
+		Elbv2Client: elbv2Client,
+	}
+
+	res, err := cloudProvider.RollbackTermination(context.TODO(), "aws://nonexistant/i-12312313", []cloudproviders.TrafficSource{
+		{Type: "elbv2", Identifier: "arn:aws:elbv2"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, RollbackTerminationEventActionFailed, res)
 }
 
 func TestTerminateNodeWrongProviderId(t *testing.T) {
@@ -214,7 +274,7 @@ func TestPrepareTerminationNodeWrongProviderId(t *testing.T) {
 	cloudProvider := AwsCloudProvider{
 		Ec2Client: ec2Client,
 	}
-	res, err := cloudProvider.PrepareTermination(context.TODO(), "test123")
+	res, _, err := cloudProvider.PrepareTermination(context.TODO(), "test123")
 	assert.Error(t, err)
 	assert.Equal(t, PrepareTerminationEventActionFailed, res)
 }
@@ -471,6 +531,7 @@ func TestDetachInstanceFromTrafficSources(t *testing.T) {
 	}
 	elbClient.EXPECT().DeregisterInstancesFromLoadBalancer(gomock.Any(), &expectedInput3).Return(nil, nil).Times(1)
 
-	err := p.detachInstanceFromTrafficSources(context.TODO(), sources, instanceId)
+	detached, err := p.detachInstanceFromTrafficSources(context.TODO(), sources, instanceId)
 	assert.NoError(t, err)
+	assert.Len(t, detached, 3)
 }
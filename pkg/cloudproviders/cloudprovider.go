@@ -4,11 +4,20 @@ import "context"
 
 //go:generate mockgen -destination=./mocks/api_mocks.go github.com/dbschenker/node-undertaker/pkg/cloudproviders CLOUDPROVIDER
 
+// TrafficSource is a load balancer (or target group) node was detached from while preparing termination
+type TrafficSource struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+}
+
 type CLOUDPROVIDER interface {
 	ValidateConfig() error
 
 	// TerminateNode terminates node with provided providerId. Returns message (for creation of events) and error
 	TerminateNode(context.Context, string) (string, error)
-	// PrepareTermination prepares node to be termianted (i.e. removes it from load balancers)
-	PrepareTermination(context.Context, string) (string, error)
+	// PrepareTermination prepares node to be termianted (i.e. removes it from load balancers). Returns message, traffic sources
+	// node was detached from (also when error occurred after some of them were detached) and error
+	PrepareTermination(context.Context, string) (string, []TrafficSource, error)
+	// RollbackTermination reverts termination preparation - registers node in traffic sources it was detached from
+	RollbackTermination(context.Context, string, []TrafficSource) (string, error)
 }
@@ -0,0 +1,76 @@
+package karpenter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// NodeClaimGVR identifies Karpenter's NodeClaim resource
+var NodeClaimGVR = schema.GroupVersionResource{Group: "karpenter.sh", Version: "v1", Resource: "nodeclaims"}
+
+// KarpenterCloudProvider terminates nodes by deleting their NodeClaims, so Karpenter drains and terminates instances itself
+type KarpenterCloudProvider struct {
+	DynamicClient dynamic.Interface
+}
+
+func CreateCloudProvider(ctx context.Context, cfg *config.Config) (KarpenterCloudProvider, error) {
+	ret := KarpenterCloudProvider{}
+	ret.DynamicClient = cfg.DynamicClient
+
+	return ret, nil
+}
+
+func (p KarpenterCloudProvider) ValidateConfig() error {
+	if p.DynamicClient == nil {
+		return errors.New("karpenter cloud provider requires dynamic client")
+	}
+	return nil
+}
+
+func (p KarpenterCloudProvider) TerminateNode(ctx context.Context, cloudProviderNodeId string) (string, error) {
+	nodeClaim, err := p.findNodeClaim(ctx, cloudProviderNodeId)
+	if err != nil {
+		return "NodeClaim Deletion Failed", err
+	}
+	if nodeClaim.GetDeletionTimestamp() != nil {
+		return fmt.Sprintf("NodeClaim %s is already being deleted", nodeClaim.GetName()), nil
+	}
+	err = p.DynamicClient.Resource(NodeClaimGVR).Delete(ctx, nodeClaim.GetName(), metav1.DeleteOptions{})
+	if err != nil {
+		return "NodeClaim Deletion Failed", err
+	}
+	return "NodeClaim Deleted", nil
+}
+
+func (p KarpenterCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, []cloudproviders.TrafficSource, error) {
+	return "No preparation required", nil, nil
+}
+
+func (p KarpenterCloudProvider) RollbackTermination(ctx context.Context, cloudProviderNodeId string, sources []cloudproviders.TrafficSource) (string, error) {
+	return "No rollback required", nil
+}
+
+// findNodeClaim returns NodeClaim of the instance with given providerId
+func (p KarpenterCloudProvider) findNodeClaim(ctx context.Context, cloudProviderNodeId string) (*unstructured.Unstructured, error) {
+	if p.DynamicClient == nil {
+		return nil, errors.New("dynamic client is nil")
+	}
+	nodeClaims, err := p.DynamicClient.Resource(NodeClaimGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodeClaims.Items {
+		providerId, _, _ := unstructured.NestedString(nodeClaims.Items[i].Object, "status", "providerID")
+		if providerId == cloudProviderNodeId {
+			return &nodeClaims.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("couldn't find NodeClaim with providerId: %s", cloudProviderNodeId)
+}
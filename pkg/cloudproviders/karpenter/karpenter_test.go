@@ -0,0 +1,72 @@
+package karpenter
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func createNodeClaim(name, providerId string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "karpenter.sh/v1",
+		"kind":       "NodeClaim",
+		"metadata":   map[string]interface{}{"name": name},
+		"status":     map[string]interface{}{"providerID": providerId},
+	}}
+}
+
+func createProvider(t *testing.T, objects ...runtime.Object) KarpenterCloudProvider {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{NodeClaimGVR: "NodeClaimList"}, objects...)
+	cp, err := CreateCloudProvider(context.TODO(), &config.Config{DynamicClient: client})
+	require.NoError(t, err)
+	return cp
+}
+
+func TestValidateConfig(t *testing.T) {
+	cp, err := CreateCloudProvider(context.TODO(), &config.Config{})
+	assert.NoError(t, err)
+	assert.Error(t, cp.ValidateConfig())
+
+	assert.NoError(t, createProvider(t).ValidateConfig())
+}
+
+func TestTerminateNode(t *testing.T) {
+	ctx := context.TODO()
+	cp := createProvider(t, createNodeClaim("default-abcde", "aws:///eu-central-1a/i-1"), createNodeClaim("default-fghij", "aws:///eu-central-1a/i-2"))
+
+	msg, err := cp.TerminateNode(ctx, "aws:///eu-central-1a/i-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "NodeClaim Deleted", msg)
+
+	nodeClaims, err := cp.DynamicClient.Resource(NodeClaimGVR).List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	if assert.Len(t, nodeClaims.Items, 1) {
+		assert.Equal(t, "default-abcde", nodeClaims.Items[0].GetName())
+	}
+}
+
+func TestTerminateNodeAlreadyDeleted(t *testing.T) {
+	nodeClaim := createNodeClaim("default-abcde", "aws:///eu-central-1a/i-1")
+	now := metav1.Now()
+	nodeClaim.SetDeletionTimestamp(&now)
+	cp := createProvider(t, nodeClaim)
+
+	msg, err := cp.TerminateNode(context.TODO(), "aws:///eu-central-1a/i-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "NodeClaim default-abcde is already being deleted", msg)
+}
+
+func TestTerminateNodeNotFound(t *testing.T) {
+	cp := createProvider(t, createNodeClaim("default-abcde", "aws:///eu-central-1a/i-1"))
+
+	msg, err := cp.TerminateNode(context.TODO(), "aws:///eu-central-1a/i-2")
+	assert.Error(t, err)
+	assert.Equal(t, "NodeClaim Deletion Failed", msg)
+}
@@ -3,6 +3,7 @@ package kind
 import (
 	"context"
 	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	log "github.com/sirupsen/logrus"
 	"os/exec"
 	"regexp"
@@ -45,6 +46,10 @@ func (p KindCloudProvider) TerminateNode(ctx context.Context, cloudProviderNodeI
 	return "Instance Terminated", nil
 }
 
-func (p KindCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, error) {
-	return "No preparation required", nil
+func (p KindCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, []cloudproviders.TrafficSource, error) {
+	return "No preparation required", nil, nil
+}
+
+func (p KindCloudProvider) RollbackTermination(ctx context.Context, cloudProviderNodeId string, sources []cloudproviders.TrafficSource) (string, error) {
+	return "No rollback required", nil
 }
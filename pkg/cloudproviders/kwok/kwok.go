@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -50,6 +51,10 @@ func (p KwokCloudProvider) TerminateNode(ctx context.Context, cloudProviderNodeI
 	return "Instance Terminated", nil
 }
 
-func (p KwokCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, error) {
-	return "No preparation required", nil
+func (p KwokCloudProvider) PrepareTermination(ctx context.Context, cloudProviderNodeId string) (string, []cloudproviders.TrafficSource, error) {
+	return "No preparation required", nil, nil
+}
+
+func (p KwokCloudProvider) RollbackTermination(ctx context.Context, cloudProviderNodeId string, sources []cloudproviders.TrafficSource) (string, error) {
+	return "No rollback required", nil
 }
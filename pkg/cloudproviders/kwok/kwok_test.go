@@ -38,7 +38,7 @@ func TestPrepareTermination(t *testing.T) {
 	err = cp.CreateNode(ctx, nodeName)
 	assert.NoError(t, err)
 
-	ret, err := cp.PrepareTermination(ctx, fmt.Sprintf("kwok://%s", nodeName))
+	ret, _, err := cp.PrepareTermination(ctx, fmt.Sprintf("kwok://%s", nodeName))
 	assert.NoError(t, err)
 	assert.Equal(t, "No preparation required", ret)
 }
@@ -2,6 +2,7 @@ package kubeclient
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/clientcmd"
@@ -9,8 +10,7 @@ import (
 
 // GetClient - gets kubernetes client with namespace it runs in
 func GetClient() (kubernetes.Interface, string, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil)
+	kubeConfig := getClientConfig()
 	config, err := kubeConfig.ClientConfig()
 	if err != nil {
 		return nil, "", err
@@ -24,6 +24,20 @@ func GetClient() (kubernetes.Interface, string, error) {
 	return clientset, namespace, err
 }
 
+// GetDynamicClient - gets kubernetes client for custom resources
+func GetDynamicClient() (dynamic.Interface, error) {
+	config, err := getClientConfig().ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
 func GetFakeClient() (kubernetes.Interface, string, error) {
 	return fake.NewClientset(), metav1.NamespaceDefault, nil
 }
+
+func getClientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, nil)
+}
@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	Group   = "node-undertaker.dbschenker.com"
+	Version = "v1alpha1"
+
+	NodeRemediationPolicyKind     = "NodeRemediationPolicy"
+	NodeRemediationPolicyResource = "noderemediationpolicies"
+	NodeRemediationKind           = "NodeRemediation"
+	NodeRemediationResource       = "noderemediations"
+
+	// PhaseCompleted is phase of NodeRemediation after its node was removed
+	PhaseCompleted = "completed"
+	// PhaseHealthy is phase of NodeRemediation after its node recovered (node without node-undertaker label)
+	PhaseHealthy = "healthy"
+
+	ConditionReady = "Ready"
+	ReasonValid    = "Valid"
+	ReasonInvalid  = "Invalid"
+)
+
+// NodeRemediationPolicyGVR is GroupVersionResource of NodeRemediationPolicy resources
+var NodeRemediationPolicyGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: NodeRemediationPolicyResource}
+
+// NodeRemediationGVR is GroupVersionResource of NodeRemediation resources
+var NodeRemediationGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: NodeRemediationResource}
+
+// NodeRemediationPolicy is cluster scoped resource which spec has the same fields as policy in policies file (except name)
+type NodeRemediationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeRemediationPolicySpec   `json:"spec"`
+	Status NodeRemediationPolicyStatus `json:"status,omitempty"`
+}
+
+// NodeRemediationPolicySpec overrides delays, drain options and enabled actions for nodes matching its node selector.
+// Fields that are not set are taken from global configuration
+type NodeRemediationPolicySpec struct {
+	NodeSelector                      string   `json:"nodeSelector"`
+	NodeInitialThreshold              *int     `json:"nodeInitialThreshold,omitempty"`
+	DrainDelay                        *int     `json:"drainDelay,omitempty"`
+	CloudPrepareTerminationDelay      *int     `json:"cloudPrepareTerminationDelay,omitempty"`
+	CloudTerminationDelay             *int     `json:"cloudTerminationDelay,omitempty"`
+	DrainTimeout                      *int     `json:"drainTimeout,omitempty"`
+	DrainGracePeriod                  *int     `json:"drainGracePeriod,omitempty"`
+	DrainDisableEvictionAfterAttempts *int     `json:"drainDisableEvictionAfterAttempts,omitempty"`
+	DrainPodSelector                  *string  `json:"drainPodSelector,omitempty"`
+	DrainSkipWaitForDeleteTimeout     *int     `json:"drainSkipWaitForDeleteTimeout,omitempty"`
+	DisabledActions                   []string `json:"disabledActions,omitempty"`
+	NodeLeaseNamespace                *string  `json:"nodeLeaseNamespace,omitempty"`
+	NotificationsSlackWebhook         *string  `json:"notificationsSlackWebhook,omitempty"`
+	MaxDisruptedNodes                 *int     `json:"maxDisruptedNodes,omitempty"`
+	MaxDisruptedNodesPercentage       *int     `json:"maxDisruptedNodesPercentage,omitempty"`
+}
+
+type NodeRemediationPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	MatchedNodes       int                `json:"matchedNodes"`
+	LastError          string             `json:"lastError,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NodeRemediation records remediation of a single node. It has the same name as the node and is owned by it
+type NodeRemediation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status NodeRemediationStatus `json:"status,omitempty"`
+}
+
+type NodeRemediationStatus struct {
+	// Phase is the current state of the node (value of node-undertaker label or PhaseHealthy)
+	Phase                  string                  `json:"phase,omitempty"`
+	Transitions            []PhaseTransition       `json:"transitions,omitempty"`
+	StaleLease             *StaleLease             `json:"staleLease,omitempty"`
+	Drain                  *DrainResult            `json:"drain,omitempty"`
+	CloudProviderResponses []CloudProviderResponse `json:"cloudProviderResponses,omitempty"`
+	// Retries is number of failed cloud provider actions that had to be retried
+	Retries int `json:"retries"`
+}
+
+type PhaseTransition struct {
+	Phase  string      `json:"phase"`
+	Time   metav1.Time `json:"time"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// StaleLease is the evidence of node being unhealthy - the lease found stale by health check as observed when node was marked unhealthy
+type StaleLease struct {
+	ObservedTime         metav1.Time  `json:"observedTime"`
+	Namespace            string       `json:"namespace,omitempty"`
+	Name                 string       `json:"name,omitempty"`
+	Found                bool         `json:"found"`
+	HolderIdentity       string       `json:"holderIdentity,omitempty"`
+	RenewTime            *metav1.Time `json:"renewTime,omitempty"`
+	LeaseDurationSeconds int32        `json:"leaseDurationSeconds,omitempty"`
+}
+
+type DrainResult struct {
+	Status    string `json:"status,omitempty"`
+	Started   string `json:"started,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+type CloudProviderResponse struct {
+	Time     metav1.Time `json:"time"`
+	Action   string      `json:"action"`
+	Response string      `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
@@ -0,0 +1,226 @@
+package circuitbreaker
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sync"
+	"time"
+)
+
+const (
+	// OpenedAtAnnotation keeps time the circuit was opened at on the circuit breaker lease
+	OpenedAtAnnotation = "dbschenker.com/node-undertaker-circuit-opened-at"
+	// syncInterval is how often state of the circuit is read from the lease - manual reset takes effect within this time
+	syncInterval = 10 * time.Second
+)
+
+// CircuitBreaker freezes node remediation when too many nodes turn stale within a sliding window.
+// Open circuit is stored in a lease, so it survives leader failover and can be reset by deleting the lease
+type CircuitBreaker struct {
+	mutex               sync.Mutex
+	thresholdPercentage int
+	window              time.Duration
+	cooldown            time.Duration
+	staleNodes          map[string]time.Time
+	openedAt            *time.Time
+	client              kubernetes.Interface
+	namespace           string
+	name                string
+	syncedAt            time.Time
+	openGauge           prometheus.Gauge
+}
+
+// New creates circuit breaker. It opens when more than thresholdPercentage of watched nodes turned stale within window.
+// It closes after cooldown (or only with manual reset when cooldown is 0)
+func New(thresholdPercentage int, window, cooldown time.Duration) *CircuitBreaker {
+	collectors.CircuitOpen.Set(0)
+	return &CircuitBreaker{
+		thresholdPercentage: thresholdPercentage,
+		window:              window,
+		cooldown:            cooldown,
+		staleNodes:          make(map[string]time.Time),
+		openGauge:           collectors.CircuitOpen,
+	}
+}
+
+// SetDryRun makes circuit breaker report its state with dry-run metric, so simulated state isn't mistaken for real one.
+// Lease shouldn't be set in dry-run mode - the state is kept only in memory then
+func (cb *CircuitBreaker) SetDryRun() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.openGauge = collectors.DryRunCircuitOpen
+	cb.openGauge.Set(0)
+}
+
+// SetLease sets lease in which state of the circuit is stored. Without it the state is kept only in memory
+func (cb *CircuitBreaker) SetLease(client kubernetes.Interface, namespace, name string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.client = client
+	cb.namespace = namespace
+	cb.name = name
+	cb.syncedAt = time.Time{}
+}
+
+// RecordStale registers node that is stale. Node is counted from the first observation until it recovers,
+// so nodes that stay stale don't open the circuit again. Returns true if this observation opened the circuit
+func (cb *CircuitBreaker) RecordStale(ctx context.Context, nodeName string, now time.Time, watchedNodes int) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.sync(ctx, now)
+	if _, found := cb.staleNodes[nodeName]; !found {
+		cb.staleNodes[nodeName] = now
+	}
+
+	if cb.openedAt != nil || watchedNodes <= 0 {
+		return false
+	}
+	if cb.countStale(now)*100 > cb.thresholdPercentage*watchedNodes {
+		cb.openedAt = &now
+		cb.openGauge.Set(1)
+		cb.store(ctx, now)
+		return true
+	}
+	return false
+}
+
+// Forget forgets node that isn't stale anymore or was removed
+func (cb *CircuitBreaker) Forget(nodeName string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	delete(cb.staleNodes, nodeName)
+}
+
+// StaleNodes returns number of nodes that turned stale within the window
+func (cb *CircuitBreaker) StaleNodes(now time.Time) int {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.countStale(now)
+}
+
+// IsOpen checks if circuit is open. Circuit is closed automatically after cooldown passes
+func (cb *CircuitBreaker) IsOpen(ctx context.Context, now time.Time) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.sync(ctx, now)
+	if cb.openedAt == nil {
+		return false
+	}
+	if cb.cooldown > 0 && !now.Before(cb.openedAt.Add(cb.cooldown)) {
+		log.Infof("Circuit breaker closed after cooldown of %s", cb.cooldown)
+		cb.close()
+		cb.remove(ctx)
+		return false
+	}
+	return true
+}
+
+// UntilClosed returns time left until open circuit closes after cooldown. Returns 0 when circuit is closed
+// or can be closed only with manual reset
+func (cb *CircuitBreaker) UntilClosed(now time.Time) time.Duration {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.openedAt == nil || cb.cooldown == 0 {
+		return 0
+	}
+	return max(cb.openedAt.Add(cb.cooldown).Sub(now), 0)
+}
+
+// sync reads state of the circuit from the lease. Missing lease means the circuit is closed (or was reset manually)
+func (cb *CircuitBreaker) sync(ctx context.Context, now time.Time) {
+	if cb.client == nil || (!now.Before(cb.syncedAt) && now.Sub(cb.syncedAt) < syncInterval) {
+		return
+	}
+	lease, err := cb.client.CoordinationV1().Leases(cb.namespace).Get(ctx, cb.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if cb.openedAt != nil {
+			log.Infof("Circuit breaker reset - lease %s/%s was removed", cb.namespace, cb.name)
+			cb.close()
+		}
+		cb.syncedAt = now
+		return
+	}
+	if err != nil {
+		log.Errorf("Couldn't read circuit breaker lease %s/%s: %v", cb.namespace, cb.name, err)
+		return
+	}
+	cb.syncedAt = now
+
+	openedAt, err := time.Parse(time.RFC3339, lease.Annotations[OpenedAtAnnotation])
+	if err != nil {
+		log.Warnf("Circuit breaker lease %s/%s has no proper %s annotation - using its creation time", cb.namespace, cb.name, OpenedAtAnnotation)
+		openedAt = lease.CreationTimestamp.Time
+	}
+	if cb.openedAt == nil {
+		log.Infof("Circuit breaker is open since %s", openedAt.Format(time.RFC3339))
+		cb.openGauge.Set(1)
+	}
+	cb.openedAt = &openedAt
+}
+
+// store saves open circuit in the lease. Circuit stays open in memory when it fails
+func (cb *CircuitBreaker) store(ctx context.Context, openedAt time.Time) {
+	if cb.client == nil {
+		return
+	}
+	leases := cb.client.CoordinationV1().Leases(cb.namespace)
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cb.name,
+			Namespace:   cb.namespace,
+			Annotations: map[string]string{OpenedAtAnnotation: openedAt.Format(time.RFC3339)},
+		},
+	}
+	_, err := leases.Create(ctx, lease, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		lease, err = leases.Get(ctx, cb.name, metav1.GetOptions{})
+		if err == nil {
+			if lease.Annotations == nil {
+				lease.Annotations = map[string]string{}
+			}
+			lease.Annotations[OpenedAtAnnotation] = openedAt.Format(time.RFC3339)
+			_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		log.Errorf("Couldn't store open circuit breaker in lease %s/%s: %v", cb.namespace, cb.name, err)
+		return
+	}
+	cb.syncedAt = openedAt
+}
+
+// remove deletes the lease of closed circuit
+func (cb *CircuitBreaker) remove(ctx context.Context) {
+	if cb.client == nil {
+		return
+	}
+	err := cb.client.CoordinationV1().Leases(cb.namespace).Delete(ctx, cb.name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Errorf("Couldn't remove circuit breaker lease %s/%s: %v", cb.namespace, cb.name, err)
+	}
+}
+
+// close closes the circuit. Stale nodes are kept, so nodes that are still stale don't open it again
+func (cb *CircuitBreaker) close() {
+	cb.openedAt = nil
+	cb.openGauge.Set(0)
+}
+
+// countStale returns number of nodes that turned stale within the window
+func (cb *CircuitBreaker) countStale(now time.Time) int {
+	ret := 0
+	for _, since := range cb.staleNodes {
+		if !since.Before(now.Add(-cb.window)) {
+			ret++
+		}
+	}
+	return ret
+}
@@ -0,0 +1,167 @@
+package circuitbreaker
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	now := time.Now()
+	cb := New(20, time.Minute, time.Hour)
+
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now, 10))
+	assert.False(t, cb.RecordStale(context.TODO(), "node2", now, 10))
+	assert.False(t, cb.IsOpen(context.TODO(), now))
+	// the same node is counted once
+	assert.False(t, cb.RecordStale(context.TODO(), "node2", now, 10))
+	assert.True(t, cb.RecordStale(context.TODO(), "node3", now, 10))
+	assert.True(t, cb.IsOpen(context.TODO(), now))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectors.CircuitOpen))
+
+	// already open
+	assert.False(t, cb.RecordStale(context.TODO(), "node4", now, 10))
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	now := time.Now()
+	cb := New(20, time.Minute, time.Hour)
+
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now.Add(-2*time.Minute), 10))
+	assert.False(t, cb.RecordStale(context.TODO(), "node2", now.Add(-90*time.Second), 10))
+	assert.False(t, cb.RecordStale(context.TODO(), "node3", now, 10))
+	assert.Equal(t, 1, cb.StaleNodes(now))
+	assert.False(t, cb.IsOpen(context.TODO(), now))
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	now := time.Now()
+	cb := New(10, time.Minute, time.Hour)
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.True(t, cb.IsOpen(context.TODO(), now.Add(59*time.Minute)))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(time.Hour)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collectors.CircuitOpen))
+	// node that is still stale doesn't open the circuit again
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now.Add(time.Hour), 2))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(time.Hour)))
+}
+
+func TestCircuitBreakerUntilClosed(t *testing.T) {
+	now := time.Now()
+	cb := New(10, time.Minute, time.Hour)
+	assert.Zero(t, cb.UntilClosed(now))
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.Equal(t, 20*time.Minute, cb.UntilClosed(now.Add(40*time.Minute)))
+	assert.Zero(t, cb.UntilClosed(now.Add(2*time.Hour)))
+
+	// closed only with manual reset
+	cb = New(10, time.Minute, 0)
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.Zero(t, cb.UntilClosed(now))
+}
+
+func TestCircuitBreakerCountsFirstObservation(t *testing.T) {
+	now := time.Now()
+	cb := New(50, time.Minute, time.Hour)
+
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now, 4))
+	assert.False(t, cb.RecordStale(context.TODO(), "node2", now.Add(50*time.Second), 4))
+	// node1 is still stale, but turned stale outside of the window
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now.Add(70*time.Second), 4))
+	assert.False(t, cb.RecordStale(context.TODO(), "node3", now.Add(70*time.Second), 4))
+	assert.Equal(t, 2, cb.StaleNodes(now.Add(70*time.Second)))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(70*time.Second)))
+}
+
+func TestCircuitBreakerForget(t *testing.T) {
+	now := time.Now()
+	cb := New(50, time.Minute, time.Hour)
+
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now, 4))
+	assert.False(t, cb.RecordStale(context.TODO(), "node2", now, 4))
+	cb.Forget("node1")
+	assert.Equal(t, 1, cb.StaleNodes(now))
+	// recovered node turning stale again is counted from the new observation
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", now.Add(90*time.Second), 4))
+	assert.Equal(t, 1, cb.StaleNodes(now.Add(90*time.Second)))
+}
+
+func TestCircuitBreakerManualOnly(t *testing.T) {
+	now := time.Now()
+	cb := New(10, time.Minute, 0)
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.True(t, cb.IsOpen(context.TODO(), now.Add(100*time.Hour)))
+}
+
+func TestCircuitBreakerNoWatchedNodes(t *testing.T) {
+	cb := New(10, time.Minute, 0)
+	assert.False(t, cb.RecordStale(context.TODO(), "node1", time.Now(), 0))
+}
+
+func TestCircuitBreakerStoresOpenCircuit(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	cb := New(10, time.Minute, 0)
+	cb.SetLease(client, "ns", "lease-circuit-breaker")
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	lease, err := client.CoordinationV1().Leases("ns").Get(context.TODO(), "lease-circuit-breaker", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, now.Format(time.RFC3339), lease.Annotations[OpenedAtAnnotation])
+
+	// new leader reads open circuit from the lease
+	other := New(10, time.Minute, 0)
+	other.SetLease(client, "ns", "lease-circuit-breaker")
+	assert.True(t, other.IsOpen(context.TODO(), now))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectors.CircuitOpen))
+	assert.False(t, other.RecordStale(context.TODO(), "node2", now, 2))
+}
+
+func TestCircuitBreakerResetByLeaseRemoval(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	cb := New(10, time.Minute, 0)
+	cb.SetLease(client, "ns", "lease-circuit-breaker")
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	require.NoError(t, client.CoordinationV1().Leases("ns").Delete(context.TODO(), "lease-circuit-breaker", metav1.DeleteOptions{}))
+
+	// state is read from the lease at most once per sync interval
+	assert.True(t, cb.IsOpen(context.TODO(), now.Add(time.Second)))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(syncInterval)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collectors.CircuitOpen))
+}
+
+func TestCircuitBreakerCooldownRemovesLease(t *testing.T) {
+	client := fake.NewClientset()
+	now := time.Now()
+	cb := New(10, time.Minute, time.Hour)
+	cb.SetLease(client, "ns", "lease-circuit-breaker")
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(time.Hour)))
+	_, err := client.CoordinationV1().Leases("ns").Get(context.TODO(), "lease-circuit-breaker", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestCircuitBreakerDryRun(t *testing.T) {
+	now := time.Now()
+	cb := New(10, time.Minute, time.Hour)
+	cb.SetDryRun()
+
+	assert.True(t, cb.RecordStale(context.TODO(), "node1", now, 2))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectors.DryRunCircuitOpen))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collectors.CircuitOpen))
+	assert.False(t, cb.IsOpen(context.TODO(), now.Add(time.Hour)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(collectors.DryRunCircuitOpen))
+}
@@ -4,38 +4,85 @@ import (
 	"fmt"
 	"github.com/dbschenker/node-undertaker/cmd/node-undertaker/flags"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/circuitbreaker"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/maintenance"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
+// CircuitBreakerLeaseSuffix is appended to leader election lease name to get name of the lease keeping open circuit breaker
+const CircuitBreakerLeaseSuffix = "-circuit-breaker"
+
 type Config struct {
-	CloudProvider                cloudproviders.CLOUDPROVIDER
-	DrainDelay                   int
-	CloudTerminationDelay        int
-	CloudPrepareTerminationDelay int
-	NodeInitialThreshold         int
-	Port                         int
-	K8sClient                    kubernetes.Interface
-	InformerResync               time.Duration
-	Namespace                    string
-	Hostname                     string
-	LeaseLockName                string
-	LeaseLockNamespace           string
-	NodeLeaseNamespace           string
-	InitialDelay                 int
-	StartupTime                  time.Time
-	NodeSelector                 labels.Selector
-	NotificationsSlackWebhook    *url.URL
+	CloudProvider                     cloudproviders.CLOUDPROVIDER
+	DrainDelay                        int
+	CloudTerminationDelay             int
+	CloudPrepareTerminationDelay      int
+	NodeInitialThreshold              int
+	Port                              int
+	K8sClient                         kubernetes.Interface
+	InformerResync                    time.Duration
+	Namespace                         string
+	Hostname                          string
+	LeaseLockName                     string
+	LeaseLockNamespace                string
+	NodeLeaseNamespace                string
+	InitialDelay                      int
+	StartupTime                       time.Time
+	NodeSelector                      labels.Selector
+	NotificationsSlackWebhook         *url.URL
+	DryRun                            bool
+	MaxDisruptedNodes                 int
+	MaxDisruptedNodesPercentage       int
+	NodeLister                        corelisters.NodeLister
+	CircuitBreakerThreshold           int
+	CircuitBreakerWindow              int
+	CircuitBreakerCooldown            int
+	CircuitBreaker                    *circuitbreaker.CircuitBreaker
+	DrainTimeout                      int
+	DrainGracePeriod                  int
+	DrainDisableEvictionAfter         int
+	DrainPodSelector                  string
+	DrainSkipWaitForDeleteTimeout     int
+	ForceDeleteTerminatingPodsAfter   int
+	DeleteVolumeAttachments           bool
+	OutOfServiceTaintDelay            int
+	Policies                          []Policy
+	PolicyName                        string
+	TaintDisabled                     bool
+	DrainDisabled                     bool
+	TerminationDisabled               bool
+	DisruptionBudgetSelector          labels.Selector
+	PolicyMaxDisruptedNodes           int
+	PolicyMaxDisruptedNodesPercentage int
+	PolicyStore                       *PolicyStore
+	PolicyResources                   bool
+	RemediationResources              bool
+	DynamicClient                     dynamic.Interface
+	HealthChecker                     healthcheck.HEALTHCHECKER
+	NodeLeases                        []healthcheck.LeaseSource
+	NodeLeasesMode                    string
+	LeaseListers                      healthcheck.LeaseListers
+	Workers                           int
+	RollbackTermination               bool
+	MaintenanceWindows                []maintenance.Window
+	TerminationMaxAttempts            int
+	TerminationRetryBackoff           int
+	TerminationFailedTimeout          int
 }
 
 func GetConfig() (*Config, error) {
 	ret := Config{}
-	ret.InformerResync = 60 * time.Second
+	ret.InformerResync = time.Duration(viper.GetInt(flags.InformerResyncFlag)) * time.Second
 	ret.DrainDelay = viper.GetInt(flags.DrainDelayFlag)
 	ret.CloudTerminationDelay = viper.GetInt(flags.CloudTerminationDelayFlag)
 	ret.CloudPrepareTerminationDelay = viper.GetInt(flags.CloudPrepareTerminationDelayFlag)
@@ -47,6 +94,29 @@ func GetConfig() (*Config, error) {
 	ret.NodeLeaseNamespace = viper.GetString(flags.NodeLeaseNamespaceFlag)
 	ret.InitialDelay = viper.GetInt(flags.InitialDelayFlag)
 	ret.StartupTime = time.Now()
+	ret.DryRun = viper.GetBool(flags.DryRunFlag)
+	ret.MaxDisruptedNodes = viper.GetInt(flags.MaxDisruptedNodesFlag)
+	ret.MaxDisruptedNodesPercentage = viper.GetInt(flags.MaxDisruptedNodesPercentageFlag)
+	ret.CircuitBreakerThreshold = viper.GetInt(flags.CircuitBreakerThresholdFlag)
+	ret.CircuitBreakerWindow = viper.GetInt(flags.CircuitBreakerWindowFlag)
+	ret.CircuitBreakerCooldown = viper.GetInt(flags.CircuitBreakerCooldownFlag)
+	ret.DrainTimeout = viper.GetInt(flags.DrainTimeoutFlag)
+	ret.DrainGracePeriod = viper.GetInt(flags.DrainGracePeriodFlag)
+	ret.DrainDisableEvictionAfter = viper.GetInt(flags.DrainDisableEvictionAfterFlag)
+	ret.DrainPodSelector = viper.GetString(flags.DrainPodSelectorFlag)
+	ret.DrainSkipWaitForDeleteTimeout = viper.GetInt(flags.DrainSkipWaitForDeleteFlag)
+	ret.ForceDeleteTerminatingPodsAfter = viper.GetInt(flags.ForceDeleteTerminatingPodsAfterFlag)
+	ret.DeleteVolumeAttachments = viper.GetBool(flags.DeleteVolumeAttachmentsFlag)
+	ret.OutOfServiceTaintDelay = viper.GetInt(flags.OutOfServiceTaintDelayFlag)
+	ret.PolicyResources = viper.GetBool(flags.PolicyResourcesFlag)
+	ret.RemediationResources = viper.GetBool(flags.RemediationResourcesFlag)
+	ret.PolicyStore = NewPolicyStore()
+	ret.Workers = viper.GetInt(flags.WorkersFlag)
+	ret.RollbackTermination = viper.GetBool(flags.RollbackTerminationFlag)
+	ret.NodeLeasesMode = viper.GetString(flags.NodeLeasesModeFlag)
+	ret.TerminationMaxAttempts = viper.GetInt(flags.TerminationMaxAttemptsFlag)
+	ret.TerminationRetryBackoff = viper.GetInt(flags.TerminationRetryBackoffFlag)
+	ret.TerminationFailedTimeout = viper.GetInt(flags.TerminationFailedTimeoutFlag)
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -68,7 +138,117 @@ func GetConfig() (*Config, error) {
 		ret.NotificationsSlackWebhook = webhook
 	}
 
-	return &ret, validateConfig(&ret)
+	if viper.GetString(flags.PoliciesFileFlag) != "" {
+		policies, err := LoadPolicies(viper.GetString(flags.PoliciesFileFlag))
+		if err != nil {
+			return nil, err
+		}
+		ret.Policies = policies
+	}
+
+	err = validateConfig(&ret)
+	if err != nil {
+		return &ret, err
+	}
+	err = validatePolicies(&ret)
+	if err != nil {
+		return &ret, err
+	}
+
+	ret.NodeLeases, err = healthcheck.ParseLeaseSources(viper.GetString(flags.NodeLeasesFlag))
+	if err != nil {
+		return &ret, fmt.Errorf("%s: %w", flags.NodeLeasesFlag, err)
+	}
+
+	ret.MaintenanceWindows, err = maintenance.ParseWindows(viper.GetString(flags.MaintenanceWindowsFlag))
+	if err != nil {
+		return &ret, fmt.Errorf("%s: %w", flags.MaintenanceWindowsFlag, err)
+	}
+
+	healthChecker, err := getHealthChecker()
+	if err != nil {
+		return &ret, err
+	}
+	ret.HealthChecker = healthChecker
+
+	if ret.CircuitBreakerThreshold > 0 {
+		ret.CircuitBreaker = circuitbreaker.New(
+			ret.CircuitBreakerThreshold,
+			time.Duration(ret.CircuitBreakerWindow)*time.Second,
+			time.Duration(ret.CircuitBreakerCooldown)*time.Second,
+		)
+		if ret.DryRun {
+			ret.CircuitBreaker.SetDryRun()
+		}
+	}
+
+	return &ret, nil
+}
+
+// getHealthChecker returns health checker built from flags. Returns nil when only lease is checked - node's lease is checked directly then
+func getHealthChecker() (healthcheck.HEALTHCHECKER, error) {
+	checks := strings.TrimSpace(viper.GetString(flags.HealthChecksFlag))
+	if checks == "" || checks == healthcheck.LeaseCheck {
+		return nil, nil
+	}
+	checker, err := healthcheck.New(healthcheck.Options{
+		Mode:               viper.GetString(flags.HealthChecksModeFlag),
+		Quorum:             viper.GetInt(flags.HealthChecksQuorumFlag),
+		Checks:             strings.Split(checks, ","),
+		Conditions:         viper.GetString(flags.UnhealthyConditionsFlag),
+		ConditionThreshold: viper.GetInt(flags.UnhealthyConditionsThresholdFlag),
+		HTTP: healthcheck.HTTPOptions{
+			Port:             viper.GetInt(flags.HTTPCheckPortFlag),
+			Path:             viper.GetString(flags.HTTPCheckPathFlag),
+			Timeout:          viper.GetInt(flags.HTTPCheckTimeoutFlag),
+			FailureThreshold: viper.GetInt(flags.HTTPCheckFailureThresholdFlag),
+			Concurrency:      viper.GetInt(flags.HTTPCheckConcurrencyFlag),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flags.HealthChecksFlag, err)
+	}
+	return checker, nil
+}
+
+// LeaseSources returns namespaces containing node leases
+func (cfg *Config) LeaseSources() []healthcheck.LeaseSource {
+	if len(cfg.NodeLeases) > 0 {
+		return cfg.NodeLeases
+	}
+	return []healthcheck.LeaseSource{{Namespace: cfg.NodeLeaseNamespace}}
+}
+
+// WatchedLeaseSources returns lease sources grouped by namespace: configured ones, ones overridden in policies
+// (from policies file and NodeRemediationPolicy resources loaded so far) and ones used by health checks
+func (cfg *Config) WatchedLeaseSources() map[string][]healthcheck.LeaseSource {
+	ret := make(map[string][]healthcheck.LeaseSource)
+	add := func(source healthcheck.LeaseSource) {
+		for _, existing := range ret[source.Namespace] {
+			if existing.NameTemplate == nil && source.NameTemplate == nil {
+				return
+			}
+		}
+		ret[source.Namespace] = append(ret[source.Namespace], source)
+	}
+	for _, source := range cfg.LeaseSources() {
+		add(source)
+	}
+	policies := cfg.Policies
+	if cfg.PolicyStore != nil {
+		policies = append(append([]Policy{}, cfg.Policies...), cfg.PolicyStore.Get()...)
+	}
+	for i := range policies {
+		if policies[i].NodeLeaseNamespace != nil {
+			add(healthcheck.LeaseSource{Namespace: *policies[i].NodeLeaseNamespace})
+		}
+	}
+	if cfg.HealthChecker != nil {
+		for _, namespace := range healthcheck.LeaseNamespaces(cfg.HealthChecker) {
+			add(healthcheck.LeaseSource{Namespace: namespace})
+		}
+	}
+	return ret
 }
 
 func (cfg *Config) SetK8sClient(k8sClient kubernetes.Interface, namespace string) {
@@ -85,6 +265,10 @@ func (cfg *Config) SetK8sClient(k8sClient kubernetes.Interface, namespace string
 		log.Infof("Using autodetected namespace for node leases: %s", namespace)
 		cfg.NodeLeaseNamespace = namespace
 	}
+	// in dry-run mode open circuit is kept only in memory
+	if cfg.CircuitBreaker != nil && !cfg.DryRun {
+		cfg.CircuitBreaker.SetLease(k8sClient, cfg.LeaseLockNamespace, cfg.LeaseLockName+CircuitBreakerLeaseSuffix)
+	}
 }
 
 func validateConfig(cfg *Config) error {
@@ -108,6 +292,66 @@ func validateConfig(cfg *Config) error {
 	if cfg.InitialDelay < 0 {
 		return fmt.Errorf("%s can't be lower than zero", flags.InitialDelayFlag)
 	}
+	if cfg.MaxDisruptedNodes < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.MaxDisruptedNodesFlag)
+	}
+	if cfg.MaxDisruptedNodesPercentage < 0 || cfg.MaxDisruptedNodesPercentage > 100 {
+		return fmt.Errorf("%s has to be between 0 and 100", flags.MaxDisruptedNodesPercentageFlag)
+	}
+	if cfg.PolicyMaxDisruptedNodes < 0 {
+		return fmt.Errorf("maxDisruptedNodes can't be lower than zero")
+	}
+	if cfg.PolicyMaxDisruptedNodesPercentage < 0 || cfg.PolicyMaxDisruptedNodesPercentage > 100 {
+		return fmt.Errorf("maxDisruptedNodesPercentage has to be between 0 and 100")
+	}
+	if cfg.CircuitBreakerThreshold < 0 || cfg.CircuitBreakerThreshold > 100 {
+		return fmt.Errorf("%s has to be between 0 and 100", flags.CircuitBreakerThresholdFlag)
+	}
+	if cfg.CircuitBreakerThreshold > 0 && cfg.CircuitBreakerWindow <= 0 {
+		return fmt.Errorf("%s has to be greater than zero", flags.CircuitBreakerWindowFlag)
+	}
+	if cfg.CircuitBreakerCooldown < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.CircuitBreakerCooldownFlag)
+	}
+	if cfg.DrainTimeout < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.DrainTimeoutFlag)
+	}
+	if cfg.DrainGracePeriod < -1 {
+		return fmt.Errorf("%s can't be lower than -1", flags.DrainGracePeriodFlag)
+	}
+	if cfg.DrainDisableEvictionAfter < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.DrainDisableEvictionAfterFlag)
+	}
+	if _, err := labels.Parse(cfg.DrainPodSelector); err != nil {
+		return fmt.Errorf("%s is not a valid label selector: %v", flags.DrainPodSelectorFlag, err)
+	}
+	if cfg.DrainSkipWaitForDeleteTimeout < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.DrainSkipWaitForDeleteFlag)
+	}
+	if cfg.ForceDeleteTerminatingPodsAfter < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.ForceDeleteTerminatingPodsAfterFlag)
+	}
+	if cfg.OutOfServiceTaintDelay < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.OutOfServiceTaintDelayFlag)
+	}
+	if cfg.InformerResync < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.InformerResyncFlag)
+	}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.WorkersFlag)
+	}
+	if cfg.TerminationMaxAttempts < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.TerminationMaxAttemptsFlag)
+	}
+	if cfg.TerminationRetryBackoff < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.TerminationRetryBackoffFlag)
+	}
+	if cfg.TerminationFailedTimeout < 0 {
+		return fmt.Errorf("%s can't be lower than zero", flags.TerminationFailedTimeoutFlag)
+	}
+	if cfg.NodeLeasesMode != "" && cfg.NodeLeasesMode != healthcheck.LeasesModeAll && cfg.NodeLeasesMode != healthcheck.LeasesModeAny {
+		return fmt.Errorf("%s has to be one of: all, any", flags.NodeLeasesModeFlag)
+	}
 
 	return nil
 }
@@ -1,14 +1,20 @@
 package config
 
 import (
+	"context"
 	"github.com/dbschenker/node-undertaker/cmd/node-undertaker/flags"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/circuitbreaker"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/client-go/kubernetes/fake"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetConfigNegativeValidation(t *testing.T) {
@@ -39,12 +45,13 @@ func TestGetConfigOk(t *testing.T) {
 	viper.Set(flags.NamespaceFlag, namespace)
 	viper.Set(flags.LeaseLockNameFlag, leaseLockName)
 	viper.Set(flags.NotificationsSlackWebhookFlag, webhook)
+	viper.Set(flags.InformerResyncFlag, 30)
 
 	ret, err := GetConfig()
 
 	assert.NoError(t, err)
 	assert.NotNil(t, ret)
-	assert.Positive(t, ret.InformerResync)
+	assert.Equal(t, 30*time.Second, ret.InformerResync)
 	assert.Equal(t, portValue, ret.Port)
 	assert.Equal(t, hostname, ret.Hostname)
 	assert.Equal(t, leaseLockName, ret.LeaseLockName)
@@ -222,3 +229,223 @@ func TestSetK8sClient1(t *testing.T) {
 	assert.Equal(t, nodeLeaseNs, cfg.NodeLeaseNamespace)
 	assert.Equal(t, client, cfg.K8sClient)
 }
+
+func TestSetK8sClientCircuitBreakerLease(t *testing.T) {
+	client := fake.NewClientset()
+	cfg := Config{
+		LeaseLockName:  "lease-lock",
+		CircuitBreaker: circuitbreaker.New(10, time.Minute, 0),
+	}
+
+	cfg.SetK8sClient(client, "test")
+	assert.True(t, cfg.CircuitBreaker.RecordStale(context.TODO(), "node1", time.Now(), 2))
+	_, err := client.CoordinationV1().Leases("test").Get(context.TODO(), "lease-lock"+CircuitBreakerLeaseSuffix, metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestSetK8sClientCircuitBreakerDryRun(t *testing.T) {
+	client := fake.NewClientset()
+	cfg := Config{
+		LeaseLockName:  "lease-lock",
+		DryRun:         true,
+		CircuitBreaker: circuitbreaker.New(10, time.Minute, 0),
+	}
+
+	cfg.SetK8sClient(client, "test")
+	assert.True(t, cfg.CircuitBreaker.RecordStale(context.TODO(), "node1", time.Now(), 2))
+	_, err := client.CoordinationV1().Leases("test").Get(context.TODO(), "lease-lock"+CircuitBreakerLeaseSuffix, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.True(t, cfg.CircuitBreaker.IsOpen(context.TODO(), time.Now()))
+}
+
+func TestValidateConfigErrMaxDisruptedNodes(t *testing.T) {
+	cfg := &Config{
+		DrainDelay:            1,
+		CloudTerminationDelay: 1,
+		Port:                  8080,
+		LeaseLockName:         "test",
+		MaxDisruptedNodes:     -1,
+	}
+	err := validateConfig(cfg)
+	assert.Error(t, err)
+}
+
+func TestValidateConfigErrMaxDisruptedNodesPercentage(t *testing.T) {
+	cfg := &Config{
+		DrainDelay:                  1,
+		CloudTerminationDelay:       1,
+		Port:                        8080,
+		LeaseLockName:               "test",
+		MaxDisruptedNodesPercentage: 101,
+	}
+	err := validateConfig(cfg)
+	assert.Error(t, err)
+}
+
+func TestValidateConfigErrCircuitBreakerThreshold(t *testing.T) {
+	cfg := &Config{
+		LeaseLockName:           "test",
+		CircuitBreakerThreshold: 101,
+		CircuitBreakerWindow:    60,
+	}
+	err := validateConfig(cfg)
+	assert.Error(t, err)
+}
+
+func TestValidateConfigErrCircuitBreakerWindow(t *testing.T) {
+	cfg := &Config{
+		LeaseLockName:           "test",
+		CircuitBreakerThreshold: 50,
+		CircuitBreakerWindow:    0,
+	}
+	err := validateConfig(cfg)
+	assert.Error(t, err)
+}
+
+func TestGetConfigCircuitBreaker(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.CircuitBreakerThresholdFlag, 30)
+	viper.Set(flags.CircuitBreakerWindowFlag, 60)
+
+	cfg, err := GetConfig()
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.CircuitBreaker)
+
+	viper.Reset()
+}
+
+func TestGetConfigMaintenanceWindows(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.MaintenanceWindowsFlag, "0 22 * * 5 60h;0 2 * * 1-4 2h")
+
+	cfg, err := GetConfig()
+	assert.NoError(t, err)
+	assert.Len(t, cfg.MaintenanceWindows, 2)
+
+	viper.Set(flags.MaintenanceWindowsFlag, "0 22 * * 5")
+	_, err = GetConfig()
+	assert.ErrorContains(t, err, flags.MaintenanceWindowsFlag)
+
+	viper.Reset()
+}
+
+func TestValidateConfigErrDrainOptions(t *testing.T) {
+	cases := map[string]Config{
+		"timeout":          {LeaseLockName: "test", DrainTimeout: -1},
+		"grace period":     {LeaseLockName: "test", DrainGracePeriod: -2},
+		"disable eviction": {LeaseLockName: "test", DrainDisableEvictionAfter: -1},
+		"pod selector":     {LeaseLockName: "test", DrainPodSelector: "app in (a"},
+		"skip wait":        {LeaseLockName: "test", DrainSkipWaitForDeleteTimeout: -1},
+		"force delete":     {LeaseLockName: "test", ForceDeleteTerminatingPodsAfter: -1},
+		"out of service":   {LeaseLockName: "test", OutOfServiceTaintDelay: -1},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateConfig(&cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidateConfigErrTerminationRetries(t *testing.T) {
+	cases := map[string]Config{
+		"max attempts":   {LeaseLockName: "test", TerminationMaxAttempts: -1},
+		"retry backoff":  {LeaseLockName: "test", TerminationRetryBackoff: -1},
+		"failed timeout": {LeaseLockName: "test", TerminationFailedTimeout: -1},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateConfig(&cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetConfigDrainOptions(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.DrainTimeoutFlag, 120)
+	viper.Set(flags.DrainGracePeriodFlag, 30)
+	viper.Set(flags.DrainDisableEvictionAfterFlag, 3)
+	viper.Set(flags.DrainPodSelectorFlag, "app!=critical")
+	viper.Set(flags.DrainSkipWaitForDeleteFlag, 60)
+
+	cfg, err := GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 120, cfg.DrainTimeout)
+	assert.Equal(t, 30, cfg.DrainGracePeriod)
+	assert.Equal(t, 3, cfg.DrainDisableEvictionAfter)
+	assert.Equal(t, "app!=critical", cfg.DrainPodSelector)
+	assert.Equal(t, 60, cfg.DrainSkipWaitForDeleteTimeout)
+
+	viper.Reset()
+}
+
+func TestLeaseSources(t *testing.T) {
+	cfg := Config{NodeLeaseNamespace: "kube-node-lease"}
+	assert.Equal(t, []healthcheck.LeaseSource{{Namespace: "kube-node-lease"}}, cfg.LeaseSources())
+
+	cfg.NodeLeases = []healthcheck.LeaseSource{{Namespace: "ns1"}, {Namespace: "ns2"}}
+	assert.Equal(t, cfg.NodeLeases, cfg.LeaseSources())
+}
+
+func TestValidateConfigErrNodeLeasesMode(t *testing.T) {
+	cfg := &Config{
+		DrainDelay:            1,
+		CloudTerminationDelay: 1,
+		Port:                  8080,
+		LeaseLockName:         "test",
+		NodeLeasesMode:        "some",
+	}
+	err := validateConfig(cfg)
+	assert.Error(t, err)
+}
+
+func TestGetConfigNodeLeasesMode(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.NodeLeasesModeFlag, healthcheck.LeasesModeAny)
+	cfg, err := GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, healthcheck.LeasesModeAny, cfg.NodeLeasesMode)
+
+	viper.Set(flags.NodeLeasesModeFlag, "al")
+	_, err = GetConfig()
+	assert.ErrorContains(t, err, flags.NodeLeasesModeFlag)
+
+	viper.Reset()
+}
+
+func TestWatchedLeaseSources(t *testing.T) {
+	policyNamespace := "policy-leases"
+	sources, err := healthcheck.ParseLeaseSources("kube-node-lease,apps:{{.NodeName}}-app")
+	assert.NoError(t, err)
+	checker, err := healthcheck.New(healthcheck.Options{Mode: healthcheck.ModeAny, Checks: []string{healthcheck.LeaseCheck, healthcheck.KubeletLeaseCheck}})
+	assert.NoError(t, err)
+	cfg := Config{
+		NodeLeases:    sources,
+		Policies:      []Policy{{Name: "p1", NodeLeaseNamespace: &policyNamespace}},
+		HealthChecker: checker,
+	}
+
+	ret := cfg.WatchedLeaseSources()
+	assert.Len(t, ret, 3)
+	assert.Len(t, ret["kube-node-lease"], 1)
+	assert.Equal(t, sources[1:], ret["apps"])
+	assert.Equal(t, []healthcheck.LeaseSource{{Namespace: policyNamespace}}, ret[policyNamespace])
+}
+
+func TestWatchedLeaseSourcesPolicyResources(t *testing.T) {
+	fileNamespace := "file-leases"
+	resourceNamespace := "resource-leases"
+	cfg := Config{
+		NodeLeaseNamespace: "kube-node-lease",
+		Policies:           []Policy{{Name: "p1", NodeLeaseNamespace: &fileNamespace}},
+		PolicyStore:        NewPolicyStore(),
+	}
+	cfg.PolicyStore.Set([]Policy{{Name: "p2", NodeLeaseNamespace: &resourceNamespace}})
+
+	ret := cfg.WatchedLeaseSources()
+	assert.Len(t, ret, 3)
+	assert.Contains(t, ret, fileNamespace)
+	assert.Contains(t, ret, resourceNamespace)
+	assert.Len(t, cfg.Policies, 1)
+}
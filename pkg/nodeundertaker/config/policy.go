@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/labels"
+	"net/url"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"sync"
+)
+
+const (
+	ActionTaint     = "taint"
+	ActionDrain     = "drain"
+	ActionTerminate = "terminate"
+)
+
+// Policy overrides delays, drain options and enabled actions for nodes matching its node selector.
+// Fields that are not set are taken from global configuration.
+type Policy struct {
+	Name                              string   `json:"name"`
+	NodeSelector                      string   `json:"nodeSelector"`
+	NodeInitialThreshold              *int     `json:"nodeInitialThreshold,omitempty"`
+	DrainDelay                        *int     `json:"drainDelay,omitempty"`
+	CloudPrepareTerminationDelay      *int     `json:"cloudPrepareTerminationDelay,omitempty"`
+	CloudTerminationDelay             *int     `json:"cloudTerminationDelay,omitempty"`
+	DrainTimeout                      *int     `json:"drainTimeout,omitempty"`
+	DrainGracePeriod                  *int     `json:"drainGracePeriod,omitempty"`
+	DrainDisableEvictionAfterAttempts *int     `json:"drainDisableEvictionAfterAttempts,omitempty"`
+	DrainPodSelector                  *string  `json:"drainPodSelector,omitempty"`
+	DrainSkipWaitForDeleteTimeout     *int     `json:"drainSkipWaitForDeleteTimeout,omitempty"`
+	DisabledActions                   []string `json:"disabledActions,omitempty"`
+	NodeLeaseNamespace                *string  `json:"nodeLeaseNamespace,omitempty"`
+	NotificationsSlackWebhook         *string  `json:"notificationsSlackWebhook,omitempty"`
+	MaxDisruptedNodes                 *int     `json:"maxDisruptedNodes,omitempty"`
+	MaxDisruptedNodesPercentage       *int     `json:"maxDisruptedNodesPercentage,omitempty"`
+	// Selector is parsed NodeSelector
+	Selector labels.Selector `json:"-"`
+	// SlackWebhook is parsed NotificationsSlackWebhook
+	SlackWebhook *url.URL `json:"-"`
+}
+
+// PolicyStore keeps policies that can change while node-undertaker is running (from NodeRemediationPolicy resources)
+type PolicyStore struct {
+	mutex    sync.RWMutex
+	policies []Policy
+	// leaseNamespaces are namespaces of leases watched since node-undertaker started. Nil until leases are watched
+	leaseNamespaces map[string]bool
+}
+
+func NewPolicyStore() *PolicyStore {
+	return &PolicyStore{}
+}
+
+// Set replaces stored policies
+func (s *PolicyStore) Set(policies []Policy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.policies = policies
+}
+
+// Get returns stored policies. Returned slice must not be modified
+func (s *PolicyStore) Get() []Policy {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.policies
+}
+
+// SetLeaseNamespaces stores namespaces of leases that are watched. Lease informers are started once,
+// so policies added later can't use other lease namespaces
+func (s *PolicyStore) SetLeaseNamespaces(namespaces []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.leaseNamespaces = make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		s.leaseNamespaces[namespace] = true
+	}
+}
+
+// IsLeaseNamespaceWatched returns true if leases in namespace are watched or leases aren't watched yet -
+// namespaces of policies loaded before are watched too
+func (s *PolicyStore) IsLeaseNamespaceWatched(namespace string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.leaseNamespaces == nil || s.leaseNamespaces[namespace]
+}
+
+// LoadPolicies reads list of policies from yaml file
+func LoadPolicies(path string) ([]Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]Policy, 0)
+	err = yaml.UnmarshalStrict(data, &policies)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse policies file %s: %w", path, err)
+	}
+	return policies, nil
+}
+
+// HasPolicies returns true if there is at least one policy configured
+func (cfg *Config) HasPolicies() bool {
+	return len(cfg.Policies) > 0 || (cfg.PolicyStore != nil && len(cfg.PolicyStore.Get()) > 0)
+}
+
+// ForNode returns configuration for the node with labels - global configuration with overrides of the first matching policy.
+// Policies from policies file are checked before policies from NodeRemediationPolicy resources
+func (cfg *Config) ForNode(nodeLabels map[string]string) *Config {
+	if policy := findPolicy(cfg.Policies, nodeLabels); policy != nil {
+		return cfg.withPolicy(policy)
+	}
+	if cfg.PolicyStore != nil {
+		if policy := findPolicy(cfg.PolicyStore.Get(), nodeLabels); policy != nil {
+			return cfg.withPolicy(policy)
+		}
+	}
+	return cfg
+}
+
+func findPolicy(policies []Policy, nodeLabels map[string]string) *Policy {
+	for i := range policies {
+		if policies[i].Selector != nil && policies[i].Selector.Matches(labels.Set(nodeLabels)) {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+// withPolicy returns copy of configuration with policy overrides applied
+func (cfg *Config) withPolicy(policy *Policy) *Config {
+	ret := *cfg
+	ret.Policies = nil
+	ret.PolicyStore = nil
+	ret.PolicyName = policy.Name
+	if policy.NodeInitialThreshold != nil {
+		ret.NodeInitialThreshold = *policy.NodeInitialThreshold
+	}
+	if policy.DrainDelay != nil {
+		ret.DrainDelay = *policy.DrainDelay
+	}
+	if policy.CloudPrepareTerminationDelay != nil {
+		ret.CloudPrepareTerminationDelay = *policy.CloudPrepareTerminationDelay
+	}
+	if policy.CloudTerminationDelay != nil {
+		ret.CloudTerminationDelay = *policy.CloudTerminationDelay
+	}
+	if policy.DrainTimeout != nil {
+		ret.DrainTimeout = *policy.DrainTimeout
+	}
+	if policy.DrainGracePeriod != nil {
+		ret.DrainGracePeriod = *policy.DrainGracePeriod
+	}
+	if policy.DrainDisableEvictionAfterAttempts != nil {
+		ret.DrainDisableEvictionAfter = *policy.DrainDisableEvictionAfterAttempts
+	}
+	if policy.DrainPodSelector != nil {
+		ret.DrainPodSelector = *policy.DrainPodSelector
+	}
+	if policy.DrainSkipWaitForDeleteTimeout != nil {
+		ret.DrainSkipWaitForDeleteTimeout = *policy.DrainSkipWaitForDeleteTimeout
+	}
+	if policy.NodeLeaseNamespace != nil {
+		ret.NodeLeaseNamespace = *policy.NodeLeaseNamespace
+		ret.NodeLeases = nil
+	}
+	if policy.SlackWebhook != nil {
+		ret.NotificationsSlackWebhook = policy.SlackWebhook
+	}
+	if policy.MaxDisruptedNodes != nil || policy.MaxDisruptedNodesPercentage != nil {
+		// budget of the policy is counted only for nodes matching it and is checked in addition to the global budget
+		ret.DisruptionBudgetSelector = policy.Selector
+	}
+	if policy.MaxDisruptedNodes != nil {
+		ret.PolicyMaxDisruptedNodes = *policy.MaxDisruptedNodes
+	}
+	if policy.MaxDisruptedNodesPercentage != nil {
+		ret.PolicyMaxDisruptedNodesPercentage = *policy.MaxDisruptedNodesPercentage
+	}
+	for _, action := range policy.DisabledActions {
+		switch action {
+		case ActionTaint:
+			ret.TaintDisabled = true
+		case ActionDrain:
+			ret.DrainDisabled = true
+		case ActionTerminate:
+			ret.TerminationDisabled = true
+		}
+	}
+	return &ret
+}
+
+// validatePolicies checks that names of policies are unique and validates each of them
+func validatePolicies(cfg *Config) error {
+	names := make(map[string]bool)
+	for i := range cfg.Policies {
+		policy := &cfg.Policies[i]
+		if names[policy.Name] {
+			return fmt.Errorf("policy %s: name is not unique", policy.Name)
+		}
+		names[policy.Name] = true
+
+		err := ValidatePolicy(cfg, policy)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidatePolicy parses node selector and webhook of the policy and validates configuration resulting from it
+func ValidatePolicy(cfg *Config, policy *Policy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("policy name can't be empty")
+	}
+
+	// empty selector would match all the nodes and silently replace the global configuration
+	if strings.TrimSpace(policy.NodeSelector) == "" {
+		return fmt.Errorf("policy %s: node selector can't be empty", policy.Name)
+	}
+	selector, err := labels.Parse(policy.NodeSelector)
+	if err != nil {
+		return fmt.Errorf("policy %s: node selector is not valid: %w", policy.Name, err)
+	}
+	policy.Selector = selector
+
+	if policy.NotificationsSlackWebhook != nil && *policy.NotificationsSlackWebhook != "" {
+		webhook, err := url.Parse(*policy.NotificationsSlackWebhook)
+		if err != nil {
+			return fmt.Errorf("policy %s: notifications slack webhook is not valid: %w", policy.Name, err)
+		}
+		policy.SlackWebhook = webhook
+	}
+
+	for _, action := range policy.DisabledActions {
+		if action != ActionTaint && action != ActionDrain && action != ActionTerminate {
+			return fmt.Errorf("policy %s: unknown action: %s", policy.Name, action)
+		}
+	}
+
+	err = validateConfig(cfg.withPolicy(policy))
+	if err != nil {
+		return fmt.Errorf("policy %s: %w", policy.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"github.com/dbschenker/node-undertaker/cmd/node-undertaker/flags"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicies = `
+- name: system
+  nodeSelector: node-role=system
+  disabledActions:
+    - drain
+    - terminate
+- name: spot
+  nodeSelector: capacity-type=spot
+  drainDelay: 10
+  cloudPrepareTerminationDelay: 60
+  cloudTerminationDelay: 0
+  drainGracePeriod: 30
+  drainPodSelector: app!=critical
+`
+
+func writePoliciesFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadPolicies(t *testing.T) {
+	policies, err := LoadPolicies(writePoliciesFile(t, testPolicies))
+	assert.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "system", policies[0].Name)
+	assert.Equal(t, []string{ActionDrain, ActionTerminate}, policies[0].DisabledActions)
+	assert.Nil(t, policies[0].DrainDelay)
+	assert.Equal(t, 10, *policies[1].DrainDelay)
+	assert.Equal(t, "app!=critical", *policies[1].DrainPodSelector)
+}
+
+func TestLoadPoliciesUnknownField(t *testing.T) {
+	_, err := LoadPolicies(writePoliciesFile(t, "- name: test\n  drainDelayy: 10\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadPoliciesMissingFile(t *testing.T) {
+	_, err := LoadPolicies(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestForNode(t *testing.T) {
+	policies, err := LoadPolicies(writePoliciesFile(t, testPolicies))
+	require.NoError(t, err)
+	cfg := &Config{
+		LeaseLockName:                "test",
+		DrainDelay:                   300,
+		CloudPrepareTerminationDelay: 300,
+		CloudTerminationDelay:        300,
+		DrainGracePeriod:             -1,
+		Policies:                     policies,
+	}
+	require.NoError(t, validatePolicies(cfg))
+
+	ret := cfg.ForNode(map[string]string{"node-role": "worker"})
+	assert.Same(t, cfg, ret)
+
+	ret = cfg.ForNode(map[string]string{"node-role": "system"})
+	assert.Equal(t, "system", ret.PolicyName)
+	assert.False(t, ret.TaintDisabled)
+	assert.True(t, ret.DrainDisabled)
+	assert.True(t, ret.TerminationDisabled)
+	assert.Equal(t, 300, ret.DrainDelay)
+	assert.Nil(t, ret.Policies)
+
+	ret = cfg.ForNode(map[string]string{"capacity-type": "spot"})
+	assert.Equal(t, "spot", ret.PolicyName)
+	assert.Equal(t, 10, ret.DrainDelay)
+	assert.Equal(t, 60, ret.CloudPrepareTerminationDelay)
+	assert.Equal(t, 0, ret.CloudTerminationDelay)
+	assert.Equal(t, 30, ret.DrainGracePeriod)
+	assert.Equal(t, "app!=critical", ret.DrainPodSelector)
+	assert.False(t, ret.DrainDisabled)
+
+	// global config is not modified
+	assert.Equal(t, 300, cfg.DrainDelay)
+	assert.Empty(t, cfg.PolicyName)
+}
+
+func TestForNodePolicyStore(t *testing.T) {
+	leaseNamespace := "spot-leases"
+	webhook := "https://hooks.example.com/spot"
+	budget := 5
+	cfg := &Config{
+		LeaseLockName:               "test",
+		NodeLeaseNamespace:          "kube-node-lease",
+		MaxDisruptedNodesPercentage: 10,
+		DrainGracePeriod:            -1,
+		Policies:                    []Policy{{Name: "system", NodeSelector: "node-role=system"}},
+		PolicyStore:                 NewPolicyStore(),
+	}
+	require.NoError(t, validatePolicies(cfg))
+	assert.True(t, cfg.HasPolicies())
+
+	policies := []Policy{
+		{Name: "spot", NodeSelector: "capacity-type=spot", NodeLeaseNamespace: &leaseNamespace, NotificationsSlackWebhook: &webhook, MaxDisruptedNodes: &budget},
+		{Name: "system-spot", NodeSelector: "node-role=system"},
+	}
+	for i := range policies {
+		require.NoError(t, ValidatePolicy(cfg, &policies[i]))
+	}
+	cfg.PolicyStore.Set(policies)
+
+	// policies from file take precedence
+	assert.Equal(t, "system", cfg.ForNode(map[string]string{"node-role": "system", "capacity-type": "spot"}).PolicyName)
+
+	ret := cfg.ForNode(map[string]string{"capacity-type": "spot"})
+	assert.Equal(t, "spot", ret.PolicyName)
+	assert.Equal(t, leaseNamespace, ret.NodeLeaseNamespace)
+	assert.Equal(t, webhook, ret.NotificationsSlackWebhook.String())
+	// global budget is kept - policy budget is checked in addition to it
+	assert.Equal(t, 0, ret.MaxDisruptedNodes)
+	assert.Equal(t, 10, ret.MaxDisruptedNodesPercentage)
+	assert.Equal(t, 5, ret.PolicyMaxDisruptedNodes)
+	assert.Equal(t, 0, ret.PolicyMaxDisruptedNodesPercentage)
+	assert.True(t, ret.DisruptionBudgetSelector.Matches(labels.Set{"capacity-type": "spot"}))
+	assert.Nil(t, ret.PolicyStore)
+
+	ret = cfg.ForNode(map[string]string{"capacity-type": "on-demand"})
+	assert.Same(t, cfg, ret)
+	assert.Nil(t, ret.DisruptionBudgetSelector)
+}
+
+func TestValidatePoliciesErr(t *testing.T) {
+	negative := -1
+	tooHigh := 101
+	invalidSelector := "app in (a"
+	cases := map[string][]Policy{
+		"empty name":        {{NodeSelector: "a=b"}},
+		"duplicated name":   {{Name: "a", NodeSelector: "a=b"}, {Name: "a", NodeSelector: "a=c"}},
+		"node selector":     {{Name: "a", NodeSelector: "a in (b"}},
+		"empty selector":    {{Name: "a", NodeSelector: " "}},
+		"unknown action":    {{Name: "a", NodeSelector: "a=b", DisabledActions: []string{"reboot"}}},
+		"negative delay":    {{Name: "a", NodeSelector: "a=b", DrainDelay: &negative}},
+		"drain selector":    {{Name: "a", NodeSelector: "a=b", DrainPodSelector: &invalidSelector}},
+		"negative timeout":  {{Name: "a", NodeSelector: "a=b", DrainTimeout: &negative}},
+		"negative budget":   {{Name: "a", NodeSelector: "a=b", MaxDisruptedNodes: &negative}},
+		"budget percentage": {{Name: "a", NodeSelector: "a=b", MaxDisruptedNodesPercentage: &tooHigh}},
+	}
+	for name, policies := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{LeaseLockName: "test", Policies: policies}
+			assert.Error(t, validatePolicies(cfg))
+		})
+	}
+}
+
+func TestGetConfigPolicies(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.PoliciesFileFlag, writePoliciesFile(t, testPolicies))
+
+	cfg, err := GetConfig()
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Policies, 2)
+	assert.Equal(t, "spot", cfg.ForNode(map[string]string{"capacity-type": "spot"}).PolicyName)
+
+	viper.Reset()
+}
+
+func TestGetConfigPoliciesInvalid(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "some-value")
+	viper.Set(flags.PoliciesFileFlag, writePoliciesFile(t, "- name: test\n  nodeSelector: a=b\n  drainDelay: -5\n"))
+
+	_, err := GetConfig()
+	assert.Error(t, err)
+
+	viper.Reset()
+}
+
+func TestPolicyStoreIsLeaseNamespaceWatched(t *testing.T) {
+	store := NewPolicyStore()
+	assert.True(t, store.IsLeaseNamespaceWatched("spot-leases"))
+
+	store.SetLeaseNamespaces([]string{"kube-node-lease", "spot-leases"})
+	assert.True(t, store.IsLeaseNamespaceWatched("spot-leases"))
+	assert.False(t, store.IsLeaseNamespaceWatched("gpu-leases"))
+}
@@ -0,0 +1,65 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+// ConditionChecker checks if any of unhealthy conditions holds longer than threshold
+type ConditionChecker struct {
+	conditions []v1.NodeCondition
+	threshold  time.Duration
+}
+
+// NewConditionChecker creates checker from comma separated list of conditions in Type=Status format, e.g. Ready=False,DiskPressure=True
+func NewConditionChecker(conditions string, thresholdSeconds int) (*ConditionChecker, error) {
+	ret := ConditionChecker{threshold: time.Duration(thresholdSeconds) * time.Second}
+	for _, condition := range strings.Split(conditions, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+		parts := strings.Split(condition, "=")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("condition %s is not in Type=Status format", condition)
+		}
+		status := v1.ConditionStatus(parts[1])
+		if status != v1.ConditionTrue && status != v1.ConditionFalse && status != v1.ConditionUnknown {
+			return nil, fmt.Errorf("condition %s: status has to be one of: True, False, Unknown", condition)
+		}
+		ret.conditions = append(ret.conditions, v1.NodeCondition{Type: v1.NodeConditionType(parts[0]), Status: status})
+	}
+	if len(ret.conditions) == 0 {
+		return nil, fmt.Errorf("at least one unhealthy condition is required for condition check")
+	}
+	if thresholdSeconds < 0 {
+		return nil, fmt.Errorf("condition threshold can't be lower than zero")
+	}
+	return &ret, nil
+}
+
+func (c *ConditionChecker) Name() string {
+	return ConditionCheck
+}
+
+func (c *ConditionChecker) Check(ctx context.Context, target Target) (Result, error) {
+	failed := make([]string, 0)
+	for _, nodeCondition := range target.Node.Status.Conditions {
+		for _, unhealthy := range c.conditions {
+			if nodeCondition.Type != unhealthy.Type || nodeCondition.Status != unhealthy.Status {
+				continue
+			}
+			duration := time.Since(nodeCondition.LastTransitionTime.Time)
+			if duration >= c.threshold {
+				failed = append(failed, fmt.Sprintf("%s=%s for %s", nodeCondition.Type, nodeCondition.Status, duration.Round(time.Second)))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return Result{Check: ConditionCheck, Healthy: false, Message: strings.Join(failed, ", ")}, nil
+	}
+	return Result{Check: ConditionCheck, Healthy: true}, nil
+}
@@ -0,0 +1,67 @@
+package healthcheck
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func createConditionNode(conditions ...v1.NodeCondition) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status:     v1.NodeStatus{Conditions: conditions},
+	}
+}
+
+func TestConditionChecker(t *testing.T) {
+	checker, err := NewConditionChecker("Ready=False, Ready=Unknown,KernelDeadlock=True", 60)
+	require.NoError(t, err)
+	old := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+	recent := metav1.Now()
+
+	cases := map[string]struct {
+		node    *v1.Node
+		healthy bool
+	}{
+		"ready":              {createConditionNode(v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue, LastTransitionTime: old}), true},
+		"not ready":          {createConditionNode(v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionFalse, LastTransitionTime: old}), false},
+		"unknown":            {createConditionNode(v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionUnknown, LastTransitionTime: old}), false},
+		"not ready recently": {createConditionNode(v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionFalse, LastTransitionTime: recent}), true},
+		"kernel deadlock": {createConditionNode(
+			v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue, LastTransitionTime: old},
+			v1.NodeCondition{Type: "KernelDeadlock", Status: v1.ConditionTrue, LastTransitionTime: old},
+		), false},
+		"no conditions": {createConditionNode(), true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, err := checker.Check(context.TODO(), Target{Node: c.node})
+			assert.NoError(t, err)
+			assert.Equal(t, c.healthy, result.Healthy)
+			assert.Equal(t, ConditionCheck, result.Check)
+		})
+	}
+}
+
+func TestConditionCheckerMessage(t *testing.T) {
+	checker, err := NewConditionChecker("DiskPressure=True", 0)
+	require.NoError(t, err)
+	node := createConditionNode(v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute))})
+
+	result, err := checker.Check(context.TODO(), Target{Node: node})
+	assert.NoError(t, err)
+	assert.Contains(t, result.Message, "DiskPressure=True for 1m")
+}
+
+func TestNewConditionCheckerErr(t *testing.T) {
+	for _, conditions := range []string{"", "Ready", "=True", "Ready=Maybe", "Ready=False=True"} {
+		_, err := NewConditionChecker(conditions, 60)
+		assert.Error(t, err, conditions)
+	}
+	_, err := NewConditionChecker("Ready=False", -1)
+	assert.Error(t, err)
+}
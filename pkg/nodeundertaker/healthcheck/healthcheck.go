@@ -0,0 +1,202 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"strings"
+)
+
+//go:generate mockgen -destination=./mocks/api_mocks.go github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck HEALTHCHECKER
+
+const (
+	// ModeAny - node is unhealthy when any of the checks fails
+	ModeAny = "any"
+	// ModeAll - node is unhealthy only when all the checks fail
+	ModeAll = "all"
+	// ModeQuorum - node is unhealthy when at least quorum of the checks fail
+	ModeQuorum = "quorum"
+
+	LeaseCheck        = "lease"
+	KubeletLeaseCheck = "kubelet-lease"
+	ConditionCheck    = "condition"
+
+	// KubeletLeaseNamespace is namespace of leases renewed by kubelet
+	KubeletLeaseNamespace = "kube-node-lease"
+)
+
+// Target is the node to be checked together with access to the cluster
+type Target struct {
+	Node         *v1.Node
+	Client       kubernetes.Interface
+	LeaseSources []LeaseSource
+	// LeasesMode decides if all or any of lease sources have to contain fresh lease
+	LeasesMode string
+	// LeaseListers are used instead of Client for watched lease namespaces
+	LeaseListers LeaseListers
+}
+
+// Result of a health check
+type Result struct {
+	// Check is name of the check, for combined check - names of failed checks
+	Check   string
+	Healthy bool
+	// Message describes why the check failed
+	Message string
+	// Signals contains result of each check - only for combined check
+	Signals map[string]bool
+	// StaleLease is the first lease found not fresh - only when a lease check failed
+	StaleLease *LeaseRef
+}
+
+// Disagree returns true if some of the combined checks failed and some passed
+func (r Result) Disagree() bool {
+	failed := 0
+	for _, healthy := range r.Signals {
+		if !healthy {
+			failed++
+		}
+	}
+	return failed > 0 && failed < len(r.Signals)
+}
+
+type HEALTHCHECKER interface {
+	// Name identifies the check in events
+	Name() string
+	Check(ctx context.Context, target Target) (Result, error)
+}
+
+// Combined combines results of multiple health checks
+type Combined struct {
+	Mode string
+	// Quorum is number of checks that have to fail in quorum mode
+	Quorum int
+	Checks []HEALTHCHECKER
+}
+
+// Forget removes state kept by checks for the node, e.g. after the node was removed
+func Forget(checker HEALTHCHECKER, nodeName string) {
+	switch c := checker.(type) {
+	case *Combined:
+		for i := range c.Checks {
+			Forget(c.Checks[i], nodeName)
+		}
+	case *HTTPChecker:
+		c.Forget(nodeName)
+	}
+}
+
+func NewCombined(mode string, quorum int, checks ...HEALTHCHECKER) (*Combined, error) {
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("at least one health check is required")
+	}
+	switch mode {
+	case ModeAny, ModeAll:
+	case ModeQuorum:
+		if quorum < 1 || quorum > len(checks) {
+			return nil, fmt.Errorf("health checks quorum has to be between 1 and number of checks (%d)", len(checks))
+		}
+	default:
+		return nil, fmt.Errorf("unknown health checks mode: %s", mode)
+	}
+	names := make(map[string]bool)
+	for _, check := range checks {
+		if names[check.Name()] {
+			return nil, fmt.Errorf("health check %s is configured more than once", check.Name())
+		}
+		names[check.Name()] = true
+	}
+	return &Combined{Mode: mode, Quorum: quorum, Checks: checks}, nil
+}
+
+func (c *Combined) Name() string {
+	names := make([]string, len(c.Checks))
+	for i := range c.Checks {
+		names[i] = c.Checks[i].Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Check runs all the checks. Error of any check is returned, because the result can't be determined then
+func (c *Combined) Check(ctx context.Context, target Target) (Result, error) {
+	failedNames := make([]string, 0)
+	failedMessages := make([]string, 0)
+	signals := make(map[string]bool, len(c.Checks))
+	var staleLease *LeaseRef
+	for _, check := range c.Checks {
+		result, err := check.Check(ctx, target)
+		if err != nil {
+			return Result{Check: check.Name()}, fmt.Errorf("%s check failed: %w", check.Name(), err)
+		}
+		signals[check.Name()] = result.Healthy
+		if !result.Healthy {
+			failedNames = append(failedNames, result.Check)
+			failedMessages = append(failedMessages, fmt.Sprintf("%s: %s", result.Check, result.Message))
+			if staleLease == nil {
+				staleLease = result.StaleLease
+			}
+		}
+	}
+
+	return Result{
+		Check:      strings.Join(failedNames, ","),
+		Healthy:    len(failedNames) < c.requiredFailures(),
+		Message:    strings.Join(failedMessages, "; "),
+		Signals:    signals,
+		StaleLease: staleLease,
+	}, nil
+}
+
+// requiredFailures returns number of checks that have to fail to consider node unhealthy
+func (c *Combined) requiredFailures() int {
+	switch c.Mode {
+	case ModeAll:
+		return len(c.Checks)
+	case ModeQuorum:
+		return c.Quorum
+	default:
+		return 1
+	}
+}
+
+// Options configures health checks
+type Options struct {
+	Mode string
+	// Quorum - number of checks that have to fail in quorum mode
+	Quorum int
+	Checks []string
+	// Conditions - comma separated list of unhealthy conditions for condition check
+	Conditions string
+	// ConditionThreshold - number of seconds unhealthy condition has to hold
+	ConditionThreshold int
+	HTTP               HTTPOptions
+}
+
+// New creates health check combining checks with given names
+func New(options Options) (HEALTHCHECKER, error) {
+	checks := make([]HEALTHCHECKER, 0, len(options.Checks))
+	for _, name := range options.Checks {
+		switch strings.TrimSpace(name) {
+		case LeaseCheck:
+			checks = append(checks, NewLeaseChecker(LeaseCheck, ""))
+		case KubeletLeaseCheck:
+			checks = append(checks, NewLeaseChecker(KubeletLeaseCheck, KubeletLeaseNamespace))
+		case ConditionCheck:
+			check, err := NewConditionChecker(options.Conditions, options.ConditionThreshold)
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, check)
+		case HTTPCheck:
+			check, err := NewHTTPChecker(options.HTTP)
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, check)
+		default:
+			return nil, fmt.Errorf("unknown health check: %s", name)
+		}
+	}
+	return NewCombined(options.Mode, options.Quorum, checks...)
+}
@@ -0,0 +1,145 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+// staticCheck always returns the same result
+type staticCheck struct {
+	name    string
+	healthy bool
+	err     error
+}
+
+func (c *staticCheck) Name() string {
+	return c.name
+}
+
+func (c *staticCheck) Check(ctx context.Context, target Target) (Result, error) {
+	return Result{Check: c.name, Healthy: c.healthy, Message: c.name + " failed"}, c.err
+}
+
+func TestCombinedCheck(t *testing.T) {
+	cases := []struct {
+		mode    string
+		quorum  int
+		healthy []bool
+		result  bool
+	}{
+		{ModeAny, 0, []bool{true, true}, true},
+		{ModeAny, 0, []bool{true, false}, false},
+		{ModeAll, 0, []bool{true, false}, true},
+		{ModeAll, 0, []bool{false, false}, false},
+		{ModeQuorum, 2, []bool{true, false, true}, true},
+		{ModeQuorum, 2, []bool{false, true, false}, false},
+		{ModeQuorum, 2, []bool{false, false, false}, false},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s %d %v", c.mode, c.quorum, c.healthy), func(t *testing.T) {
+			checks := make([]HEALTHCHECKER, len(c.healthy))
+			for i := range c.healthy {
+				checks[i] = &staticCheck{fmt.Sprintf("check%d", i), c.healthy[i], nil}
+			}
+			combined, err := NewCombined(c.mode, c.quorum, checks...)
+			require.NoError(t, err)
+
+			result, err := combined.Check(context.TODO(), Target{})
+			assert.NoError(t, err)
+			assert.Equal(t, c.result, result.Healthy)
+		})
+	}
+}
+
+func TestCombinedCheckFailedNames(t *testing.T) {
+	combined, err := NewCombined(ModeAny, 0,
+		&staticCheck{"lease", false, nil},
+		&staticCheck{"condition", true, nil},
+		&staticCheck{"other", false, nil},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "lease,condition,other", combined.Name())
+
+	result, err := combined.Check(context.TODO(), Target{})
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, "lease,other", result.Check)
+	assert.Equal(t, "lease: lease failed; other: other failed", result.Message)
+	assert.Equal(t, map[string]bool{"lease": false, "condition": true, "other": false}, result.Signals)
+	assert.True(t, result.Disagree())
+}
+
+func TestCombinedCheckStaleLease(t *testing.T) {
+	client := fake.NewClientset(
+		createLease("node1", "kube-node-lease", time.Now()),
+		createLease("node1", "apps", time.Now().Add(-time.Hour)),
+	)
+	combined, err := NewCombined(ModeAny, 0,
+		&staticCheck{"condition", false, nil},
+		NewLeaseChecker(KubeletLeaseCheck, KubeletLeaseNamespace),
+		NewLeaseChecker("apps-lease", "apps"),
+	)
+	require.NoError(t, err)
+
+	result, err := combined.Check(context.TODO(), Target{Node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}, Client: client})
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, &LeaseRef{Namespace: "apps", Name: "node1"}, result.StaleLease)
+}
+
+func TestResultDisagree(t *testing.T) {
+	assert.False(t, Result{}.Disagree())
+	assert.False(t, Result{Signals: map[string]bool{"lease": true, "http": true}}.Disagree())
+	assert.False(t, Result{Signals: map[string]bool{"lease": false, "http": false}}.Disagree())
+	assert.True(t, Result{Signals: map[string]bool{"lease": false, "http": true}}.Disagree())
+}
+
+func TestCombinedCheckErr(t *testing.T) {
+	combined, err := NewCombined(ModeAll, 0,
+		&staticCheck{"lease", true, nil},
+		&staticCheck{"condition", false, fmt.Errorf("test error")},
+	)
+	require.NoError(t, err)
+
+	_, err = combined.Check(context.TODO(), Target{})
+	assert.ErrorContains(t, err, "condition check failed")
+}
+
+func TestNewErr(t *testing.T) {
+	cases := map[string]Options{
+		"mode":        {Mode: "some", Checks: []string{LeaseCheck}},
+		"unknown":     {Mode: ModeAny, Checks: []string{"unknown"}},
+		"condition":   {Mode: ModeAny, Checks: []string{ConditionCheck}, Conditions: "Ready"},
+		"http":        {Mode: ModeAny, Checks: []string{HTTPCheck}},
+		"no checks":   {Mode: ModeAny, Checks: []string{}},
+		"quorum":      {Mode: ModeQuorum, Quorum: 3, Checks: []string{LeaseCheck, KubeletLeaseCheck}},
+		"zero quorum": {Mode: ModeQuorum, Checks: []string{LeaseCheck}},
+		"duplicated":  {Mode: ModeAny, Checks: []string{LeaseCheck, LeaseCheck}},
+	}
+	for name, options := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(options)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	checker, err := New(Options{
+		Mode:               ModeQuorum,
+		Quorum:             2,
+		Checks:             []string{LeaseCheck, KubeletLeaseCheck, " condition", HTTPCheck},
+		Conditions:         "Ready=False",
+		ConditionThreshold: 60,
+		HTTP:               HTTPOptions{Port: 8080, Path: "/healthz", Timeout: 5, FailureThreshold: 3, Concurrency: 10},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "lease,kubelet-lease,condition,http", checker.Name())
+}
@@ -0,0 +1,125 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const HTTPCheck = "http"
+
+// HTTPOptions configures HTTP health check
+type HTTPOptions struct {
+	Port int
+	Path string
+	// Timeout of a single probe in seconds
+	Timeout int
+	// FailureThreshold is number of consecutive failed probes after which the check fails
+	FailureThreshold int
+	// Concurrency is maximum number of probes running at the same time
+	Concurrency int
+}
+
+// HTTPChecker probes http endpoint on node's InternalIP
+type HTTPChecker struct {
+	options   HTTPOptions
+	client    *http.Client
+	semaphore chan struct{}
+	mutex     sync.Mutex
+	failures  map[string]int
+}
+
+func NewHTTPChecker(options HTTPOptions) (*HTTPChecker, error) {
+	if options.Port <= 0 || options.Port > 65535 {
+		return nil, fmt.Errorf("http check port has to be between 1 and 65535")
+	}
+	if options.Timeout <= 0 {
+		return nil, fmt.Errorf("http check timeout has to be greater than zero")
+	}
+	if options.FailureThreshold <= 0 {
+		return nil, fmt.Errorf("http check failure threshold has to be greater than zero")
+	}
+	if options.Concurrency <= 0 {
+		return nil, fmt.Errorf("http check concurrency has to be greater than zero")
+	}
+	return &HTTPChecker{
+		options:   options,
+		client:    &http.Client{Timeout: time.Duration(options.Timeout) * time.Second},
+		semaphore: make(chan struct{}, options.Concurrency),
+		failures:  make(map[string]int),
+	}, nil
+}
+
+func (c *HTTPChecker) Name() string {
+	return HTTPCheck
+}
+
+// Check probes the node. It fails only after FailureThreshold consecutive failed probes
+func (c *HTTPChecker) Check(ctx context.Context, target Target) (Result, error) {
+	probeErr := c.probe(ctx, target.Node)
+	if ctx.Err() != nil {
+		// probe was cancelled, so it says nothing about the node
+		return Result{Check: HTTPCheck}, ctx.Err()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if probeErr == nil {
+		delete(c.failures, target.Node.Name)
+		return Result{Check: HTTPCheck, Healthy: true}, nil
+	}
+	c.failures[target.Node.Name]++
+	failures := c.failures[target.Node.Name]
+	message := fmt.Sprintf("%d consecutive probes failed, last: %v", failures, probeErr)
+	return Result{Check: HTTPCheck, Healthy: failures < c.options.FailureThreshold, Message: message}, nil
+}
+
+// Forget removes count of failed probes of the node
+func (c *HTTPChecker) Forget(nodeName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.failures, nodeName)
+}
+
+func (c *HTTPChecker) probe(ctx context.Context, node *v1.Node) error {
+	address := getInternalIP(node)
+	if address == "" {
+		return fmt.Errorf("node has no InternalIP address")
+	}
+
+	select {
+	case c.semaphore <- struct{}{}:
+		defer func() { <-c.semaphore }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(address, strconv.Itoa(c.options.Port)), c.options.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("got %d http code from %s", resp.StatusCode, url)
+	}
+	return nil
+}
+
+func getInternalIP(node *v1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
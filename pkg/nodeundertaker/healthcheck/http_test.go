@@ -0,0 +1,193 @@
+package healthcheck
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServer starts test server with the handler. Returns port it listens on
+func startServer(t *testing.T, handler http.HandlerFunc) int {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	ret, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return ret
+}
+
+func createHTTPTarget(address string) Target {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	if address != "" {
+		node.Status.Addresses = []v1.NodeAddress{
+			{Type: v1.NodeHostName, Address: "node1"},
+			{Type: v1.NodeInternalIP, Address: address},
+		}
+	}
+	return Target{Node: node}
+}
+
+func createHTTPChecker(t *testing.T, port, failureThreshold int) *HTTPChecker {
+	checker, err := NewHTTPChecker(HTTPOptions{Port: port, Path: "/healthz", Timeout: 1, FailureThreshold: failureThreshold, Concurrency: 2})
+	require.NoError(t, err)
+	return checker
+}
+
+func TestHTTPCheckerHealthy(t *testing.T) {
+	path := ""
+	port := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	})
+	checker := createHTTPChecker(t, port, 1)
+
+	result, err := checker.Check(context.TODO(), createHTTPTarget("127.0.0.1"))
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+	assert.Equal(t, HTTPCheck, result.Check)
+	assert.Equal(t, "/healthz", path)
+}
+
+func TestHTTPCheckerFailureThreshold(t *testing.T) {
+	var healthy atomic.Bool
+	port := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	checker := createHTTPChecker(t, port, 2)
+	target := createHTTPTarget("127.0.0.1")
+
+	result, err := checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+	assert.Contains(t, result.Message, "1 consecutive probes failed")
+
+	result, err = checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Contains(t, result.Message, "got 503 http code")
+
+	// success resets counter
+	healthy.Store(true)
+	result, err = checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+	healthy.Store(false)
+	result, err = checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+}
+
+func TestForgetHTTPFailures(t *testing.T) {
+	port := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	checker := createHTTPChecker(t, port, 2)
+	combined, err := NewCombined(ModeAny, 0, checker)
+	require.NoError(t, err)
+	target := createHTTPTarget("127.0.0.1")
+
+	result, err := checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+
+	// removed node starts counting from zero
+	Forget(combined, "node1")
+	result, err = checker.Check(context.TODO(), target)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+	assert.Contains(t, result.Message, "1 consecutive probes failed")
+}
+
+func TestHTTPCheckerTimeout(t *testing.T) {
+	port := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(3 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	checker := createHTTPChecker(t, port, 1)
+
+	start := time.Now()
+	result, err := checker.Check(context.TODO(), createHTTPTarget("127.0.0.1"))
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestHTTPCheckerNoInternalIP(t *testing.T) {
+	checker := createHTTPChecker(t, 8080, 1)
+
+	result, err := checker.Check(context.TODO(), createHTTPTarget(""))
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Contains(t, result.Message, "no InternalIP")
+}
+
+func TestHTTPCheckerConcurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	port := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := maxRunning.Load()
+			if current <= old || maxRunning.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	checker := createHTTPChecker(t, port, 1)
+
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			_, _ = checker.Check(context.TODO(), createHTTPTarget("127.0.0.1"))
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestHTTPCheckerCancelled(t *testing.T) {
+	checker := createHTTPChecker(t, 8080, 1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, err := checker.Check(ctx, createHTTPTarget("127.0.0.1"))
+	assert.Error(t, err)
+}
+
+func TestNewHTTPCheckerErr(t *testing.T) {
+	valid := HTTPOptions{Port: 8080, Path: "/healthz", Timeout: 5, FailureThreshold: 3, Concurrency: 10}
+	invalid := []func(o *HTTPOptions){
+		func(o *HTTPOptions) { o.Port = 0 },
+		func(o *HTTPOptions) { o.Port = 70000 },
+		func(o *HTTPOptions) { o.Timeout = 0 },
+		func(o *HTTPOptions) { o.FailureThreshold = 0 },
+		func(o *HTTPOptions) { o.Concurrency = 0 },
+	}
+	for i := range invalid {
+		options := valid
+		invalid[i](&options)
+		_, err := NewHTTPChecker(options)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,225 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// LeasesModeAll - node is healthy when all its leases are fresh
+	LeasesModeAll = "all"
+	// LeasesModeAny - node is healthy when any of its leases is fresh
+	LeasesModeAny = "any"
+
+	// nodeNameMarker is rendered in place of node name to find node name in lease name
+	nodeNameMarker = "\x00"
+)
+
+// LeaseListers contains listers of watched lease namespaces
+type LeaseListers map[string]coordinationlisters.LeaseNamespaceLister
+
+// GetLease returns lease from informer's cache when its namespace is watched, otherwise from API server
+func (l LeaseListers) GetLease(ctx context.Context, client kubernetes.Interface, namespace, name string) (*coordinationv1.Lease, error) {
+	if lister, found := l[namespace]; found {
+		return lister.Get(name)
+	}
+	return client.CoordinationV1().Leases(namespace).Get(ctx, name, metav1.GetOptions{ResourceVersion: "0"})
+}
+
+// LeaseSource is a namespace containing leases of nodes
+type LeaseSource struct {
+	Namespace string
+	// NameTemplate renders lease name from LeaseNameData. When nil - lease has the same name as node
+	NameTemplate *template.Template
+}
+
+// LeaseRef identifies lease of a node in one of lease sources
+type LeaseRef struct {
+	Namespace string
+	Name      string
+}
+
+// LeaseNameData is passed to lease name template
+type LeaseNameData struct {
+	NodeName string
+}
+
+// ParseLeaseSources parses comma separated list of namespaces with optional lease name templates, e.g. kube-node-lease,apps:{{.NodeName}}-app
+func ParseLeaseSources(value string) ([]LeaseSource, error) {
+	ret := make([]LeaseSource, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		namespace, nameTemplate, hasTemplate := strings.Cut(item, ":")
+		if namespace == "" {
+			return nil, fmt.Errorf("lease namespace can't be empty: %s", item)
+		}
+		source := LeaseSource{Namespace: namespace}
+		if hasTemplate {
+			tmpl, err := template.New(namespace).Option("missingkey=error").Parse(nameTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("lease name template for namespace %s is not valid: %w", namespace, err)
+			}
+			source.NameTemplate = tmpl
+			if _, err = source.LeaseName("test"); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, source)
+	}
+	return ret, nil
+}
+
+// LeaseName returns name of the node's lease in this source
+func (s LeaseSource) LeaseName(nodeName string) (string, error) {
+	if s.NameTemplate == nil {
+		return nodeName, nil
+	}
+	buf := bytes.Buffer{}
+	err := s.NameTemplate.Execute(&buf, LeaseNameData{NodeName: nodeName})
+	if err != nil {
+		return "", fmt.Errorf("couldn't render lease name for namespace %s: %w", s.Namespace, err)
+	}
+	return buf.String(), nil
+}
+
+// NodeName returns name of the node owning the lease. Returns false if lease name doesn't match the template
+func (s LeaseSource) NodeName(leaseName string) (string, bool) {
+	if s.NameTemplate == nil {
+		return leaseName, true
+	}
+	rendered, err := s.LeaseName(nodeNameMarker)
+	if err != nil {
+		return "", false
+	}
+	prefix, suffix, found := strings.Cut(rendered, nodeNameMarker)
+	if !found || strings.Contains(suffix, nodeNameMarker) {
+		// template doesn't contain node name exactly once
+		return "", false
+	}
+	if len(leaseName) <= len(prefix)+len(suffix) || !strings.HasPrefix(leaseName, prefix) || !strings.HasSuffix(leaseName, suffix) {
+		return "", false
+	}
+	return leaseName[len(prefix) : len(leaseName)-len(suffix)], true
+}
+
+// LeaseNamespaces returns namespaces checked by lease checks regardless of target, e.g. by kubelet-lease check
+func LeaseNamespaces(checker HEALTHCHECKER) []string {
+	ret := make([]string, 0)
+	switch c := checker.(type) {
+	case *Combined:
+		for i := range c.Checks {
+			ret = append(ret, LeaseNamespaces(c.Checks[i])...)
+		}
+	case *LeaseChecker:
+		for i := range c.sources {
+			ret = append(ret, c.sources[i].Namespace)
+		}
+	}
+	return ret
+}
+
+// LeaseChecker checks if node's leases are renewed
+type LeaseChecker struct {
+	name string
+	// sources of leases, when empty - sources from target are used
+	sources []LeaseSource
+}
+
+// NewLeaseChecker creates lease checker. When namespace is empty, leases configured in target are checked
+func NewLeaseChecker(name, namespace string) *LeaseChecker {
+	ret := LeaseChecker{name: name}
+	if namespace != "" {
+		ret.sources = []LeaseSource{{Namespace: namespace}}
+	}
+	return &ret
+}
+
+func (c *LeaseChecker) Name() string {
+	return c.name
+}
+
+func (c *LeaseChecker) Check(ctx context.Context, target Target) (Result, error) {
+	sources, mode := c.sources, LeasesModeAll
+	if len(sources) == 0 {
+		sources, mode = target.LeaseSources, target.LeasesMode
+	}
+	if len(sources) == 0 {
+		return Result{Check: c.name}, fmt.Errorf("no lease namespaces configured")
+	}
+
+	fresh := 0
+	messages := make([]string, 0)
+	var staleLease *LeaseRef
+	for _, source := range sources {
+		ref, healthy, message, err := checkLease(ctx, target, source)
+		if err != nil {
+			return Result{Check: c.name}, err
+		}
+		if healthy {
+			fresh++
+		} else {
+			messages = append(messages, message)
+			if staleLease == nil {
+				staleLease = &ref
+			}
+		}
+	}
+
+	healthy := fresh == len(sources)
+	if mode == LeasesModeAny {
+		healthy = fresh > 0
+	}
+	ret := Result{Check: c.name, Healthy: healthy, Message: strings.Join(messages, ", ")}
+	if !healthy {
+		ret.StaleLease = staleLease
+	}
+	return ret, nil
+}
+
+// checkLease returns true if node's lease in the source is fresh, otherwise description why it isn't
+func checkLease(ctx context.Context, target Target, source LeaseSource) (LeaseRef, bool, string, error) {
+	name, err := source.LeaseName(target.Node.Name)
+	if err != nil {
+		return LeaseRef{}, false, "", err
+	}
+	ref := LeaseRef{Namespace: source.Namespace, Name: name}
+	lease, err := target.LeaseListers.GetLease(ctx, target.Client, source.Namespace, name)
+	if errors.IsNotFound(err) {
+		return ref, false, fmt.Sprintf("lease %s/%s not found", source.Namespace, name), nil
+	} else if err != nil {
+		return ref, false, "", err
+	}
+	if IsLeaseFresh(lease) {
+		return ref, true, "", nil
+	}
+	if lease.Spec.RenewTime == nil {
+		return ref, false, fmt.Sprintf("lease %s/%s was never renewed", source.Namespace, name), nil
+	}
+	return ref, false, fmt.Sprintf("lease %s/%s renewed at %s", source.Namespace, name, lease.Spec.RenewTime.UTC().Format(time.RFC3339)), nil
+}
+
+// IsLeaseFresh returns true if lease was renewed within its duration
+func IsLeaseFresh(lease *coordinationv1.Lease) bool {
+	expiry, ok := LeaseExpiry(lease)
+	return ok && expiry.After(time.Now())
+}
+
+// LeaseExpiry returns time when lease becomes stale unless it is renewed. Returns false when lease doesn't have renew time or duration
+func LeaseExpiry(lease *coordinationv1.Lease) (time.Time, bool) {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}, false
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second), true
+}
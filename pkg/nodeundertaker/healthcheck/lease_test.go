@@ -0,0 +1,194 @@
+package healthcheck
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+	"time"
+)
+
+func createLease(name, namespace string, renewTime time.Time) *coordinationv1.Lease {
+	duration := int32(40)
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       coordinationv1.LeaseSpec{RenewTime: &renew, LeaseDurationSeconds: &duration},
+	}
+}
+
+func TestLeaseChecker(t *testing.T) {
+	client := fake.NewClientset(
+		createLease("fresh", "kube-node-lease", time.Now()),
+		createLease("stale", "kube-node-lease", time.Now().Add(-time.Hour)),
+		createLease("custom", "custom", time.Now()),
+	)
+	cases := map[string]struct {
+		checker *LeaseChecker
+		node    string
+		healthy bool
+	}{
+		"fresh":            {NewLeaseChecker(LeaseCheck, ""), "fresh", true},
+		"stale":            {NewLeaseChecker(LeaseCheck, ""), "stale", false},
+		"missing":          {NewLeaseChecker(LeaseCheck, ""), "missing", false},
+		"custom namespace": {NewLeaseChecker("custom-lease", "custom"), "custom", true},
+		"custom missing":   {NewLeaseChecker("custom-lease", "custom"), "fresh", false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			target := Target{
+				Node:         &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: c.node}},
+				Client:       client,
+				LeaseSources: []LeaseSource{{Namespace: "kube-node-lease"}},
+			}
+			result, err := c.checker.Check(context.TODO(), target)
+			assert.NoError(t, err)
+			assert.Equal(t, c.healthy, result.Healthy)
+			assert.Equal(t, c.checker.Name(), result.Check)
+			if !c.healthy {
+				assert.NotEmpty(t, result.Message)
+			}
+		})
+	}
+}
+
+func TestLeaseCheckerMultipleSources(t *testing.T) {
+	client := fake.NewClientset(
+		createLease("node1", "kube-node-lease", time.Now()),
+		createLease("node1-app", "apps", time.Now().Add(-time.Hour)),
+		createLease("node2", "kube-node-lease", time.Now()),
+		createLease("node2-app", "apps", time.Now()),
+	)
+	sources, err := ParseLeaseSources("kube-node-lease,apps:{{.NodeName}}-app")
+	assert.NoError(t, err)
+
+	cases := map[string]struct {
+		node    string
+		mode    string
+		healthy bool
+	}{
+		"all fresh, mode all":  {"node2", LeasesModeAll, true},
+		"one stale, mode all":  {"node1", LeasesModeAll, false},
+		"one stale, mode any":  {"node1", LeasesModeAny, true},
+		"all missing mode any": {"node3", LeasesModeAny, false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			target := Target{
+				Node:         &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: c.node}},
+				Client:       client,
+				LeaseSources: sources,
+				LeasesMode:   c.mode,
+			}
+			result, err := NewLeaseChecker(LeaseCheck, "").Check(context.TODO(), target)
+			assert.NoError(t, err)
+			assert.Equal(t, c.healthy, result.Healthy)
+		})
+	}
+
+	result, err := NewLeaseChecker(LeaseCheck, "").Check(context.TODO(), Target{
+		Node:         &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		Client:       client,
+		LeaseSources: sources,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, result.Message, "lease apps/node1-app renewed at")
+	assert.Equal(t, &LeaseRef{Namespace: "apps", Name: "node1-app"}, result.StaleLease)
+
+	// node is healthy, so stale lease isn't reported
+	result, err = NewLeaseChecker(LeaseCheck, "").Check(context.TODO(), Target{
+		Node:         &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		Client:       client,
+		LeaseSources: sources,
+		LeasesMode:   LeasesModeAny,
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+	assert.Nil(t, result.StaleLease)
+}
+
+func TestParseLeaseSources(t *testing.T) {
+	sources, err := ParseLeaseSources(" kube-node-lease , apps:{{.NodeName}}-app,")
+	assert.NoError(t, err)
+	assert.Len(t, sources, 2)
+	assert.Equal(t, "kube-node-lease", sources[0].Namespace)
+	assert.Nil(t, sources[0].NameTemplate)
+	name, err := sources[1].LeaseName("node1")
+	assert.NoError(t, err)
+	assert.Equal(t, "node1-app", name)
+
+	sources, err = ParseLeaseSources("")
+	assert.NoError(t, err)
+	assert.Empty(t, sources)
+
+	_, err = ParseLeaseSources(":{{.NodeName}}")
+	assert.Error(t, err)
+	_, err = ParseLeaseSources("apps:{{.NodeName")
+	assert.Error(t, err)
+	_, err = ParseLeaseSources("apps:{{.Unknown}}")
+	assert.Error(t, err)
+}
+
+func TestIsLeaseFreshNeverRenewed(t *testing.T) {
+	assert.False(t, IsLeaseFresh(&coordinationv1.Lease{}))
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	renewTime := time.Now().Add(-10 * time.Second)
+	expiry, ok := LeaseExpiry(createLease("lease", "kube-node-lease", renewTime))
+	assert.True(t, ok)
+	assert.WithinDuration(t, renewTime.Add(40*time.Second), expiry, time.Millisecond)
+
+	_, ok = LeaseExpiry(&coordinationv1.Lease{})
+	assert.False(t, ok)
+}
+
+func TestLeaseSourceNodeName(t *testing.T) {
+	sources, err := ParseLeaseSources("kube-node-lease,apps:{{.NodeName}}-app,twice:{{.NodeName}}-{{.NodeName}}")
+	assert.NoError(t, err)
+
+	name, matches := sources[0].NodeName("node1")
+	assert.True(t, matches)
+	assert.Equal(t, "node1", name)
+
+	name, matches = sources[1].NodeName("node1-app")
+	assert.True(t, matches)
+	assert.Equal(t, "node1", name)
+
+	_, matches = sources[1].NodeName("node1-other")
+	assert.False(t, matches)
+	_, matches = sources[1].NodeName("-app")
+	assert.False(t, matches)
+	_, matches = sources[2].NodeName("node1-node1")
+	assert.False(t, matches)
+}
+
+func TestLeaseListersGetLease(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(createLease("cached", "kube-node-lease", time.Now())))
+	listers := LeaseListers{"kube-node-lease": coordinationlisters.NewLeaseLister(indexer).Leases("kube-node-lease")}
+	client := fake.NewClientset(createLease("other", "custom", time.Now()))
+
+	lease, err := listers.GetLease(context.TODO(), client, "kube-node-lease", "cached")
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", lease.Name)
+
+	_, err = listers.GetLease(context.TODO(), client, "kube-node-lease", "missing")
+	assert.True(t, errors.IsNotFound(err))
+
+	lease, err = listers.GetLease(context.TODO(), client, "custom", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", lease.Name)
+}
+
+func TestLeaseNamespaces(t *testing.T) {
+	checker, err := New(Options{Mode: ModeAny, Checks: []string{LeaseCheck, KubeletLeaseCheck}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{KubeletLeaseNamespace}, LeaseNamespaces(checker))
+}
@@ -0,0 +1,158 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is a set of allowed values of one field of cron expression
+type cronField struct {
+	values map[int]bool
+	// any is true only when field is literally '*' (steps like '*/2' restrict the field) - it matters for matching
+	// day of month and day of week
+	any bool
+}
+
+// cronSchedule is a standard 5 field cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+}
+
+// cronFieldBounds are minimal and maximal values of fields of cron expression
+var cronFieldBounds = [5][2]int{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are sunday
+}
+
+// parseCron parses 5 field cron expression. Fields can contain '*', values, ranges (a-b), steps (*/n, a-b/n, a/n) and lists of them
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' should have 5 fields, has %d", expression, len(fields))
+	}
+	parsed := [5]cronField{}
+	for i := range fields {
+		field, err := parseCronField(fields[i], cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression '%s': %w", expression, err)
+		}
+		parsed[i] = field
+	}
+	// sunday can be given as 7
+	if parsed[4].values[7] {
+		parsed[4].values[0] = true
+	}
+	return &cronSchedule{
+		minute:     parsed[0],
+		hour:       parsed[1],
+		dayOfMonth: parsed[2],
+		month:      parsed[3],
+		dayOfWeek:  parsed[4],
+	}, nil
+}
+
+func parseCronField(field string, low, high int) (cronField, error) {
+	ret := cronField{values: make(map[int]bool), any: field == "*"}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return ret, fmt.Errorf("invalid step in '%s'", part)
+			}
+		}
+
+		from, to := low, high
+		if rangePart != "*" {
+			fromPart, toPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			from, err = strconv.Atoi(fromPart)
+			if err != nil {
+				return ret, fmt.Errorf("invalid value in '%s'", part)
+			}
+			to = from
+			if isRange {
+				to, err = strconv.Atoi(toPart)
+				if err != nil {
+					return ret, fmt.Errorf("invalid value in '%s'", part)
+				}
+			} else if hasStep {
+				// a/n means every n-th value starting from a
+				to = high
+			}
+		}
+		if from < low || to > high || from > to {
+			return ret, fmt.Errorf("'%s' is out of range %d-%d", part, low, high)
+		}
+		for value := from; value <= to; value += step {
+			ret.values[value] = true
+		}
+	}
+	return ret, nil
+}
+
+// matches checks if schedule fires at given minute
+func (s *cronSchedule) matches(t time.Time) bool {
+	return s.minute.values[t.Minute()] && s.hour.values[t.Hour()] && s.matchesDay(t)
+}
+
+// matchesDay checks if schedule fires on the day of given time. As in standard cron, when both day of month and
+// day of week are restricted, either of them has to match
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if !s.month.values[int(t.Month())] {
+		return false
+	}
+	dayOfMonth := s.dayOfMonth.values[t.Day()]
+	dayOfWeek := s.dayOfWeek.values[int(t.Weekday())]
+	if s.dayOfMonth.any || s.dayOfWeek.any {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// previous returns the latest time not after t at which schedule fires, if it is after limit. Matching days are
+// found first, then the latest matching hour and minute within them - no minute by minute scanning
+func (s *cronSchedule) previous(t, limit time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for day := today; day.AddDate(0, 0, 1).After(limit); day = day.AddDate(0, 0, -1) {
+		if !s.matchesDay(day) {
+			continue
+		}
+		lastHour := 23
+		if day.Equal(today) {
+			lastHour = t.Hour()
+		}
+		for hour := lastHour; hour >= 0; hour-- {
+			if !s.hour.values[hour] {
+				continue
+			}
+			lastMinute := 59
+			if day.Equal(today) && hour == t.Hour() {
+				lastMinute = t.Minute()
+			}
+			for minute := lastMinute; minute >= 0; minute-- {
+				if !s.minute.values[minute] {
+					continue
+				}
+				fired := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+				if !fired.After(limit) {
+					return time.Time{}, false
+				}
+				return fired, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,75 @@
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxDuration limits window duration, so finding the start of active window stays cheap
+const maxDuration = 7 * 24 * time.Hour
+
+// Window is a recurring period during which node-undertaker doesn't move nodes towards termination
+type Window struct {
+	spec     string
+	schedule *cronSchedule
+	duration time.Duration
+}
+
+// ParseWindows parses semicolon separated list of windows. Each window is a 5 field cron expression of its start (in UTC)
+// followed by its duration, e.g. '0 22 * * 5 60h;0 2 * * 1-4 2h'
+func ParseWindows(spec string) ([]Window, error) {
+	ret := make([]Window, 0)
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Fields(part)
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("maintenance window '%s' should be a cron expression followed by duration", part)
+		}
+		schedule, err := parseCron(strings.Join(fields[:5], " "))
+		if err != nil {
+			return nil, err
+		}
+		duration, err := time.ParseDuration(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window '%s': %w", part, err)
+		}
+		if duration < time.Minute || duration > maxDuration {
+			return nil, fmt.Errorf("maintenance window '%s': duration should be between %s and %s", part, time.Minute, maxDuration)
+		}
+		ret = append(ret, Window{spec: part, schedule: schedule, duration: duration})
+	}
+	return ret, nil
+}
+
+// String returns window as it was configured
+func (w Window) String() string {
+	return w.spec
+}
+
+// ActiveUntil returns end of the window if it is active at given time
+func (w Window) ActiveUntil(now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	// the latest start gives the latest end
+	start, found := w.schedule.previous(now, now.Add(-w.duration))
+	if !found {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// Active returns active window which ends the latest
+func Active(windows []Window, now time.Time) (Window, time.Time, bool) {
+	var active Window
+	var end time.Time
+	found := false
+	for i := range windows {
+		if windowEnd, ok := windows[i].ActiveUntil(now); ok && windowEnd.After(end) {
+			active, end, found = windows[i], windowEnd, true
+		}
+	}
+	return active, end, found
+}
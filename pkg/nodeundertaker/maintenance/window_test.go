@@ -0,0 +1,125 @@
+package maintenance
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	schedule, err := parseCron("*/15 2-4 1,15 * 1-5")
+	require.NoError(t, err)
+	assert.Len(t, schedule.minute.values, 4)
+	assert.True(t, schedule.minute.values[45])
+	assert.Len(t, schedule.hour.values, 3)
+	assert.Len(t, schedule.month.values, 12)
+	assert.True(t, schedule.month.any)
+	assert.False(t, schedule.dayOfWeek.any)
+
+	schedule, err = parseCron("5/20 0 * * 7")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{5: true, 25: true, 45: true}, schedule.minute.values)
+	assert.True(t, schedule.dayOfWeek.values[0])
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expression := range []string{"* * * *", "60 * * * *", "* 5-2 * * *", "*/0 * * * *", "a * * * *", "* * 0 * *", "* * * 1-x *"} {
+		_, err := parseCron(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2024-03-01 is friday
+	friday := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+
+	schedule, err := parseCron("0 22 * * 5")
+	require.NoError(t, err)
+	assert.True(t, schedule.matches(friday))
+	assert.False(t, schedule.matches(friday.Add(time.Minute)))
+	assert.False(t, schedule.matches(friday.Add(7*24*time.Hour-24*time.Hour)))
+
+	// day of month or day of week
+	schedule, err = parseCron("0 22 15 * 5")
+	require.NoError(t, err)
+	assert.True(t, schedule.matches(friday))
+	assert.True(t, schedule.matches(time.Date(2024, 3, 15, 22, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.matches(time.Date(2024, 3, 14, 22, 0, 0, 0, time.UTC)))
+
+	// day of month and any day of week
+	schedule, err = parseCron("0 22 15 * *")
+	require.NoError(t, err)
+	assert.False(t, schedule.matches(friday))
+
+	// stepped day of month restricts the field - either odd day of month or monday matches
+	schedule, err = parseCron("0 2 */2 * 1")
+	require.NoError(t, err)
+	assert.False(t, schedule.dayOfMonth.any)
+	assert.True(t, schedule.matches(time.Date(2024, 3, 3, 2, 0, 0, 0, time.UTC)))
+	assert.True(t, schedule.matches(time.Date(2024, 3, 4, 2, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.matches(time.Date(2024, 3, 6, 2, 0, 0, 0, time.UTC)))
+}
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows(" 0 22 * * 5 60h ; 0 2 * * 1-4 2h;")
+	require.NoError(t, err)
+	require.Len(t, windows, 2)
+	assert.Equal(t, "0 22 * * 5 60h", windows[0].String())
+	assert.Equal(t, 60*time.Hour, windows[0].duration)
+
+	windows, err = ParseWindows("")
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+}
+
+func TestParseWindowsErrors(t *testing.T) {
+	for _, spec := range []string{"0 22 * * 5", "0 22 * * 5 abc", "0 22 * * 5 30s", "0 22 * * 5 200h", "0 25 * * 5 1h"} {
+		_, err := ParseWindows(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestActive(t *testing.T) {
+	windows, err := ParseWindows("0 22 * * 5 60h;0 2 * * * 2h")
+	require.NoError(t, err)
+	friday := time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC)
+
+	window, end, active := Active(windows, friday.Add(30*time.Hour))
+	assert.True(t, active)
+	assert.Equal(t, "0 22 * * 5 60h", window.String())
+	assert.Equal(t, friday.Add(60*time.Hour), end)
+
+	_, _, active = Active(windows, friday.Add(60*time.Hour))
+	assert.False(t, active)
+
+	window, end, active = Active(windows, time.Date(2024, 3, 5, 3, 59, 0, 0, time.UTC))
+	assert.True(t, active)
+	assert.Equal(t, "0 2 * * * 2h", window.String())
+	assert.Equal(t, time.Date(2024, 3, 5, 4, 0, 0, 0, time.UTC), end)
+
+	_, _, active = Active(windows, time.Date(2024, 3, 5, 1, 59, 0, 0, time.UTC))
+	assert.False(t, active)
+}
+
+func TestCronPrevious(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)
+	for _, expression := range []string{"0 22 * * 5", "*/15 2-4 1,15 * 1-5", "0 22 15 * 5", "30 10 * * *", "59 23 29 2 *", "5/20 0 * * 7", "0 2 */2 * 1"} {
+		schedule, err := parseCron(expression)
+		require.NoError(t, err)
+		limit := now.Add(-maxDuration)
+
+		// the latest matching minute found by scanning back
+		var expected time.Time
+		for start := now; start.After(limit); start = start.Add(-time.Minute) {
+			if schedule.matches(start) {
+				expected = start
+				break
+			}
+		}
+
+		fired, found := schedule.previous(now.Add(30*time.Second), limit)
+		assert.Equal(t, !expected.IsZero(), found, expression)
+		assert.Equal(t, expected, fired, expression)
+	}
+}
@@ -0,0 +1,35 @@
+package node
+
+const (
+	ClusterAutoscaler = "cluster-autoscaler"
+	Karpenter         = "karpenter"
+
+	// ClusterAutoscalerTaintKey is applied by cluster-autoscaler to nodes it scales down
+	ClusterAutoscalerTaintKey = "ToBeDeletedByClusterAutoscaler"
+	// KarpenterDisruptedTaintKey is applied by karpenter to nodes it disrupts
+	KarpenterDisruptedTaintKey = "karpenter.sh/disrupted"
+	// KarpenterDisruptionTaintKey is applied to disrupted nodes by karpenter versions before v1
+	KarpenterDisruptionTaintKey = "karpenter.sh/disruption"
+	// KarpenterTerminationFinalizer is kept by karpenter on nodes until their instances are terminated
+	KarpenterTerminationFinalizer = "karpenter.sh/termination"
+)
+
+// RemovedBy returns name of the controller that is removing the node (cluster-autoscaler or karpenter), empty string if there is none
+func (n *Node) RemovedBy() string {
+	for i := range n.Spec.Taints {
+		switch n.Spec.Taints[i].Key {
+		case ClusterAutoscalerTaintKey:
+			return ClusterAutoscaler
+		case KarpenterDisruptedTaintKey, KarpenterDisruptionTaintKey:
+			return Karpenter
+		}
+	}
+	if n.ObjectMeta.DeletionTimestamp != nil {
+		for _, finalizer := range n.ObjectMeta.Finalizers {
+			if finalizer == KarpenterTerminationFinalizer {
+				return Karpenter
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestRemovedBy(t *testing.T) {
+	now := metav1.Now()
+	cases := map[string]struct {
+		node     v1.Node
+		expected string
+	}{
+		"none": {
+			node: v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: TaintKey, Effect: v1.TaintEffectNoSchedule}}}},
+		},
+		"cluster-autoscaler": {
+			node:     v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: ClusterAutoscalerTaintKey, Effect: v1.TaintEffectNoSchedule}}}},
+			expected: ClusterAutoscaler,
+		},
+		"karpenter disrupted": {
+			node:     v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: KarpenterDisruptedTaintKey, Effect: v1.TaintEffectNoSchedule}}}},
+			expected: Karpenter,
+		},
+		"karpenter before v1": {
+			node:     v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: KarpenterDisruptionTaintKey, Value: "disrupting", Effect: v1.TaintEffectNoSchedule}}}},
+			expected: Karpenter,
+		},
+		"karpenter terminating": {
+			node:     v1.Node{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now, Finalizers: []string{KarpenterTerminationFinalizer}}},
+			expected: Karpenter,
+		},
+		"karpenter finalizer without deletion": {
+			node: v1.Node{ObjectMeta: metav1.ObjectMeta{Finalizers: []string{KarpenterTerminationFinalizer}}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.expected, CreateNode(&c.node).RemovedBy())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	DrainStatusRunning   = "running"
+	DrainStatusSucceeded = "succeeded"
+	DrainStatusFailed    = "failed"
+	// DrainOutcomeTimedOut is reported when drain didn't finish before node was moved on
+	DrainOutcomeTimedOut = "timed_out"
+)
+
+// drainAnnotations are annotations describing drain progress
+var drainAnnotations = []string{
+	DrainStatusAnnotation,
+	DrainStartedAnnotation,
+	DrainAttemptsAnnotation,
+	DrainLastErrorAnnotation,
+}
+
+// GetDrainStatus returns status of the drain started by node-undertaker (empty if drain wasn't started)
+func (n *Node) GetDrainStatus() string {
+	return n.ObjectMeta.Annotations[DrainStatusAnnotation]
+}
+
+// RemoveDrainAnnotations removes annotations describing drain progress
+func (n *Node) RemoveDrainAnnotations() {
+	for _, annotation := range drainAnnotations {
+		if _, found := n.ObjectMeta.Annotations[annotation]; found {
+			delete(n.ObjectMeta.Annotations, annotation)
+			n.changed = true
+		}
+	}
+}
+
+// finishDrain records drain outcome on the node and in metrics
+func finishDrain(ctx context.Context, cfg *config.Config, n OBJECT, status string) {
+	collectors.DrainOutcomes.WithLabelValues(status).Inc()
+	err := patchAnnotations(ctx, cfg, n.GetName(), map[string]string{DrainStatusAnnotation: status})
+	if err != nil {
+		log.Errorf("Node %s: couldn't save drain status: %v", n.GetName(), err)
+	}
+}
+
+// patchAnnotations patches node annotations directly, as drain progresses independently of the node update loop
+func patchAnnotations(ctx context.Context, cfg *config.Config, nodeName string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = cfg.K8sClient.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
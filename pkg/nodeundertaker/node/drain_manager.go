@@ -0,0 +1,214 @@
+package node
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/drain"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultDrainRetryInterval = 30 * time.Second
+
+// DrainManager runs at most one drain per node. Drain progress is stored in node annotations,
+// so unfinished drains can be resumed by a new leader.
+type DrainManager struct {
+	mutex         sync.Mutex
+	drains        map[string]*drainJob
+	drainFunc     func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error
+	retryInterval time.Duration
+}
+
+// drainJob is a drain running in the background
+type drainJob struct {
+	cancel context.CancelFunc
+}
+
+func NewDrainManager() *DrainManager {
+	return &DrainManager{
+		drains:        make(map[string]*drainJob),
+		drainFunc:     runNodeDrain,
+		retryInterval: defaultDrainRetryInterval,
+	}
+}
+
+// Start starts drain of the node if it isn't already running. Drain which was started earlier (i.e. by previous leader)
+// and hasn't finished is continued with its start time and attempt count. Returns false if drain is already running.
+func (m *DrainManager) Start(ctx context.Context, cfg *config.Config, n *v1.Node) bool {
+	m.mutex.Lock()
+	if _, found := m.drains[n.ObjectMeta.Name]; found {
+		m.mutex.Unlock()
+		log.Debugf("Node/%s: drain is already running", n.ObjectMeta.Name)
+		return false
+	}
+	drainCtx, cancel := context.WithCancel(ctx)
+	job := &drainJob{cancel: cancel}
+	m.drains[n.ObjectMeta.Name] = job
+	m.mutex.Unlock()
+
+	started, attempts, resumed := getUnfinishedDrain(n)
+	if resumed {
+		log.Infof("Node/%s: resuming drain started at %s after %d attempts", n.ObjectMeta.Name, started.Format(time.RFC3339), attempts)
+	} else {
+		started = time.Now()
+		attempts = 0
+		annotations := map[string]string{
+			DrainStatusAnnotation:   DrainStatusRunning,
+			DrainStartedAnnotation:  started.Format(time.RFC3339),
+			DrainAttemptsAnnotation: strconv.Itoa(attempts),
+		}
+		// drain cordons the node - it's recorded only if node wasn't cordoned before, so it's uncordoned when it recovers
+		if !n.Spec.Unschedulable {
+			annotations[CordonedAnnotation] = "true"
+		}
+		err := patchAnnotations(ctx, cfg, n.ObjectMeta.Name, annotations)
+		if err != nil {
+			log.Errorf("Node %s: couldn't save drain status: %v", n.ObjectMeta.Name, err)
+		}
+	}
+
+	go m.run(drainCtx, cfg, job, CreateNode(n), started, attempts)
+	return true
+}
+
+// Resume starts drains of nodes that are draining, but their drain hasn't finished
+func (m *DrainManager) Resume(ctx context.Context, cfg *config.Config, nodes []*v1.Node) {
+	for i := range nodes {
+		if nodes[i].ObjectMeta.Labels[Label] != NodeDraining {
+			continue
+		}
+		if _, _, unfinished := getUnfinishedDrain(nodes[i]); unfinished {
+			m.Start(ctx, cfg.ForNode(nodes[i].ObjectMeta.Labels), nodes[i])
+		}
+	}
+}
+
+// IsRunning checks if drain of the node is running
+func (m *DrainManager) IsRunning(nodeName string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, found := m.drains[nodeName]
+	return found
+}
+
+// Cancel stops drain of the node if it's running
+func (m *DrainManager) Cancel(nodeName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if job, found := m.drains[nodeName]; found {
+		job.cancel()
+		delete(m.drains, nodeName)
+	}
+}
+
+// forget removes finished job, unless it was already replaced by a new drain of the same node
+func (m *DrainManager) forget(nodeName string, job *drainJob) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job.cancel()
+	if m.drains[nodeName] == job {
+		delete(m.drains, nodeName)
+	}
+}
+
+// run retries drain until it succeeds or hard drain timeout (CloudPrepareTerminationDelay) passes
+func (m *DrainManager) run(ctx context.Context, cfg *config.Config, job *drainJob, n *Node, started time.Time, attempts int) {
+	defer m.forget(n.GetName(), job)
+	deadline := started.Add(time.Duration(cfg.CloudPrepareTerminationDelay) * time.Second)
+	for {
+		attempts++
+		err := patchAnnotations(ctx, cfg, n.GetName(), map[string]string{DrainAttemptsAnnotation: strconv.Itoa(attempts)})
+		if err != nil {
+			log.Errorf("Node %s: couldn't save drain attempts: %v", n.GetName(), err)
+		}
+
+		err = m.drainFunc(ctx, cfg, n.GetName(), attempts)
+		// checked first - drain cancelled because node recovered mustn't write its status back
+		if ctx.Err() != nil {
+			// drain was cancelled or leadership was lost - it will be resumed by next leader if still needed
+			log.Infof("Node/%s: drain interrupted: %v", n.GetName(), ctx.Err())
+			return
+		}
+		if err == nil {
+			finishDrain(ctx, cfg, n, DrainStatusSucceeded)
+			ReportEvent(ctx, cfg, log.InfoLevel, n, "Drain", "Drain Completed", "", "")
+			return
+		}
+
+		log.Warnf("Node/%s: drain attempt %d failed: %v", n.GetName(), attempts, err)
+		patchErr := patchAnnotations(ctx, cfg, n.GetName(), map[string]string{DrainLastErrorAnnotation: err.Error()})
+		if patchErr != nil {
+			log.Errorf("Node %s: couldn't save drain error: %v", n.GetName(), patchErr)
+		}
+		if time.Now().Add(m.retryInterval).After(deadline) {
+			finishDrain(ctx, cfg, n, DrainStatusFailed)
+			ReportEvent(ctx, cfg, log.ErrorLevel, n, "Drain", "Drain Failed", err.Error(), "")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.retryInterval):
+		}
+	}
+}
+
+// getUnfinishedDrain returns start time and attempt count of the drain which was started and hasn't finished
+func getUnfinishedDrain(n *v1.Node) (time.Time, int, bool) {
+	if n.ObjectMeta.Annotations[DrainStatusAnnotation] != DrainStatusRunning {
+		return time.Time{}, 0, false
+	}
+	started, err := time.Parse(time.RFC3339, n.ObjectMeta.Annotations[DrainStartedAnnotation])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	attempts, err := strconv.Atoi(n.ObjectMeta.Annotations[DrainAttemptsAnnotation])
+	if err != nil {
+		attempts = 0
+	}
+	return started, attempts, true
+}
+
+func runNodeDrain(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+	return drain.RunNodeDrain(newDrainHelper(ctx, cfg, nodeName, attempt), nodeName)
+}
+
+// newDrainHelper creates drain helper for given drain attempt (attempts are counted from 1)
+func newDrainHelper(ctx context.Context, cfg *config.Config, nodeName string, attempt int) *drain.Helper {
+	// fallback to delete (which ignores PodDisruptionBudgets) when eviction failed too many times
+	disableEviction := cfg.DrainDisableEvictionAfter > 0 && attempt > cfg.DrainDisableEvictionAfter
+	if disableEviction {
+		log.Infof("Node/%s: eviction failed %d times - deleting pods instead", nodeName, attempt-1)
+	}
+
+	//https://github.com/aws/aws-node-termination-handler/blob/main/pkg/node/node.go#L106
+	return &drain.Helper{
+		Client:                          cfg.K8sClient,
+		Ctx:                             ctx,
+		Force:                           true,
+		GracePeriodSeconds:              cfg.DrainGracePeriod, // -1 - use pods terminationGracePeriodSeconds
+		IgnoreAllDaemonSets:             true,
+		DeleteEmptyDirData:              true,
+		Timeout:                         time.Duration(cfg.DrainTimeout) * time.Second,
+		DisableEviction:                 disableEviction, // true - use delete rather than evict
+		PodSelector:                     cfg.DrainPodSelector,
+		SkipWaitForDeleteTimeoutSeconds: cfg.DrainSkipWaitForDeleteTimeout,
+		OnPodDeletionOrEvictionFinished: func(pod *v1.Pod, usingEviction bool, err error) {
+			operation := "deleted"
+			if usingEviction {
+				operation = "evicted"
+			}
+			if err != nil {
+				log.Warnf("failed to drain node %s: %v", nodeName, err)
+			} else {
+				log.Debugf("Pod %s in namespace: %s %s", pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, operation)
+			}
+		},
+		Out:    log.StandardLogger().Out,
+		ErrOut: log.StandardLogger().Out,
+	}
+}
@@ -0,0 +1,234 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func createDrainManagerTestNode(t *testing.T, cfg *config.Config, annotations map[string]string) *v1.Node {
+	nodev1 := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Labels:      map[string]string{Label: NodeDraining},
+			Annotations: annotations,
+		},
+	}
+	_, err := cfg.K8sClient.CoreV1().Nodes().Create(context.TODO(), nodev1, metav1.CreateOptions{})
+	require.NoError(t, err)
+	return nodev1
+}
+
+func getDrainAnnotations(t *testing.T, cfg *config.Config) map[string]string {
+	result, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	return result.Annotations
+}
+
+func TestDrainManagerStartSucceeds(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+
+	release := make(chan struct{})
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		<-release
+		return nil
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	assert.True(t, m.IsRunning("node1"))
+	// second drain of the same node isn't started
+	assert.False(t, m.Start(context.TODO(), &cfg, nodev1))
+
+	close(release)
+	assert.Eventually(t, func() bool { return !m.IsRunning("node1") }, 5*time.Second, 10*time.Millisecond)
+
+	annotations := getDrainAnnotations(t, &cfg)
+	assert.Equal(t, DrainStatusSucceeded, annotations[DrainStatusAnnotation])
+	assert.Equal(t, "1", annotations[DrainAttemptsAnnotation])
+	assert.Contains(t, annotations, DrainStartedAnnotation)
+	assert.NotContains(t, annotations, DrainLastErrorAnnotation)
+	assert.Equal(t, "true", annotations[CordonedAnnotation])
+}
+
+func TestDrainManagerStartNodeAlreadyCordoned(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+	nodev1.Spec.Unschedulable = true
+
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		return nil
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	assert.Eventually(t, func() bool { return !m.IsRunning("node1") }, 5*time.Second, 10*time.Millisecond)
+
+	// node cordoned by somebody else isn't owned by node-undertaker
+	annotations := getDrainAnnotations(t, &cfg)
+	assert.Equal(t, DrainStatusSucceeded, annotations[DrainStatusAnnotation])
+	assert.NotContains(t, annotations, CordonedAnnotation)
+}
+
+func TestDrainManagerRetries(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+
+	calls := atomic.Int32{}
+	m := NewDrainManager()
+	m.retryInterval = time.Millisecond
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		if calls.Add(1) < 3 {
+			return fmt.Errorf("blocked by pdb")
+		}
+		return nil
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	assert.Eventually(t, func() bool { return !m.IsRunning("node1") }, 5*time.Second, 10*time.Millisecond)
+
+	annotations := getDrainAnnotations(t, &cfg)
+	assert.Equal(t, DrainStatusSucceeded, annotations[DrainStatusAnnotation])
+	assert.Equal(t, "3", annotations[DrainAttemptsAnnotation])
+	assert.Equal(t, "blocked by pdb", annotations[DrainLastErrorAnnotation])
+}
+
+func TestDrainManagerFailsAfterDeadline(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 0}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		return fmt.Errorf("drain error")
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	assert.Eventually(t, func() bool { return !m.IsRunning("node1") }, 5*time.Second, 10*time.Millisecond)
+
+	annotations := getDrainAnnotations(t, &cfg)
+	assert.Equal(t, DrainStatusFailed, annotations[DrainStatusAnnotation])
+	assert.Equal(t, "1", annotations[DrainAttemptsAnnotation])
+	assert.Equal(t, "drain error", annotations[DrainLastErrorAnnotation])
+}
+
+func TestDrainManagerCancel(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	m.Cancel("node1")
+	assert.False(t, m.IsRunning("node1"))
+	time.Sleep(50 * time.Millisecond)
+
+	// interrupted drain is left running, so it can be resumed
+	assert.Equal(t, DrainStatusRunning, getDrainAnnotations(t, &cfg)[DrainStatusAnnotation])
+}
+
+func TestDrainManagerCancelIgnoresSuccess(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	nodev1 := createDrainManagerTestNode(t, &cfg, nil)
+
+	finished := make(chan struct{})
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		<-ctx.Done()
+		defer close(finished)
+		return nil
+	}
+
+	assert.True(t, m.Start(context.TODO(), &cfg, nodev1))
+	m.Cancel("node1")
+	<-finished
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, DrainStatusRunning, getDrainAnnotations(t, &cfg)[DrainStatusAnnotation])
+}
+
+func TestDrainManagerResume(t *testing.T) {
+	cfg := config.Config{K8sClient: fake.NewClientset(), CloudPrepareTerminationDelay: 300}
+	started := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	nodev1 := createDrainManagerTestNode(t, &cfg, map[string]string{
+		DrainStatusAnnotation:   DrainStatusRunning,
+		DrainStartedAnnotation:  started,
+		DrainAttemptsAnnotation: "2",
+	})
+	finished := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node2",
+			Labels:      map[string]string{Label: NodeDraining},
+			Annotations: map[string]string{DrainStatusAnnotation: DrainStatusFailed},
+		},
+	}
+	notDraining := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node3",
+			Annotations: map[string]string{DrainStatusAnnotation: DrainStatusRunning, DrainStartedAnnotation: started},
+		},
+	}
+
+	drained := make(chan string, 3)
+	m := NewDrainManager()
+	m.drainFunc = func(ctx context.Context, cfg *config.Config, nodeName string, attempt int) error {
+		drained <- nodeName
+		return nil
+	}
+
+	m.Resume(context.TODO(), &cfg, []*v1.Node{nodev1, finished, notDraining})
+	assert.Equal(t, "node1", <-drained)
+	assert.Eventually(t, func() bool { return !m.IsRunning("node1") }, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, drained, 0)
+
+	annotations := getDrainAnnotations(t, &cfg)
+	assert.Equal(t, DrainStatusSucceeded, annotations[DrainStatusAnnotation])
+	assert.Equal(t, started, annotations[DrainStartedAnnotation])
+	assert.Equal(t, "3", annotations[DrainAttemptsAnnotation])
+}
+
+func TestNewDrainHelper(t *testing.T) {
+	cfg := config.Config{
+		K8sClient:                     fake.NewClientset(),
+		DrainTimeout:                  120,
+		DrainGracePeriod:              30,
+		DrainDisableEvictionAfter:     2,
+		DrainPodSelector:              "app!=critical",
+		DrainSkipWaitForDeleteTimeout: 60,
+	}
+
+	helper := newDrainHelper(context.TODO(), &cfg, "node1", 1)
+	assert.Equal(t, 120*time.Second, helper.Timeout)
+	assert.Equal(t, 30, helper.GracePeriodSeconds)
+	assert.Equal(t, "app!=critical", helper.PodSelector)
+	assert.Equal(t, 60, helper.SkipWaitForDeleteTimeoutSeconds)
+	assert.False(t, helper.DisableEviction)
+
+	helper = newDrainHelper(context.TODO(), &cfg, "node1", 2)
+	assert.False(t, helper.DisableEviction)
+
+	helper = newDrainHelper(context.TODO(), &cfg, "node1", 3)
+	assert.True(t, helper.DisableEviction)
+}
+
+func TestNewDrainHelperEvictionAlways(t *testing.T) {
+	cfg := config.Config{
+		DrainGracePeriod: -1,
+	}
+
+	helper := newDrainHelper(context.TODO(), &cfg, "node1", 100)
+	assert.False(t, helper.DisableEviction)
+	assert.Equal(t, -1, helper.GracePeriodSeconds)
+}
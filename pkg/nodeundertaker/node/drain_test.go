@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestGetDrainStatus(t *testing.T) {
+	node := CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Annotations: map[string]string{
+				DrainStatusAnnotation:    DrainStatusRunning,
+				DrainStartedAnnotation:   "2024-01-01T00:00:00Z",
+				DrainAttemptsAnnotation:  "1",
+				DrainLastErrorAnnotation: "some error",
+				"other":                  "value",
+			},
+		},
+	})
+	assert.Equal(t, DrainStatusRunning, node.GetDrainStatus())
+
+	node.RemoveDrainAnnotations()
+	assert.Empty(t, node.GetDrainStatus())
+	assert.Equal(t, map[string]string{"other": "value"}, node.Annotations)
+	assert.True(t, node.changed)
+}
+
+func TestGetDrainStatusNone(t *testing.T) {
+	node := CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	})
+	assert.Empty(t, node.GetDrainStatus())
+
+	node.RemoveDrainAnnotations()
+	assert.False(t, node.changed)
+}
+
+func TestFinishDrain(t *testing.T) {
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{Label: NodeDraining},
+		},
+	}
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+	}
+	_, err := cfg.K8sClient.CoreV1().Nodes().Create(context.TODO(), &nodev1, metav1.CreateOptions{})
+	require.NoError(t, err)
+	before := testutil.ToFloat64(collectors.DrainOutcomes.WithLabelValues(DrainStatusFailed))
+
+	finishDrain(context.TODO(), &cfg, CreateNode(&nodev1), DrainStatusFailed)
+
+	result, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, DrainStatusFailed, result.Annotations[DrainStatusAnnotation])
+	assert.Equal(t, NodeDraining, result.Labels[Label])
+	assert.Equal(t, before+1, testutil.ToFloat64(collectors.DrainOutcomes.WithLabelValues(DrainStatusFailed)))
+}
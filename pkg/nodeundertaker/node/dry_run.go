@@ -0,0 +1,169 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"sync"
+)
+
+const (
+	DryRunActionSave                    = "save"
+	DryRunActionDrain                   = "drain"
+	DryRunActionPrepareTermination      = "prepare_termination"
+	DryRunActionRollbackTermination     = "rollback_termination"
+	DryRunActionUncordon                = "uncordon"
+	DryRunActionTerminate               = "terminate"
+	DryRunActionForceDeletePods         = "force_delete_pods"
+	DryRunActionDeleteVolumeAttachments = "delete_volume_attachments"
+)
+
+// DryRunStore keeps simulated node states in memory, so the simulated state machine progresses over time
+type DryRunStore struct {
+	mutex  sync.Mutex
+	states map[string]ownedFields
+}
+
+// DryRunNode is a node that doesn't modify anything - it records would-be changes in DryRunStore
+type DryRunNode struct {
+	*Node
+	store *DryRunStore
+}
+
+func NewDryRunStore() *DryRunStore {
+	return &DryRunStore{
+		states: make(map[string]ownedFields),
+	}
+}
+
+// Forget removes simulated state of the node
+func (s *DryRunStore) Forget(nodeName string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.states, nodeName)
+}
+
+func (s *DryRunStore) get(nodeName string) (ownedFields, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state, found := s.states[nodeName]
+	return state, found
+}
+
+func (s *DryRunStore) set(nodeName string, state ownedFields) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(state.labels) == 0 && len(state.annotations) == 0 && len(state.taints) == 0 {
+		delete(s.states, nodeName)
+		return
+	}
+	s.states[nodeName] = state
+}
+
+// CreateDryRunNode creates node with simulated state applied on top of the real one
+func CreateDryRunNode(n *v1.Node, store *DryRunStore) *DryRunNode {
+	node := DryRunNode{
+		Node:  CreateNode(n),
+		store: store,
+	}
+	if state, found := store.get(node.GetName()); found {
+		node.applyState(state)
+	}
+	return &node
+}
+
+func (n *DryRunNode) applyState(state ownedFields) {
+	for key := range n.ObjectMeta.Labels {
+		if isOwnedKey(key) {
+			delete(n.ObjectMeta.Labels, key)
+		}
+	}
+	for key, value := range state.labels {
+		n.ObjectMeta.Labels[key] = value
+	}
+	for key := range n.ObjectMeta.Annotations {
+		if isOwnedKey(key) {
+			delete(n.ObjectMeta.Annotations, key)
+		}
+	}
+	for key, value := range state.annotations {
+		n.ObjectMeta.Annotations[key] = value
+	}
+	taints := make([]v1.Taint, 0)
+	for i := range n.Spec.Taints {
+		if !isOwnedTaint(n.Spec.Taints[i].Key) {
+			taints = append(taints, n.Spec.Taints[i])
+		}
+	}
+	n.Spec.Taints = append(taints, state.taints...)
+}
+
+// Save records simulated state instead of updating node
+func (n *DryRunNode) Save(ctx context.Context, cfg *config.Config) error {
+	if n.changed {
+		n.store.set(n.GetName(), getOwnedFields(n.Node.Node))
+		n.changed = false
+		n.record(DryRunActionSave, "state saved with label: '%s'", n.GetLabel())
+	}
+	return nil
+}
+
+// StartDrain only records that drain would be started. Simulated drain succeeds immediately
+func (n *DryRunNode) StartDrain(ctx context.Context, cfg *config.Config, drains *DrainManager) {
+	n.ObjectMeta.Annotations[DrainStatusAnnotation] = DrainStatusSucceeded
+	if !n.Spec.Unschedulable {
+		n.ObjectMeta.Annotations[CordonedAnnotation] = "true"
+	}
+	n.changed = true
+	n.record(DryRunActionDrain, "drain would be started")
+}
+
+// Terminate only records that node would be terminated in cloud provider
+func (n *DryRunNode) Terminate(ctx context.Context, cfg *config.Config) (string, error) {
+	n.record(DryRunActionTerminate, "instance %s would be terminated", n.Spec.ProviderID)
+	return "Instance Termination Skipped", nil
+}
+
+// PrepareTermination only records that node would be prepared for termination in cloud provider
+func (n *DryRunNode) PrepareTermination(ctx context.Context, cfg *config.Config) (string, error) {
+	n.record(DryRunActionPrepareTermination, "instance %s would be prepared for termination", n.Spec.ProviderID)
+	return "Instance Preparation For Termination Skipped", nil
+}
+
+// RollbackTermination only records that node would be registered back in traffic sources
+func (n *DryRunNode) RollbackTermination(ctx context.Context, cfg *config.Config) (string, error) {
+	n.record(DryRunActionRollbackTermination, "instance %s would be registered back in traffic sources", n.Spec.ProviderID)
+	delete(n.ObjectMeta.Annotations, DetachedTrafficSourcesAnnotation)
+	n.changed = true
+	return "Instance Termination Rollback Skipped", nil
+}
+
+// Uncordon only records that node cordoned by node-undertaker would be made schedulable again
+func (n *DryRunNode) Uncordon() {
+	if _, found := n.ObjectMeta.Annotations[CordonedAnnotation]; !found {
+		return
+	}
+	delete(n.ObjectMeta.Annotations, CordonedAnnotation)
+	n.changed = true
+	n.record(DryRunActionUncordon, "node would be uncordoned")
+}
+
+// ForceDeleteTerminatingPods only records that stuck pods would be force deleted
+func (n *DryRunNode) ForceDeleteTerminatingPods(ctx context.Context, cfg *config.Config) error {
+	n.record(DryRunActionForceDeletePods, "pods terminating longer than %d seconds would be force deleted", cfg.ForceDeleteTerminatingPodsAfter)
+	return nil
+}
+
+// DeleteVolumeAttachments only records that volume attachments would be deleted
+func (n *DryRunNode) DeleteVolumeAttachments(ctx context.Context, cfg *config.Config) error {
+	n.record(DryRunActionDeleteVolumeAttachments, "volume attachments would be deleted")
+	return nil
+}
+
+func (n *DryRunNode) record(action, format string, args ...interface{}) {
+	collectors.DryRunActions.WithLabelValues(action).Inc()
+	log.Infof("%s%s/%s: %s", DryRunMessagePrefix, n.GetKind(), n.GetName(), fmt.Sprintf(format, args...))
+}
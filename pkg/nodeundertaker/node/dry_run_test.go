@@ -0,0 +1,127 @@
+package node
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestDryRunNodeSaveDoesntUpdateNode(t *testing.T) {
+	nodeName := "dry-run-node1"
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+	}
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(&nodev1),
+		DryRun:    true,
+	}
+	store := NewDryRunStore()
+
+	saves := testutil.ToFloat64(collectors.DryRunActions.WithLabelValues(DryRunActionSave))
+	n := CreateDryRunNode(&nodev1, store)
+	n.Taint()
+	n.SetLabel(NodeTainted)
+	n.SetActionTimestamp(time.Now())
+	err := n.Save(context.TODO(), &cfg)
+	assert.NoError(t, err)
+
+	ret, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, ret.Labels)
+	assert.Empty(t, ret.Annotations)
+	assert.Empty(t, ret.Spec.Taints)
+	assert.Equal(t, saves+1, testutil.ToFloat64(collectors.DryRunActions.WithLabelValues(DryRunActionSave)))
+}
+
+func TestDryRunNodeStateProgresses(t *testing.T) {
+	nodeName := "dry-run-node2"
+	timestamp := time.Now().Add(-time.Hour).Truncate(time.Second)
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   nodeName,
+			Labels: map[string]string{"other": "label"},
+		},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: "sample", Effect: v1.TaintEffectNoSchedule}},
+		},
+	}
+	cfg := config.Config{DryRun: true}
+	store := NewDryRunStore()
+
+	n := CreateDryRunNode(&nodev1, store)
+	assert.Equal(t, NodeHealthy, n.GetLabel())
+	n.Taint()
+	n.SetLabel(NodeTainted)
+	n.SetActionTimestamp(timestamp)
+	assert.NoError(t, n.Save(context.TODO(), &cfg))
+
+	// informer still delivers unmodified node
+	n = CreateDryRunNode(&nodev1, store)
+	assert.Equal(t, NodeTainted, n.GetLabel())
+	ts, err := n.GetActionTimestamp()
+	assert.NoError(t, err)
+	assert.True(t, timestamp.Equal(ts))
+	assert.Len(t, n.Spec.Taints, 2)
+	assert.Equal(t, "label", n.Labels["other"])
+
+	n.Untaint()
+	n.RemoveLabel()
+	n.RemoveActionTimestamp()
+	assert.NoError(t, n.Save(context.TODO(), &cfg))
+
+	n = CreateDryRunNode(&nodev1, store)
+	assert.Equal(t, NodeHealthy, n.GetLabel())
+	assert.Len(t, n.Spec.Taints, 1)
+	_, found := store.get(nodeName)
+	assert.False(t, found)
+}
+
+func TestDryRunNodeCloudProviderNotCalled(t *testing.T) {
+	nodeName := "dry-run-node3"
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+	}
+	// CloudProvider is nil - calling it would panic
+	cfg := config.Config{DryRun: true}
+	n := CreateDryRunNode(&nodev1, NewDryRunStore())
+	actions := []string{DryRunActionPrepareTermination, DryRunActionTerminate, DryRunActionRollbackTermination, DryRunActionDrain}
+	before := make(map[string]float64)
+	for _, action := range actions {
+		before[action] = testutil.ToFloat64(collectors.DryRunActions.WithLabelValues(action))
+	}
+
+	_, err := n.PrepareTermination(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	_, err = n.Terminate(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	_, err = n.RollbackTermination(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	n.StartDrain(context.TODO(), &cfg, NewDrainManager())
+
+	for _, action := range actions {
+		assert.Equal(t, before[action]+1, testutil.ToFloat64(collectors.DryRunActions.WithLabelValues(action)), action)
+	}
+}
+
+func TestDryRunStoreForget(t *testing.T) {
+	nodeName := "dry-run-node4"
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+	}
+	store := NewDryRunStore()
+	n := CreateDryRunNode(&nodev1, store)
+	n.SetLabel(NodeUnhealthy)
+	assert.NoError(t, n.Save(context.TODO(), &config.Config{DryRun: true}))
+
+	store.Forget(nodeName)
+	n = CreateDryRunNode(&nodev1, store)
+	assert.Equal(t, NodeHealthy, n.GetLabel())
+}
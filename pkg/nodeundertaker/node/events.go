@@ -16,9 +16,40 @@ import (
 
 const (
 	ReportingController = "dbschenker.com/node-undertaker"
+	DryRunEventLabel    = "dbschenker.com/node-undertaker-dry-run"
+	DryRunMessagePrefix = "[dry-run] "
 )
 
-func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n NODE, action, reason, reasonDesc, msgOverride string) {
+// OBJECT is kubernetes object that events can be reported for
+type OBJECT interface {
+	GetName() string
+	GetKind() string
+}
+
+// NAMESPACEDOBJECT is implemented by namespaced objects - their events are created in object's namespace
+type NAMESPACEDOBJECT interface {
+	GetNamespace() string
+}
+
+// controllerObject represents node-undertaker itself (by its leader election lease) in cluster-level events
+type controllerObject struct {
+	name string
+}
+
+func (o controllerObject) GetName() string {
+	return o.name
+}
+
+func (o controllerObject) GetKind() string {
+	return "Lease"
+}
+
+// ReportClusterEvent reports event that isn't related to a single node
+func ReportClusterEvent(ctx context.Context, cfg *config.Config, lvl log.Level, action, reason, reasonDesc string) {
+	ReportEvent(ctx, cfg, lvl, controllerObject{name: cfg.LeaseLockName}, action, reason, reasonDesc, "")
+}
+
+func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n OBJECT, action, reason, reasonDesc, msgOverride string) {
 	microTime := metav1.NewMicroTime(time.Now())
 	msg := msgOverride
 	if msg == "" {
@@ -29,8 +60,19 @@ func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n NODE,
 		}
 	}
 
+	var eventLabels map[string]string = nil
+	if cfg.DryRun {
+		msg = DryRunMessagePrefix + msg
+		eventLabels = map[string]string{DryRunEventLabel: "true"}
+	}
+
 	fullMsg := msg
 
+	namespace := cfg.Namespace
+	if namespaced, ok := n.(NAMESPACEDOBJECT); ok && namespaced.GetNamespace() != "" {
+		namespace = namespaced.GetNamespace()
+	}
+
 	if len(msg) >= 1024 {
 		msg = msg[:1024]
 	}
@@ -51,7 +93,8 @@ func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n NODE,
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("node-undertaker.%s", rand.String(16)),
-			Namespace: cfg.Namespace,
+			Namespace: namespace,
+			Labels:    eventLabels,
 		},
 		EventTime: microTime,
 		//Related: - second object related to event
@@ -60,7 +103,7 @@ func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n NODE,
 		Action:              action,
 		Reason:              reason,
 		Regarding: v1.ObjectReference{
-			Namespace: cfg.Namespace,
+			Namespace: namespace,
 			Name:      n.GetName(),
 			Kind:      n.GetKind(),
 		},
@@ -70,7 +113,7 @@ func ReportEvent(ctx context.Context, cfg *config.Config, lvl log.Level, n NODE,
 	}
 
 	log.StandardLogger().Log(lvl, fmt.Sprintf("%s/%s: %s", n.GetKind(), n.GetName(), fullMsg))
-	_, err := cfg.K8sClient.EventsV1().Events(cfg.Namespace).Create(ctx, &evt, metav1.CreateOptions{})
+	_, err := cfg.K8sClient.EventsV1().Events(namespace).Create(ctx, &evt, metav1.CreateOptions{})
 	if err != nil {
 		log.Errorf("Couldn't create event: %s\n due to %v", msg, err)
 	}
@@ -228,3 +228,42 @@ func TestReportEventWithKwok(t *testing.T) {
 	assert.Equal(t, "Warning", ev.Type)
 	assert.NotEmpty(t, ev.Note)
 }
+
+func TestReportEventDryRun(t *testing.T) {
+	namespace := "test"
+	nodeName := "test-node"
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: namespace,
+		Hostname:  "dummy-host",
+		DryRun:    true,
+	}
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+	}
+	node := CreateNode(&nodev1)
+	ReportEvent(context.TODO(), &cfg, logrus.InfoLevel, node, "DummyAction", "DummyReason", "", "")
+
+	events, err := cfg.K8sClient.EventsV1().Events(namespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, events.Items, 1)
+	ev := events.Items[0]
+	assert.Equal(t, "true", ev.ObjectMeta.Labels[DryRunEventLabel])
+	assert.True(t, strings.HasPrefix(ev.Note, DryRunMessagePrefix))
+}
+
+func TestReportEventNamespacedObject(t *testing.T) {
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: "test",
+	}
+	pod := podObject{Pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "app-ns"}}}
+	ReportEvent(context.TODO(), &cfg, logrus.InfoLevel, pod, "DummyAction", "DummyReason", "", "")
+
+	events, err := cfg.K8sClient.EventsV1().Events("app-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "Pod", events.Items[0].Regarding.Kind)
+	assert.Equal(t, "pod1", events.Items[0].Regarding.Name)
+	assert.Equal(t, "app-ns", events.Items[0].Regarding.Namespace)
+}
@@ -0,0 +1,90 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"time"
+)
+
+// podObject is used to report events regarding pods
+type podObject struct {
+	*v1.Pod
+}
+
+func (p podObject) GetKind() string {
+	return "Pod"
+}
+
+// ForceDeleteTerminatingPods deletes (with grace period 0) pods bound to the node that are terminating
+// longer than ForceDeleteTerminatingPodsAfter seconds. Such pods never finish terminating when kubelet is gone.
+func (n *Node) ForceDeleteTerminatingPods(ctx context.Context, cfg *config.Config) error {
+	pods, err := cfg.K8sClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", n.GetName()).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	terminatingBefore := time.Now().Add(-time.Duration(cfg.ForceDeleteTerminatingPodsAfter) * time.Second)
+	gracePeriod := int64(0)
+	errs := make([]error, 0)
+	for i := range pods.Items {
+		pod := podObject{Pod: &pods.Items[i]}
+		if pod.Spec.NodeName != n.GetName() || pod.DeletionTimestamp == nil || pod.DeletionTimestamp.After(terminatingBefore) {
+			continue
+		}
+
+		err = cfg.K8sClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't force delete pod %s/%s: %w", pod.Namespace, pod.Name, err))
+			ReportEvent(ctx, cfg, log.ErrorLevel, pod, "ForceDelete", "Pod Force Delete Failed", err.Error(), "")
+			continue
+		}
+
+		desc := fmt.Sprintf("terminating for more than %d seconds on unhealthy node %s", cfg.ForceDeleteTerminatingPodsAfter, n.GetName())
+		ReportEvent(ctx, cfg, log.WarnLevel, pod, "ForceDelete", "Pod Force Deleted", desc, "")
+		ReportEvent(ctx, cfg, log.WarnLevel, n, "ForceDelete", "Pod Force Deleted", fmt.Sprintf("pod %s/%s %s", pod.Namespace, pod.Name, desc), "")
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteVolumeAttachments deletes VolumeAttachments of the node, so attach-detach controller releases volumes
+func (n *Node) DeleteVolumeAttachments(ctx context.Context, cfg *config.Config) error {
+	volumeAttachments, err := cfg.K8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for i := range volumeAttachments.Items {
+		volumeAttachment := volumeAttachments.Items[i]
+		if volumeAttachment.Spec.NodeName != n.GetName() {
+			continue
+		}
+
+		err = cfg.K8sClient.StorageV1().VolumeAttachments().Delete(ctx, volumeAttachment.Name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't delete volume attachment %s: %w", volumeAttachment.Name, err))
+			ReportEvent(ctx, cfg, log.ErrorLevel, n, "ForceDelete", "Volume Attachment Delete Failed", err.Error(), "")
+			continue
+		}
+
+		volume := ""
+		if volumeAttachment.Spec.Source.PersistentVolumeName != nil {
+			volume = *volumeAttachment.Spec.Source.PersistentVolumeName
+		}
+		ReportEvent(ctx, cfg, log.WarnLevel, n, "ForceDelete", "Volume Attachment Deleted", fmt.Sprintf("volume attachment %s of volume %s deleted", volumeAttachment.Name, volume), "")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func createTerminatingPod(t *testing.T, cfg *config.Config, namespace, name, nodeName string, deletedAgo *time.Duration) {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+	if deletedAgo != nil {
+		deletionTimestamp := metav1.NewTime(time.Now().Add(-*deletedAgo))
+		pod.DeletionTimestamp = &deletionTimestamp
+	}
+	_, err := cfg.K8sClient.CoreV1().Pods(namespace).Create(context.TODO(), &pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+func TestForceDeleteTerminatingPods(t *testing.T) {
+	nodeName := "node1"
+	cfg := config.Config{
+		K8sClient:                       fake.NewClientset(),
+		Namespace:                       "undertaker-ns",
+		ForceDeleteTerminatingPodsAfter: 300,
+	}
+	old := 10 * time.Minute
+	recent := time.Minute
+	createTerminatingPod(t, &cfg, "app-ns", "stuck", nodeName, &old)
+	createTerminatingPod(t, &cfg, "app-ns", "recently-deleted", nodeName, &recent)
+	createTerminatingPod(t, &cfg, "app-ns", "running", nodeName, nil)
+	createTerminatingPod(t, &cfg, "app-ns", "other-node", "node2", &old)
+
+	node := CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}})
+	err := node.ForceDeleteTerminatingPods(context.TODO(), &cfg)
+	assert.NoError(t, err)
+
+	pods, err := cfg.K8sClient.CoreV1().Pods("app-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	names := make([]string, 0)
+	for i := range pods.Items {
+		names = append(names, pods.Items[i].Name)
+	}
+	assert.ElementsMatch(t, []string{"recently-deleted", "running", "other-node"}, names)
+
+	podEvents, err := cfg.K8sClient.EventsV1().Events("app-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	require.Len(t, podEvents.Items, 1)
+	assert.Equal(t, "Pod", podEvents.Items[0].Regarding.Kind)
+	assert.Equal(t, "stuck", podEvents.Items[0].Regarding.Name)
+	assert.Equal(t, "app-ns", podEvents.Items[0].Regarding.Namespace)
+
+	nodeEvents, err := cfg.K8sClient.EventsV1().Events("undertaker-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	require.Len(t, nodeEvents.Items, 1)
+	assert.Equal(t, "Node", nodeEvents.Items[0].Regarding.Kind)
+	assert.Equal(t, nodeName, nodeEvents.Items[0].Regarding.Name)
+}
+
+func TestDeleteVolumeAttachments(t *testing.T) {
+	nodeName := "node1"
+	pvName := "pv1"
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: "undertaker-ns",
+	}
+	for _, va := range []storagev1.VolumeAttachment{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "va1"},
+			Spec:       storagev1.VolumeAttachmentSpec{NodeName: nodeName, Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "va2"},
+			Spec:       storagev1.VolumeAttachmentSpec{NodeName: "node2", Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName}},
+		},
+	} {
+		_, err := cfg.K8sClient.StorageV1().VolumeAttachments().Create(context.TODO(), &va, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	node := CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}})
+	err := node.DeleteVolumeAttachments(context.TODO(), &cfg)
+	assert.NoError(t, err)
+
+	volumeAttachments, err := cfg.K8sClient.StorageV1().VolumeAttachments().List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	require.Len(t, volumeAttachments.Items, 1)
+	assert.Equal(t, "va2", volumeAttachments.Items[0].Name)
+
+	events, err := cfg.K8sClient.EventsV1().Events("undertaker-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Contains(t, events.Items[0].Note, "va1")
+	assert.Contains(t, events.Items[0].Note, pvName)
+}
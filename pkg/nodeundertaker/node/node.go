@@ -2,24 +2,46 @@ package node
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
 	log "github.com/sirupsen/logrus"
 	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/kubectl/pkg/drain"
+	"slices"
+	"strconv"
 	"time"
 )
 
 //go:generate mockgen -destination=./mocks/api_mocks.go github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node NODE
 
 const (
-	TaintKey            = "dbschenker.com/node-undertaker"
-	TaintValue          = ""
-	Label               = "dbschenker.com/node-undertaker"
-	TimestampAnnotation = "dbschenker.com/node-undertaker-timestamp"
+	TaintKey                 = "dbschenker.com/node-undertaker"
+	TaintValue               = ""
+	Label                    = "dbschenker.com/node-undertaker"
+	TimestampAnnotation      = "dbschenker.com/node-undertaker-timestamp"
+	DrainStatusAnnotation    = "dbschenker.com/node-undertaker-drain-status"
+	DrainStartedAnnotation   = "dbschenker.com/node-undertaker-drain-started"
+	DrainAttemptsAnnotation  = "dbschenker.com/node-undertaker-drain-attempts"
+	DrainLastErrorAnnotation = "dbschenker.com/node-undertaker-drain-last-error"
+	UnhealthySinceAnnotation = "dbschenker.com/node-undertaker-unhealthy-since"
+	// DetachedTrafficSourcesAnnotation keeps traffic sources node was detached from, so termination preparation can be rolled back
+	DetachedTrafficSourcesAnnotation = "dbschenker.com/node-undertaker-detached-traffic-sources"
+	// CordonedAnnotation marks node cordoned by node-undertaker - only such node is uncordoned when it recovers
+	CordonedAnnotation = "dbschenker.com/node-undertaker-cordoned"
+	// TerminationAttemptsAnnotation counts failed terminations of the node since it was labeled terminating
+	TerminationAttemptsAnnotation = "dbschenker.com/node-undertaker-termination-attempts"
+	// TerminationNextAttemptAnnotation is the time before which failed termination isn't retried
+	TerminationNextAttemptAnnotation = "dbschenker.com/node-undertaker-termination-next-attempt"
+	TerminationLastErrorAnnotation   = "dbschenker.com/node-undertaker-termination-last-error"
+	// TerminatedAnnotation is the time cloud provider accepted termination of the node - it isn't terminated again
+	TerminatedAnnotation   = "dbschenker.com/node-undertaker-terminated"
+	OutOfServiceTaintKey   = "node.kubernetes.io/out-of-service"
+	OutOfServiceTaintValue = "nodeshutdown"
 )
 
 const (
@@ -30,11 +52,17 @@ const (
 	NodeHealthy                     = ""
 	NodePreparingTermination        = "preparing_termination"
 	NodeTerminationPrepared         = "termination_prepared"
+	NodeOutOfService                = "out_of_service"
+	NodeTerminationFailed           = "termination_failed"
 )
 
 type Node struct {
 	*v1.Node
+	// base is the node as it was read - changes are computed against it when saving
+	base    *v1.Node
 	changed bool
+	// uncordon is set when node should be made schedulable again on save
+	uncordon bool
 }
 
 type NODE interface {
@@ -46,19 +74,38 @@ type NODE interface {
 	SetLabel(label string)
 	SetActionTimestamp(t time.Time)
 	GetActionTimestamp() (time.Time, error)
+	GetUnhealthySince() (time.Time, error)
 	Taint()
+	TaintOutOfService()
 	Untaint()
-	StartDrain(ctx context.Context, cfg *config.Config)
+	StartDrain(ctx context.Context, cfg *config.Config, drains *DrainManager)
+	GetDrainStatus() string
+	RemoveDrainAnnotations()
+	ForceDeleteTerminatingPods(ctx context.Context, cfg *config.Config) error
+	DeleteVolumeAttachments(ctx context.Context, cfg *config.Config) error
 	Terminate(ctx context.Context, cfg *config.Config) (string, error)
+	GetTerminationAttempts() (int, time.Time)
+	SetTerminationAttempts(attempts int, nextAttempt time.Time)
+	SetTerminationLastError(lastErr error)
+	RemoveTerminationAttempts()
+	IsTerminated() bool
+	SetTerminated(terminated time.Time)
 	PrepareTermination(ctx context.Context, cfg *config.Config) (string, error)
+	RollbackTermination(ctx context.Context, cfg *config.Config) (string, error)
+	Uncordon()
 	Save(ctx context.Context, cfg *config.Config) error
 	GetName() string
 	GetKind() string
+	GetLabels() map[string]string
+	GetAnnotations() map[string]string
+	RemovedBy() string
+	GetNode() *v1.Node
 }
 
 func CreateNode(n *v1.Node) *Node {
 	node := Node{
 		Node:    n.DeepCopy(),
+		base:    n.DeepCopy(),
 		changed: false,
 	}
 	if node.Labels == nil {
@@ -76,6 +123,7 @@ func (n *Node) IsGrownUp(cfg *config.Config) bool {
 	return creationTime.Before(&before)
 }
 
+// HasFreshLease checks node's lease in NodeLeaseNamespace. Multiple lease sources are checked by healthcheck.LeaseChecker
 func (n *Node) HasFreshLease(ctx context.Context, cfg *config.Config) (bool, error) {
 	lease, err := n.findLease(ctx, cfg)
 	if errors.IsNotFound(err) {
@@ -85,9 +133,7 @@ func (n *Node) HasFreshLease(ctx context.Context, cfg *config.Config) (bool, err
 		return false, err
 	}
 
-	leaseDuration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
-	isFresh := lease.Spec.RenewTime.Add(leaseDuration).After(time.Now())
-	return isFresh, nil
+	return healthcheck.IsLeaseFresh(lease), nil
 }
 
 func (n *Node) GetLabel() string {
@@ -102,6 +148,12 @@ func (n *Node) RemoveLabel() {
 		delete(n.ObjectMeta.Labels, Label)
 		n.changed = true
 	}
+	for _, annotation := range []string{UnhealthySinceAnnotation, TerminationAttemptsAnnotation, TerminationNextAttemptAnnotation, TerminationLastErrorAnnotation, TerminatedAnnotation} {
+		if _, found := n.ObjectMeta.Annotations[annotation]; found {
+			delete(n.ObjectMeta.Annotations, annotation)
+			n.changed = true
+		}
+	}
 }
 
 func (n *Node) RemoveActionTimestamp() {
@@ -111,11 +163,23 @@ func (n *Node) RemoveActionTimestamp() {
 	}
 }
 
+// SetLabel sets node state. When node leaves healthy state the time is recorded in annotation
 func (n *Node) SetLabel(label string) {
+	if n.GetLabel() == NodeHealthy && label != NodeHealthy {
+		n.ObjectMeta.Annotations[UnhealthySinceAnnotation] = time.Now().Format(time.RFC3339)
+	}
 	n.ObjectMeta.Labels[Label] = label
 	n.changed = true
 }
 
+// GetUnhealthySince returns time when node left healthy state
+func (n *Node) GetUnhealthySince() (time.Time, error) {
+	if val, ok := n.ObjectMeta.Annotations[UnhealthySinceAnnotation]; ok {
+		return time.Parse(time.RFC3339, val)
+	}
+	return time.Now(), fmt.Errorf("node %s doesn't have annotation: %s", n.ObjectMeta.Name, UnhealthySinceAnnotation)
+}
+
 func (n *Node) SetActionTimestamp(t time.Time) {
 	n.changed = true
 	n.ObjectMeta.Annotations[TimestampAnnotation] = t.Format(time.RFC3339)
@@ -146,17 +210,32 @@ func (n *Node) Taint() {
 	n.changed = true
 }
 
+// TaintOutOfService applies out-of-service taint, so volumes are detached and pods are deleted from the node (non-graceful node shutdown)
+func (n *Node) TaintOutOfService() {
+	taint := outOfServiceTaint()
+	for i := range n.Spec.Taints {
+		if n.Spec.Taints[i].Key == taint.Key && n.Spec.Taints[i].Value == taint.Value && n.Spec.Taints[i].Effect == taint.Effect {
+			return
+		}
+	}
+	n.Spec.Taints = append(n.Spec.Taints, taint)
+	n.changed = true
+}
+
+// Untaint removes taints applied by node-undertaker
 func (n *Node) Untaint() {
 	taint := v1.Taint{
 		Key:    TaintKey,
 		Value:  TaintValue,
 		Effect: v1.TaintEffectNoSchedule,
 	}
+	oosTaint := outOfServiceTaint()
 
 	// assume that there is only taint with same set of parameters (api sever should guard this)
 	newTaints := make([]v1.Taint, 0)
 	for i := range n.Spec.Taints {
-		if n.Spec.Taints[i] != taint {
+		isOutOfServiceTaint := n.Spec.Taints[i].Key == oosTaint.Key && n.Spec.Taints[i].Value == oosTaint.Value && n.Spec.Taints[i].Effect == oosTaint.Effect
+		if n.Spec.Taints[i] != taint && !isOutOfServiceTaint {
 			newTaints = append(newTaints, n.Spec.Taints[i])
 		} else {
 			n.changed = true
@@ -167,41 +246,17 @@ func (n *Node) Untaint() {
 	}
 }
 
-func (n *Node) StartDrain(ctx context.Context, cfg *config.Config) {
-	//https://github.com/aws/aws-node-termination-handler/blob/main/pkg/node/node.go#L106
-	drainHelper := drain.Helper{
-		Client:              cfg.K8sClient,
-		Ctx:                 ctx,
-		Force:               true,
-		GracePeriodSeconds:  -1, //use pods terminationGracePeriodSeconds
-		IgnoreAllDaemonSets: true,
-		DeleteEmptyDirData:  true,
-		Timeout:             time.Duration(cfg.CloudTerminationDelay) * time.Second,
-		DisableEviction:     false, // true - use delete rather than evict
-		OnPodDeletionOrEvictionFinished: func(pod *v1.Pod, usingEviction bool, err error) {
-			operation := "deleted"
-			if usingEviction {
-				operation = "evicted"
-			}
-			if err != nil {
-				log.Warnf("failed to drain node %s: %v", n.Node.ObjectMeta.Name, err)
-			} else {
-				log.Debugf("Pod %s in namespace: %s %s", pod.ObjectMeta.Name, pod.ObjectMeta.Namespace, operation)
-			}
-		},
-		Out:    log.StandardLogger().Out,
-		ErrOut: log.StandardLogger().Out,
+func outOfServiceTaint() v1.Taint {
+	return v1.Taint{
+		Key:    OutOfServiceTaintKey,
+		Value:  OutOfServiceTaintValue,
+		Effect: v1.TaintEffectNoExecute,
 	}
+}
 
-	go func() {
-		err := drain.RunNodeDrain(&drainHelper, n.GetName())
-		if err != nil {
-			ReportEvent(ctx, cfg, log.ErrorLevel, n, "Drain", "Drain Failed", err.Error(), "")
-			return
-		}
-		ReportEvent(ctx, cfg, log.InfoLevel, n, "Drain", "Drain Completed", "", "")
-	}()
-
+// StartDrain starts drain of the node in the background. At most one drain per node is running at the same time
+func (n *Node) StartDrain(ctx context.Context, cfg *config.Config, drains *DrainManager) {
+	drains.Start(ctx, cfg, n.Node)
 }
 
 // Terminate deletes node from cloud provider
@@ -209,28 +264,142 @@ func (n *Node) Terminate(ctx context.Context, cfg *config.Config) (string, error
 	return cfg.CloudProvider.TerminateNode(ctx, n.Spec.ProviderID)
 }
 
+// GetTerminationAttempts returns number of failed terminations and time before which termination isn't retried
+func (n *Node) GetTerminationAttempts() (int, time.Time) {
+	attempts, err := strconv.Atoi(n.ObjectMeta.Annotations[TerminationAttemptsAnnotation])
+	if err != nil {
+		attempts = 0
+	}
+	nextAttempt, err := time.Parse(time.RFC3339, n.ObjectMeta.Annotations[TerminationNextAttemptAnnotation])
+	if err != nil {
+		nextAttempt = time.Time{}
+	}
+	return attempts, nextAttempt
+}
+
+// SetTerminationAttempts records number of failed terminations and time before which termination isn't retried
+func (n *Node) SetTerminationAttempts(attempts int, nextAttempt time.Time) {
+	n.ObjectMeta.Annotations[TerminationAttemptsAnnotation] = strconv.Itoa(attempts)
+	n.ObjectMeta.Annotations[TerminationNextAttemptAnnotation] = nextAttempt.Format(time.RFC3339)
+	n.changed = true
+}
+
+// SetTerminationLastError records error of the last failed termination
+func (n *Node) SetTerminationLastError(lastErr error) {
+	n.ObjectMeta.Annotations[TerminationLastErrorAnnotation] = lastErr.Error()
+	n.changed = true
+}
+
+// IsTerminated returns true if cloud provider already accepted termination of the node
+func (n *Node) IsTerminated() bool {
+	_, found := n.ObjectMeta.Annotations[TerminatedAnnotation]
+	return found
+}
+
+// SetTerminated records that cloud provider accepted termination of the node
+func (n *Node) SetTerminated(terminated time.Time) {
+	n.ObjectMeta.Annotations[TerminatedAnnotation] = terminated.Format(time.RFC3339)
+	n.changed = true
+}
+
+// RemoveTerminationAttempts resets counting of failed terminations. Last error is kept
+func (n *Node) RemoveTerminationAttempts() {
+	for _, annotation := range []string{TerminationAttemptsAnnotation, TerminationNextAttemptAnnotation} {
+		if _, found := n.ObjectMeta.Annotations[annotation]; found {
+			delete(n.ObjectMeta.Annotations, annotation)
+			n.changed = true
+		}
+	}
+}
+
+// PrepareTermination prepares node for termination in cloud provider. Traffic sources node was detached from are remembered
+// in annotation (also when preparation failed in the middle), so the preparation can be rolled back
 func (n *Node) PrepareTermination(ctx context.Context, cfg *config.Config) (string, error) {
-	return cfg.CloudProvider.PrepareTermination(ctx, n.Spec.ProviderID)
+	msg, detached, err := cfg.CloudProvider.PrepareTermination(ctx, n.Spec.ProviderID)
+	if len(detached) > 0 {
+		sources := n.getDetachedTrafficSources()
+		for i := range detached {
+			if !slices.Contains(sources, detached[i]) {
+				sources = append(sources, detached[i])
+			}
+		}
+		value, marshalErr := json.Marshal(sources)
+		if marshalErr != nil {
+			return msg, marshalErr
+		}
+		n.ObjectMeta.Annotations[DetachedTrafficSourcesAnnotation] = string(value)
+		n.changed = true
+	}
+	return msg, err
+}
+
+// RollbackTermination registers node back in traffic sources it was detached from while preparing termination
+func (n *Node) RollbackTermination(ctx context.Context, cfg *config.Config) (string, error) {
+	msg, err := cfg.CloudProvider.RollbackTermination(ctx, n.Spec.ProviderID, n.getDetachedTrafficSources())
+	if err != nil {
+		return msg, err
+	}
+	if _, found := n.ObjectMeta.Annotations[DetachedTrafficSourcesAnnotation]; found {
+		delete(n.ObjectMeta.Annotations, DetachedTrafficSourcesAnnotation)
+		n.changed = true
+	}
+	return msg, nil
+}
+
+// getDetachedTrafficSources reads traffic sources node was detached from. Invalid annotation is ignored
+func (n *Node) getDetachedTrafficSources() []cloudproviders.TrafficSource {
+	sources := make([]cloudproviders.TrafficSource, 0)
+	if val, ok := n.ObjectMeta.Annotations[DetachedTrafficSourcesAnnotation]; ok {
+		if err := json.Unmarshal([]byte(val), &sources); err != nil {
+			log.Warnf("%s/%s: can't parse annotation %s: %v", n.GetKind(), n.GetName(), DetachedTrafficSourcesAnnotation, err)
+			return make([]cloudproviders.TrafficSource, 0)
+		}
+	}
+	return sources
+}
+
+// Uncordon makes node schedulable again if it was cordoned by node-undertaker. Nodes cordoned by anybody else are left alone
+func (n *Node) Uncordon() {
+	if _, found := n.ObjectMeta.Annotations[CordonedAnnotation]; !found {
+		return
+	}
+	delete(n.ObjectMeta.Annotations, CordonedAnnotation)
+	n.changed = true
+	if n.Spec.Unschedulable {
+		n.Spec.Unschedulable = false
+		n.uncordon = true
+	}
 }
 
-// TODO: check if saving whole object works fine. Maybe it should be done using patches:  https://stackoverflow.com/questions/57310483/whats-the-shortest-way-to-add-a-label-to-a-pod-using-the-kubernetes-go-client
+// Save patches only node-undertaker owned labels, annotations and taints, so changes made concurrently by other controllers aren't overwritten
 func (n *Node) Save(ctx context.Context, cfg *config.Config) error {
 	if n.changed {
-		_, err := cfg.K8sClient.CoreV1().Nodes().Update(ctx, n.Node, metav1.UpdateOptions{})
-		//TODO maybe Patch instead of Update will work better
-		return err
+		fields := getOwnedFields(n.Node)
+		fields.uncordon = n.uncordon
+		saved, err := patchNode(ctx, cfg, n.base, fields)
+		if err != nil {
+			return err
+		}
+		n.base = saved.DeepCopy()
+		n.changed = false
+		n.uncordon = false
 	}
 	return nil
 }
 
 func (n *Node) findLease(ctx context.Context, cfg *config.Config) (*coordinationv1.Lease, error) {
-	return cfg.K8sClient.CoordinationV1().Leases(cfg.NodeLeaseNamespace).Get(ctx, n.ObjectMeta.Name, metav1.GetOptions{ResourceVersion: "0"})
+	return cfg.LeaseListers.GetLease(ctx, cfg.K8sClient, cfg.NodeLeaseNamespace, n.ObjectMeta.Name)
 }
 
 func (n *Node) GetName() string {
 	return n.ObjectMeta.Name
 }
 
+// GetNode returns node object with changes that are not saved yet
+func (n *Node) GetNode() *v1.Node {
+	return n.Node
+}
+
 func (n *Node) GetKind() string {
 	return "Node"
 }
@@ -3,6 +3,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders/kwok"
 	mockcloudproviders "github.com/dbschenker/node-undertaker/pkg/cloudproviders/mocks"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
@@ -189,7 +190,6 @@ func TestSaveNoChange(t *testing.T) {
 
 func TestSaveChange(t *testing.T) {
 	nodeName := "node1"
-	newProviderId := "test"
 
 	nodev1 := v1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
@@ -205,20 +205,22 @@ func TestSaveChange(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, nodes.Items, 1)
 	assert.Equal(t, nodeName, nodes.Items[0].Name)
-	assert.Empty(t, nodes.Items[0].Spec.ProviderID)
+	assert.Empty(t, nodes.Items[0].Labels)
 
 	node := CreateNode(&nodev1)
-	node.Spec.ProviderID = newProviderId
-	node.changed = true
+	node.SetLabel(NodeUnhealthy)
+	node.Taint()
 
 	err = node.Save(context.TODO(), &cfg)
 	assert.NoError(t, err)
+	assert.False(t, node.changed)
 
 	nodes, err = cfg.K8sClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, err)
 	assert.Len(t, nodes.Items, 1)
 	assert.Equal(t, nodeName, nodes.Items[0].Name)
-	assert.Equal(t, newProviderId, nodes.Items[0].Spec.ProviderID)
+	assert.Equal(t, NodeUnhealthy, nodes.Items[0].Labels[Label])
+	assert.Len(t, nodes.Items[0].Spec.Taints, 1)
 }
 
 func TestTaintNoTaints(t *testing.T) {
@@ -524,6 +526,52 @@ func TestRemoveLabelOk(t *testing.T) {
 	assert.True(t, n.changed)
 }
 
+func TestRemoveLabelRemovesTerminationAttempts(t *testing.T) {
+	n := CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "dummy", Labels: map[string]string{Label: NodeTerminationFailed}}})
+	n.SetTerminationAttempts(3, time.Now())
+	n.SetTerminationLastError(fmt.Errorf("api error"))
+	n.SetTerminated(time.Now())
+	n.RemoveLabel()
+
+	assert.NotContains(t, n.ObjectMeta.Annotations, TerminationAttemptsAnnotation)
+	assert.NotContains(t, n.ObjectMeta.Annotations, TerminationNextAttemptAnnotation)
+	assert.NotContains(t, n.ObjectMeta.Annotations, TerminationLastErrorAnnotation)
+	assert.False(t, n.IsTerminated())
+}
+
+func TestTerminationAttempts(t *testing.T) {
+	n := CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "dummy"}})
+	attempts, nextAttempt := n.GetTerminationAttempts()
+	assert.Equal(t, 0, attempts)
+	assert.True(t, nextAttempt.IsZero())
+
+	next := time.Now().Add(time.Minute).Truncate(time.Second)
+	n.SetTerminationAttempts(2, next)
+	n.SetTerminationLastError(fmt.Errorf("api error"))
+	assert.True(t, n.changed)
+	attempts, nextAttempt = n.GetTerminationAttempts()
+	assert.Equal(t, 2, attempts)
+	assert.True(t, next.Equal(nextAttempt))
+	assert.Equal(t, "api error", n.ObjectMeta.Annotations[TerminationLastErrorAnnotation])
+
+	n.changed = false
+	n.RemoveTerminationAttempts()
+	assert.True(t, n.changed)
+	attempts, nextAttempt = n.GetTerminationAttempts()
+	assert.Equal(t, 0, attempts)
+	assert.True(t, nextAttempt.IsZero())
+	assert.Equal(t, "api error", n.ObjectMeta.Annotations[TerminationLastErrorAnnotation])
+}
+
+func TestTerminated(t *testing.T) {
+	n := CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "dummy"}})
+	assert.False(t, n.IsTerminated())
+
+	n.SetTerminated(time.Now())
+	assert.True(t, n.changed)
+	assert.True(t, n.IsTerminated())
+}
+
 func TestRemoveLabelNotExisting(t *testing.T) {
 	v1node := v1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -582,7 +630,7 @@ func TestPrepareTermination(t *testing.T) {
 	termianteAction := "TestAction"
 	mockCtrl := gomock.NewController(t)
 	cloudProvider := mockcloudproviders.NewMockCLOUDPROVIDER(mockCtrl)
-	cloudProvider.EXPECT().PrepareTermination(gomock.Any(), gomock.Any()).Return(termianteAction, nil).Times(1)
+	cloudProvider.EXPECT().PrepareTermination(gomock.Any(), gomock.Any()).Return(termianteAction, nil, nil).Times(1)
 
 	cfg := config.Config{
 		CloudProvider: cloudProvider,
@@ -596,6 +644,118 @@ func TestPrepareTermination(t *testing.T) {
 	res, err := n.PrepareTermination(context.TODO(), &cfg)
 	assert.NoError(t, err)
 	assert.Equal(t, termianteAction, res)
+	assert.NotContains(t, n.Annotations, DetachedTrafficSourcesAnnotation)
+	assert.False(t, n.changed)
+}
+
+func TestPrepareTerminationRemembersDetachedTrafficSources(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	cloudProvider := mockcloudproviders.NewMockCLOUDPROVIDER(mockCtrl)
+	cloudProvider.EXPECT().PrepareTermination(gomock.Any(), gomock.Any()).Return("TestAction", []cloudproviders.TrafficSource{
+		{Type: "elbv2", Identifier: "arn2"},
+	}, fmt.Errorf("test error")).Times(1)
+
+	cfg := config.Config{
+		CloudProvider: cloudProvider,
+	}
+	v1node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dummy",
+			Annotations: map[string]string{
+				DetachedTrafficSourcesAnnotation: `[{"type":"elb","identifier":"lb1"}]`,
+			},
+		},
+	}
+	n := CreateNode(&v1node)
+	_, err := n.PrepareTermination(context.TODO(), &cfg)
+	assert.Error(t, err)
+	assert.True(t, n.changed)
+	assert.JSONEq(t, `[{"type":"elb","identifier":"lb1"},{"type":"elbv2","identifier":"arn2"}]`, n.Annotations[DetachedTrafficSourcesAnnotation])
+}
+
+func TestRollbackTermination(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	cloudProvider := mockcloudproviders.NewMockCLOUDPROVIDER(mockCtrl)
+	cloudProvider.EXPECT().RollbackTermination(gomock.Any(), "aws:///i-123", []cloudproviders.TrafficSource{
+		{Type: "elb", Identifier: "lb1"},
+	}).Return("TestAction", nil).Times(1)
+
+	cfg := config.Config{
+		CloudProvider: cloudProvider,
+	}
+	v1node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dummy",
+			Annotations: map[string]string{
+				DetachedTrafficSourcesAnnotation: `[{"type":"elb","identifier":"lb1"}]`,
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "aws:///i-123",
+		},
+	}
+	n := CreateNode(&v1node)
+	res, err := n.RollbackTermination(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "TestAction", res)
+	assert.NotContains(t, n.Annotations, DetachedTrafficSourcesAnnotation)
+	assert.True(t, n.changed)
+}
+
+func TestUncordon(t *testing.T) {
+	v1node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dummy",
+			Annotations: map[string]string{CordonedAnnotation: "true"},
+		},
+		Spec: v1.NodeSpec{
+			Unschedulable: true,
+		},
+	}
+	n := CreateNode(&v1node)
+	n.Uncordon()
+	assert.False(t, n.Spec.Unschedulable)
+	assert.NotContains(t, n.Annotations, CordonedAnnotation)
+	assert.True(t, n.uncordon)
+	assert.True(t, n.changed)
+}
+
+func TestUncordonNotCordonedByUndertaker(t *testing.T) {
+	v1node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dummy",
+		},
+		Spec: v1.NodeSpec{
+			Unschedulable: true,
+		},
+	}
+	n := CreateNode(&v1node)
+	n.Uncordon()
+	assert.True(t, n.Spec.Unschedulable)
+	assert.False(t, n.uncordon)
+	assert.False(t, n.changed)
+}
+
+func TestSaveUncordon(t *testing.T) {
+	v1node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "dummy",
+			Annotations: map[string]string{CordonedAnnotation: "true"},
+		},
+		Spec: v1.NodeSpec{
+			Unschedulable: true,
+		},
+	}
+	cfg := config.Config{K8sClient: fake.NewClientset(&v1node)}
+	n := CreateNode(&v1node)
+	n.Uncordon()
+	err := n.Save(context.TODO(), &cfg)
+	require.NoError(t, err)
+
+	saved, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "dummy", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.False(t, saved.Spec.Unschedulable)
+	assert.NotContains(t, saved.Annotations, CordonedAnnotation)
 }
 
 func TestTerminate(t *testing.T) {
@@ -686,7 +846,7 @@ func TestDrain(t *testing.T) {
 	require.NoError(t, err)
 
 	// drain
-	node.StartDrain(ctx, &cfg)
+	node.StartDrain(ctx, &cfg, NewDrainManager())
 	assert.NoError(t, err)
 
 	time.Sleep(time.Duration(cfg.CloudTerminationDelay+20) * time.Second) //sleep longer than drain takes
@@ -771,7 +931,7 @@ func TestDrainWithBlockingPDB(t *testing.T) {
 	require.NoError(t, err)
 
 	// drain
-	node.StartDrain(ctx, &cfg)
+	node.StartDrain(ctx, &cfg, NewDrainManager())
 
 	time.Sleep(time.Duration(cfg.CloudTerminationDelay+20) * time.Second) //sleep longer than drain takes
 
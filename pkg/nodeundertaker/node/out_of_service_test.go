@@ -0,0 +1,64 @@
+package node
+
+import (
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestTaintOutOfService(t *testing.T) {
+	otherTaint := v1.Taint{Key: "other", Effect: v1.TaintEffectNoSchedule}
+	node := CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Spec:       v1.NodeSpec{Taints: []v1.Taint{otherTaint}},
+	})
+
+	node.TaintOutOfService()
+	assert.True(t, node.changed)
+	assert.Equal(t, []v1.Taint{otherTaint, {Key: OutOfServiceTaintKey, Value: OutOfServiceTaintValue, Effect: v1.TaintEffectNoExecute}}, node.Spec.Taints)
+
+	node.changed = false
+	node.TaintOutOfService()
+	assert.False(t, node.changed)
+	assert.Len(t, node.Spec.Taints, 2)
+}
+
+func TestUntaintOutOfService(t *testing.T) {
+	otherTaint := v1.Taint{Key: "other", Effect: v1.TaintEffectNoSchedule}
+	node := CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Spec:       v1.NodeSpec{Taints: []v1.Taint{otherTaint}},
+	})
+	node.Taint()
+	node.TaintOutOfService()
+	node.changed = false
+
+	node.Untaint()
+	assert.True(t, node.changed)
+	assert.Equal(t, []v1.Taint{otherTaint}, node.Spec.Taints)
+}
+
+func TestSetLabelUnhealthySince(t *testing.T) {
+	node := CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	})
+	_, err := node.GetUnhealthySince()
+	assert.Error(t, err)
+
+	node.SetLabel(NodeUnhealthy)
+	since, err := node.GetUnhealthySince()
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), since, 2*time.Second)
+
+	// moving between unhealthy states doesn't change the time
+	node.ObjectMeta.Annotations[UnhealthySinceAnnotation] = "2024-01-01T00:00:00Z"
+	node.SetLabel(NodeTainted)
+	since, err = node.GetUnhealthySince()
+	assert.NoError(t, err)
+	assert.Equal(t, 2024, since.Year())
+
+	node.RemoveLabel()
+	assert.NotContains(t, node.Annotations, UnhealthySinceAnnotation)
+}
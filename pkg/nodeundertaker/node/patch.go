@@ -0,0 +1,180 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"reflect"
+	"strings"
+)
+
+// ownedFields holds node-undertaker owned labels, annotations and taints of the node. Cordon isn't owned - uncordon
+// is only requested explicitly, so a node cordoned concurrently isn't uncordoned when patch is recomputed
+type ownedFields struct {
+	labels      map[string]string
+	annotations map[string]string
+	taints      []v1.Taint
+	uncordon    bool
+}
+
+// getOwnedFields extracts node-undertaker owned labels, annotations and taints from the node
+func getOwnedFields(n *v1.Node) ownedFields {
+	fields := ownedFields{
+		labels:      make(map[string]string),
+		annotations: make(map[string]string),
+		taints:      make([]v1.Taint, 0),
+	}
+	for key, value := range n.ObjectMeta.Labels {
+		if isOwnedKey(key) {
+			fields.labels[key] = value
+		}
+	}
+	for key, value := range n.ObjectMeta.Annotations {
+		if isOwnedKey(key) {
+			fields.annotations[key] = value
+		}
+	}
+	for i := range n.Spec.Taints {
+		if isOwnedTaint(n.Spec.Taints[i].Key) {
+			fields.taints = append(fields.taints, n.Spec.Taints[i])
+		}
+	}
+	return fields
+}
+
+// ownedChanges are changes of owned fields made by node-undertaker. Removed labels and annotations have nil values,
+// taints are nil when they weren't changed
+type ownedChanges struct {
+	labels      map[string]interface{}
+	annotations map[string]interface{}
+	taints      []v1.Taint
+	uncordon    bool
+}
+
+// getOwnedChanges returns changes that turn owned fields of base node into desired ones
+func getOwnedChanges(base *v1.Node, desired ownedFields) ownedChanges {
+	current := getOwnedFields(base)
+	changes := ownedChanges{
+		labels:      diffMap(current.labels, desired.labels),
+		annotations: diffMap(current.annotations, desired.annotations),
+		uncordon:    desired.uncordon,
+	}
+	if !reflect.DeepEqual(current.taints, desired.taints) {
+		changes.taints = desired.taints
+	}
+	return changes
+}
+
+// patchNode sends merge patch with changes of owned fields only. Taints list can't be merged, so when taints are
+// changed resourceVersion is added to the patch - in case of conflict node is fetched again and the same changes
+// are applied on top of it, so owned fields changed concurrently by others (e.g. drain status) are kept.
+func patchNode(ctx context.Context, cfg *config.Config, base *v1.Node, desired ownedFields) (*v1.Node, error) {
+	changes := getOwnedChanges(base, desired)
+	var result *v1.Node
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		patch, err := createPatch(base, changes)
+		if err != nil {
+			return err
+		}
+		if patch == nil {
+			result = base
+			return nil
+		}
+		result, err = cfg.K8sClient.CoreV1().Nodes().Patch(ctx, base.ObjectMeta.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if errors.IsConflict(err) {
+			collectors.NodeSaveConflicts.Inc()
+			log.Debugf("Node/%s: conflict while saving, retrying: %v", base.ObjectMeta.Name, err)
+			latest, getErr := cfg.K8sClient.CoreV1().Nodes().Get(ctx, base.ObjectMeta.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			base = latest
+		}
+		return err
+	})
+	return result, err
+}
+
+// createPatch creates merge patch applying changes to owned fields of base node. Returns nil if there is nothing to change
+func createPatch(base *v1.Node, changes ownedChanges) ([]byte, error) {
+	current := getOwnedFields(base)
+	metadata := make(map[string]interface{})
+	if labels := pendingChanges(current.labels, changes.labels); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := pendingChanges(current.annotations, changes.annotations); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+
+	patch := make(map[string]interface{})
+	spec := make(map[string]interface{})
+	if changes.taints != nil && !reflect.DeepEqual(current.taints, changes.taints) {
+		taints := make([]v1.Taint, 0)
+		for i := range base.Spec.Taints {
+			if !isOwnedTaint(base.Spec.Taints[i].Key) {
+				taints = append(taints, base.Spec.Taints[i])
+			}
+		}
+		spec["taints"] = append(taints, changes.taints...)
+		metadata["resourceVersion"] = base.ObjectMeta.ResourceVersion
+	}
+	if changes.uncordon && base.Spec.Unschedulable {
+		spec["unschedulable"] = false
+	}
+	if len(spec) > 0 {
+		patch["spec"] = spec
+	}
+
+	if len(metadata) > 0 {
+		patch["metadata"] = metadata
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(patch)
+}
+
+// diffMap returns merge patch changing current map to desired one (removed keys are set to nil)
+func diffMap(current, desired map[string]string) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for key := range current {
+		if _, found := desired[key]; !found {
+			diff[key] = nil
+		}
+	}
+	for key, value := range desired {
+		if currentValue, found := current[key]; !found || currentValue != value {
+			diff[key] = value
+		}
+	}
+	return diff
+}
+
+// pendingChanges returns changes that aren't applied to current map yet
+func pendingChanges(current map[string]string, changes map[string]interface{}) map[string]interface{} {
+	pending := make(map[string]interface{})
+	for key, value := range changes {
+		currentValue, found := current[key]
+		if value == nil && found || value != nil && (!found || currentValue != value.(string)) {
+			pending[key] = value
+		}
+	}
+	return pending
+}
+
+// isOwnedKey checks if label or annotation key is managed by node-undertaker
+func isOwnedKey(key string) bool {
+	return strings.HasPrefix(key, Label)
+}
+
+// isOwnedTaint checks if taint key is managed by node-undertaker
+func isOwnedTaint(key string) bool {
+	return isOwnedKey(key) || key == OutOfServiceTaintKey
+}
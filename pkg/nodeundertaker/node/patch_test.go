@@ -0,0 +1,212 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+func TestCreatePatchNoChange(t *testing.T) {
+	nodev1 := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{Label: NodeUnhealthy, "other": "value"},
+		},
+	}
+	patch, err := createPatch(nodev1, getOwnedChanges(nodev1, getOwnedFields(nodev1)))
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+func TestCreatePatchLabelsOnly(t *testing.T) {
+	nodev1 := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "node1",
+			ResourceVersion: "10",
+			Labels:          map[string]string{Label: NodeUnhealthy, "other": "value"},
+			Annotations:     map[string]string{TimestampAnnotation: "some-time"},
+		},
+	}
+	node := CreateNode(nodev1)
+	node.SetLabel(NodeTainted)
+	node.RemoveActionTimestamp()
+
+	patch, err := createPatch(nodev1, getOwnedChanges(nodev1, getOwnedFields(node.Node)))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"labels":{"dbschenker.com/node-undertaker":"tainted"},"annotations":{"dbschenker.com/node-undertaker-timestamp":null}}}`, string(patch))
+}
+
+func TestCreatePatchTaints(t *testing.T) {
+	otherTaint := v1.Taint{Key: "other", Effect: v1.TaintEffectNoExecute}
+	nodev1 := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "node1",
+			ResourceVersion: "10",
+		},
+		Spec: v1.NodeSpec{Taints: []v1.Taint{otherTaint}},
+	}
+	node := CreateNode(nodev1)
+	node.Taint()
+
+	patch, err := createPatch(nodev1, getOwnedChanges(nodev1, getOwnedFields(node.Node)))
+	assert.NoError(t, err)
+
+	result := v1.Node{}
+	assert.NoError(t, json.Unmarshal(patch, &result))
+	assert.Equal(t, "10", result.ResourceVersion)
+	assert.Equal(t, []v1.Taint{otherTaint, {Key: TaintKey, Value: TaintValue, Effect: v1.TaintEffectNoSchedule}}, result.Spec.Taints)
+	assert.Empty(t, result.Labels)
+}
+
+func TestCreatePatchUncordon(t *testing.T) {
+	nodev1 := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+		},
+		Spec: v1.NodeSpec{Unschedulable: true},
+	}
+	changes := getOwnedChanges(nodev1, getOwnedFields(nodev1))
+
+	patch, err := createPatch(nodev1, changes)
+	assert.NoError(t, err)
+	assert.Nil(t, patch, "uncordon is applied only when explicitly requested")
+
+	changes.uncordon = true
+	patch, err = createPatch(nodev1, changes)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"unschedulable":false}}`, string(patch))
+
+	nodev1.Spec.Unschedulable = false
+	patch, err = createPatch(nodev1, changes)
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+func TestSaveKeepsConcurrentChanges(t *testing.T) {
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	}
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+	}
+	_, err := cfg.K8sClient.CoreV1().Nodes().Create(context.TODO(), &nodev1, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	node := CreateNode(&nodev1)
+
+	// label added by someone else after node was read
+	concurrent := nodev1.DeepCopy()
+	concurrent.Labels = map[string]string{"other": "value"}
+	_, err = cfg.K8sClient.CoreV1().Nodes().Update(context.TODO(), concurrent, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	node.SetLabel(NodeUnhealthy)
+	node.SetActionTimestamp(time.Now())
+	err = node.Save(context.TODO(), &cfg)
+	assert.NoError(t, err)
+
+	result, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "value", result.Labels["other"])
+	assert.Equal(t, NodeUnhealthy, result.Labels[Label])
+	assert.Contains(t, result.Annotations, TimestampAnnotation)
+}
+
+func TestSaveRetriesOnConflict(t *testing.T) {
+	otherTaint := v1.Taint{Key: "other", Effect: v1.TaintEffectNoSchedule}
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	}
+	k8sClient := fake.NewClientset()
+	cfg := config.Config{
+		K8sClient: k8sClient,
+	}
+	_, err := cfg.K8sClient.CoreV1().Nodes().Create(context.TODO(), &nodev1, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	node := CreateNode(&nodev1)
+
+	// taint added by someone else after node was read
+	concurrent := nodev1.DeepCopy()
+	concurrent.Spec.Taints = []v1.Taint{otherTaint}
+	_, err = cfg.K8sClient.CoreV1().Nodes().Update(context.TODO(), concurrent, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	patches := 0
+	k8sClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches++
+		if patches == 1 {
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "nodes"}, "node1", nil)
+		}
+		return false, nil, nil
+	})
+	conflictsBefore := testutil.ToFloat64(collectors.NodeSaveConflicts)
+
+	node.Taint()
+	err = node.Save(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, patches)
+	assert.Equal(t, conflictsBefore+1, testutil.ToFloat64(collectors.NodeSaveConflicts))
+
+	result, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []v1.Taint{otherTaint, {Key: TaintKey, Value: TaintValue, Effect: v1.TaintEffectNoSchedule}}, result.Spec.Taints)
+}
+
+// owned annotations written concurrently (e.g. by drain) aren't removed when patch is applied on top of refetched node
+func TestSaveRetryKeepsConcurrentOwnedAnnotations(t *testing.T) {
+	nodev1 := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Labels:      map[string]string{Label: NodeTainted},
+			Annotations: map[string]string{TimestampAnnotation: "some-time"},
+		},
+	}
+	k8sClient := fake.NewClientset()
+	cfg := config.Config{
+		K8sClient: k8sClient,
+	}
+	_, err := cfg.K8sClient.CoreV1().Nodes().Create(context.TODO(), &nodev1, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	node := CreateNode(&nodev1)
+
+	// drain status written after node was read
+	err = patchAnnotations(context.TODO(), &cfg, "node1", map[string]string{DrainStatusAnnotation: DrainStatusRunning})
+	require.NoError(t, err)
+
+	patches := 0
+	k8sClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches++
+		if patches == 1 {
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "nodes"}, "node1", nil)
+		}
+		return false, nil, nil
+	})
+
+	node.Taint()
+	node.SetLabel(NodeDraining)
+	err = node.Save(context.TODO(), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, patches)
+
+	result, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, DrainStatusRunning, result.Annotations[DrainStatusAnnotation])
+	assert.Equal(t, "some-time", result.Annotations[TimestampAnnotation])
+	assert.Equal(t, NodeDraining, result.Labels[Label])
+	assert.Len(t, result.Spec.Taints, 1)
+}
@@ -6,11 +6,15 @@ import (
 	"github.com/dbschenker/node-undertaker/cmd/node-undertaker/flags"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders/aws"
+	"github.com/dbschenker/node-undertaker/pkg/cloudproviders/karpenter"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders/kind"
 	"github.com/dbschenker/node-undertaker/pkg/cloudproviders/kwok"
 	"github.com/dbschenker/node-undertaker/pkg/kubeclient"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/nodeupdatehandler"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/policycontroller"
 	"github.com/dbschenker/node-undertaker/pkg/observability"
 	"github.com/dbschenker/node-undertaker/pkg/observability/metrics"
 	log "github.com/sirupsen/logrus"
@@ -19,10 +23,12 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // Execute executes node-undertaker logic
@@ -42,6 +48,9 @@ func Execute() error {
 func executeWithContext(ctx context.Context, getk8sClient func() (kubernetes.Interface, string, error), cancel func()) error {
 	// initialize config
 	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
 
 	// k8s ClientSet
 	k8sClient, currentNamespace, err := getk8sClient()
@@ -49,10 +58,14 @@ func executeWithContext(ctx context.Context, getk8sClient func() (kubernetes.Int
 		return err
 	}
 	cfg.SetK8sClient(k8sClient, currentNamespace)
-
-	if err != nil {
-		return err
+	if cfg.PolicyResources || cfg.RemediationResources || viper.GetString(flags.CloudProviderFlag) == "karpenter" {
+		dynamicClient, err := kubeclient.GetDynamicClient()
+		if err != nil {
+			return err
+		}
+		cfg.DynamicClient = dynamicClient
 	}
+
 	cloudProvider, err := getCloudProvider(ctx, cfg)
 	if err != nil {
 		return err
@@ -73,7 +86,7 @@ func executeWithContext(ctx context.Context, getk8sClient func() (kubernetes.Int
 			ctx2,
 			cfg,
 			func(ctx3 context.Context) {
-				startLogic(ctx2, cfg, nodeupdatehandler.GetDefaultUpdateHandlerFuncs(ctx, cfg), cancel)
+				startLogic(ctx2, cfg, nodeupdatehandler.NewController(cfg, node.NewDrainManager()), cancel)
 			},
 			cancel)
 		return nil
@@ -113,7 +126,7 @@ func startServer(ctx context.Context, cfg *config.Config, observabilityServer ob
 	return g.Wait()
 }
 
-func startLogic(ctx context.Context, cfg *config.Config, handlerFuncs cache.ResourceEventHandlerFuncs, cancel func()) {
+func startLogic(ctx context.Context, cfg *config.Config, controller *nodeupdatehandler.Controller, cancel func()) {
 	tweakListOptionsFunc := func(opts *v1.ListOptions) {
 		opts.LabelSelector = cfg.NodeSelector.String()
 	}
@@ -123,16 +136,40 @@ func startLogic(ctx context.Context, cfg *config.Config, handlerFuncs cache.Reso
 	nodeInformer := factory.Core().V1().Nodes()
 	informer := nodeInformer.Informer()
 	nodeLister := nodeInformer.Lister()
+	cfg.NodeLister = nodeLister
 	factory.Start(ctx.Done())
 	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
 		log.Errorf("Timed out waiting for caches to sync")
 		cancel()
 	}
-	_, err := informer.AddEventHandler(handlerFuncs)
+	// policies are loaded first - leases in namespaces they use are watched too
+	if cfg.PolicyResources {
+		policyController := policycontroller.New(cfg, cfg.DynamicClient)
+		err := policyController.Start(ctx)
+		if err != nil {
+			log.Errorf("Error occured while starting policy controller: %v", err)
+			cancel()
+		}
+		_, err = informer.AddEventHandler(policyController.NodeHandlerFuncs())
+		if err != nil {
+			log.Errorf("Error occured while adding policy controller event handler funcs: %v", err)
+			cancel()
+		}
+	}
+	err := startLeaseInformers(ctx, cfg, controller)
+	if err != nil {
+		log.Errorf("Error occured while starting lease informers: %v", err)
+		cancel()
+	}
+	if !cfg.DryRun {
+		resumeDrains(ctx, cfg, controller.Drains(), nodeLister)
+	}
+	_, err = informer.AddEventHandler(controller.HandlerFuncs())
 	if err != nil {
 		log.Errorf("Error occured while adding event handler funcs: %v", err)
 		cancel()
 	}
+	go controller.Run(ctx, cfg.Workers)
 
 	unregisterMetrics := metrics.Initialize(nodeLister)
 	// unregister metrics so there is always only one metric - needed for testing
@@ -142,6 +179,64 @@ func startLogic(ctx context.Context, cfg *config.Config, handlerFuncs cache.Reso
 	}
 }
 
+// leaseCacheSyncTimeout is time to wait for leases cache to sync, e.g. it never syncs when RBAC doesn't allow to watch leases
+const leaseCacheSyncTimeout = time.Minute
+
+// startLeaseInformers watches leases in namespaces containing node leases, so their freshness is read from cache
+// and lease changes trigger evaluation of nodes. Leases from namespaces that can't be watched are read from API server
+func startLeaseInformers(ctx context.Context, cfg *config.Config, controller *nodeupdatehandler.Controller) error {
+	watched := cfg.WatchedLeaseSources()
+	if cfg.PolicyStore != nil {
+		namespaces := make([]string, 0, len(watched))
+		for namespace := range watched {
+			namespaces = append(namespaces, namespace)
+		}
+		// NodeRemediationPolicy resources created later can't use namespaces that aren't watched
+		cfg.PolicyStore.SetLeaseNamespaces(namespaces)
+	}
+	listers := make(healthcheck.LeaseListers)
+	leaseInformers := make(map[string]cache.SharedIndexInformer)
+	for namespace := range watched {
+		namespaceCtx, cancel := context.WithCancel(ctx)
+		factory := informers.NewSharedInformerFactoryWithOptions(cfg.K8sClient, cfg.InformerResync, informers.WithNamespace(namespace))
+		leaseInformer := factory.Coordination().V1().Leases()
+		informer := leaseInformer.Informer()
+		factory.Start(namespaceCtx.Done())
+
+		syncCtx, syncCancel := context.WithTimeout(ctx, leaseCacheSyncTimeout)
+		synced := cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced)
+		syncCancel()
+		if !synced {
+			cancel()
+			log.Warnf("Leases cache in namespace %s didn't sync, leases will be read from API server", namespace)
+			continue
+		}
+		// informer runs until ctx is done
+		context.AfterFunc(ctx, cancel)
+		leaseInformers[namespace] = informer
+		listers[namespace] = leaseInformer.Lister().Leases(namespace)
+	}
+	// listers have to be set before handlers are added - handlers read them
+	cfg.LeaseListers = listers
+	for namespace, informer := range leaseInformers {
+		_, err := informer.AddEventHandler(controller.LeaseHandlerFuncs(watched[namespace]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeDrains continues drains that were started, but not finished by previous leader
+func resumeDrains(ctx context.Context, cfg *config.Config, drains *node.DrainManager, nodeLister corelisters.NodeLister) {
+	nodes, err := nodeLister.List(cfg.NodeSelector)
+	if err != nil {
+		log.Errorf("Couldn't list nodes to resume drains: %v", err)
+		return
+	}
+	drains.Resume(ctx, cfg, nodes)
+}
+
 func getCloudProvider(ctx context.Context, cfg *config.Config) (cloudproviders.CLOUDPROVIDER, error) {
 	switch cloudProviderName := viper.GetString(flags.CloudProviderFlag); cloudProviderName {
 	case "aws":
@@ -154,6 +249,9 @@ func getCloudProvider(ctx context.Context, cfg *config.Config) (cloudproviders.C
 	case "kwok":
 		cloudProvider, err := kwok.CreateCloudProvider(ctx, cfg)
 		return cloudProvider, err
+	case "karpenter":
+		cloudProvider, err := karpenter.CreateCloudProvider(ctx, cfg)
+		return cloudProvider, err
 	default:
 		return nil, fmt.Errorf("Unknown cloud provider: %s", cloudProviderName)
 	}
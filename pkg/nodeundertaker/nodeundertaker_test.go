@@ -215,3 +215,22 @@ func TestExecuteWithContextK8sErr(t *testing.T) {
 	}, cancel)
 	assert.Error(t, err)
 }
+
+func TestExecuteWithContextConfigErr(t *testing.T) {
+	viper.Set(flags.LeaseLockNameFlag, "test-lease")
+	viper.Set(flags.PortFlag, 0) //use random port
+	viper.Set(flags.CloudProviderFlag, "kwok")
+	viper.Set(flags.DrainTimeoutFlag, -1)
+	defer viper.Set(flags.DrainTimeoutFlag, 0)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	k8sClientCalled := false
+	err := executeWithContext(ctx, func() (kubernetes.Interface, string, error) {
+		k8sClientCalled = true
+		return fake.NewClientset(), "test-ns", nil
+	}, cancel)
+	assert.ErrorContains(t, err, flags.DrainTimeoutFlag)
+	assert.False(t, k8sClientCalled)
+}
@@ -0,0 +1,146 @@
+package nodeupdatehandler
+
+import (
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// reservationTimeout limits how long reservation is counted when node lister doesn't show the node as disrupted
+	reservationTimeout = time.Minute
+	// budgetRetryInterval is how often node held by exhausted disruption budget is processed again, so freed budget is used promptly
+	budgetRetryInterval = 15 * time.Second
+)
+
+// disruptedStates are states of nodes that count towards disruption budget
+var disruptedStates = map[string]bool{
+	nodepkg.NodeTainted:              true,
+	nodepkg.NodeDraining:             true,
+	nodepkg.NodeOutOfService:         true,
+	nodepkg.NodePreparingTermination: true,
+	nodepkg.NodeTerminationPrepared:  true,
+	nodepkg.NodeTerminating:          true,
+	nodepkg.NodeTerminationFailed:    true,
+}
+
+// reservations are nodes being disrupted by this process, that aren't shown as disrupted by node lister yet. They are counted
+// towards disruption budget, so concurrent workers can't exceed it before the lister observes their changes
+var reservations = struct {
+	sync.Mutex
+	nodes map[string]time.Time
+}{nodes: make(map[string]time.Time)}
+
+// reserveDisruptionBudget checks if one more node can be disrupted and reserves place in the budget for it.
+// Global budget is counted for all watched nodes, budget of a policy only for nodes matching the policy - both have to allow it.
+// Returns false and description when budget is exhausted
+func reserveDisruptionBudget(cfg *config.Config, nodeName string) (bool, string, error) {
+	globalBudget, policyBudget := hasGlobalBudget(cfg), hasPolicyBudget(cfg)
+	if !globalBudget && !policyBudget {
+		return true, "", nil
+	}
+	reservations.Lock()
+	defer reservations.Unlock()
+	nodes, err := listWatchedNodes(cfg)
+	if err != nil {
+		return false, "", err
+	}
+
+	now := time.Now()
+	for name, reserved := range reservations.nodes {
+		if now.Sub(reserved) > reservationTimeout {
+			delete(reservations.nodes, name)
+		}
+	}
+	disrupted := make(map[string]bool)
+	for i := range nodes {
+		name := nodes[i].Name
+		// createNode is used so simulated state is counted in dry-run mode
+		if disruptedStates[createNode(cfg, nodes[i]).GetLabel()] {
+			disrupted[name] = true
+			// lister observed the change - node is already counted
+			delete(reservations.nodes, name)
+		} else if _, reserved := reservations.nodes[name]; reserved && name != nodeName {
+			disrupted[name] = true
+		}
+	}
+
+	if globalBudget {
+		budget := disruptionBudget(cfg.MaxDisruptedNodes, cfg.MaxDisruptedNodesPercentage, len(nodes))
+		if len(disrupted) >= budget {
+			return false, fmt.Sprintf("%d of %d nodes are already disrupted (budget: %d)", len(disrupted), len(nodes), budget), nil
+		}
+	}
+	if policyBudget {
+		policyNodes := filterPolicyBudgetNodes(cfg, nodes)
+		policyDisrupted := 0
+		for i := range policyNodes {
+			if disrupted[policyNodes[i].Name] {
+				policyDisrupted++
+			}
+		}
+		budget := disruptionBudget(cfg.PolicyMaxDisruptedNodes, cfg.PolicyMaxDisruptedNodesPercentage, len(policyNodes))
+		if policyDisrupted >= budget {
+			return false, fmt.Sprintf("%d of %d nodes of policy %s are already disrupted (budget: %d)", policyDisrupted, len(policyNodes), cfg.PolicyName, budget), nil
+		}
+	}
+	reservations.nodes[nodeName] = now
+	return true, "", nil
+}
+
+func hasGlobalBudget(cfg *config.Config) bool {
+	return cfg.MaxDisruptedNodes > 0 || cfg.MaxDisruptedNodesPercentage > 0
+}
+
+func hasPolicyBudget(cfg *config.Config) bool {
+	return cfg.DisruptionBudgetSelector != nil && (cfg.PolicyMaxDisruptedNodes > 0 || cfg.PolicyMaxDisruptedNodesPercentage > 0)
+}
+
+// releaseDisruptionBudget removes reservation of the node, e.g. when its disruption wasn't saved
+func releaseDisruptionBudget(nodeName string) {
+	reservations.Lock()
+	defer reservations.Unlock()
+	delete(reservations.nodes, nodeName)
+}
+
+// listWatchedNodes lists nodes matching node selector from informer's cache
+func listWatchedNodes(cfg *config.Config) ([]*v1.Node, error) {
+	if cfg.NodeLister == nil {
+		return nil, fmt.Errorf("node lister is not initialized")
+	}
+	selector := cfg.NodeSelector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	return cfg.NodeLister.List(selector)
+}
+
+// filterPolicyBudgetNodes returns nodes counted towards budget of a policy - nodes matching the policy
+func filterPolicyBudgetNodes(cfg *config.Config, nodes []*v1.Node) []*v1.Node {
+	ret := make([]*v1.Node, 0, len(nodes))
+	for i := range nodes {
+		if cfg.DisruptionBudgetSelector.Matches(labels.Set(nodes[i].ObjectMeta.Labels)) {
+			ret = append(ret, nodes[i])
+		}
+	}
+	return ret
+}
+
+// disruptionBudget returns maximum number of nodes that can be disrupted at the same time
+func disruptionBudget(maxNodes, maxPercentage, nodesCount int) int {
+	budget := math.MaxInt
+	if maxNodes > 0 {
+		budget = maxNodes
+	}
+	if maxPercentage > 0 {
+		// rounded up, the same way as maxUnavailable in PodDisruptionBudget
+		percentageBudget := int(math.Ceil(float64(nodesCount*maxPercentage) / 100))
+		budget = min(budget, percentageBudget)
+	}
+	return budget
+}
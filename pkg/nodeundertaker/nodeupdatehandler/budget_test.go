@@ -0,0 +1,241 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+)
+
+func createNodeLister(t *testing.T, nodes ...*v1.Node) corelisters.NodeLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for i := range nodes {
+		require.NoError(t, indexer.Add(nodes[i]))
+	}
+	return corelisters.NewNodeLister(indexer)
+}
+
+func createListedNode(name, label string, nodeLabels map[string]string) *v1.Node {
+	ret := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{},
+		},
+	}
+	for k, v := range nodeLabels {
+		ret.Labels[k] = v
+	}
+	if label != nodepkg.NodeHealthy {
+		ret.Labels[nodepkg.Label] = label
+	}
+	return &ret
+}
+
+func TestDisruptionBudget(t *testing.T) {
+	tests := []struct {
+		name       string
+		absolute   int
+		percentage int
+		nodesCount int
+		expected   int
+	}{
+		{name: "absolute", absolute: 3, nodesCount: 10, expected: 3},
+		{name: "percentage rounded up", percentage: 15, nodesCount: 10, expected: 2},
+		{name: "percentage small cluster", percentage: 10, nodesCount: 3, expected: 1},
+		{name: "lower wins", absolute: 3, percentage: 50, nodesCount: 4, expected: 2},
+		{name: "lower wins absolute", absolute: 1, percentage: 50, nodesCount: 4, expected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, disruptionBudget(tt.absolute, tt.percentage, tt.nodesCount))
+		})
+	}
+}
+
+func TestReserveDisruptionBudgetDisabled(t *testing.T) {
+	cfg := config.Config{}
+	ok, _, err := reserveDisruptionBudget(&cfg, "node1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestReserveDisruptionBudgetNoLister(t *testing.T) {
+	cfg := config.Config{MaxDisruptedNodes: 1}
+	ok, _, err := reserveDisruptionBudget(&cfg, "node1")
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestReserveDisruptionBudget(t *testing.T) {
+	defer releaseDisruptionBudget("node2")
+	lister := createNodeLister(t,
+		createListedNode("node1", nodepkg.NodeHealthy, nil),
+		createListedNode("node2", nodepkg.NodeUnhealthy, nil),
+		createListedNode("node3", nodepkg.NodeTainted, nil),
+		createListedNode("node4", nodepkg.NodeTerminating, nil),
+		createListedNode("node5", nodepkg.NodeDraining, map[string]string{"pool": "other"}),
+	)
+	selector, err := labels.Parse("pool!=other")
+	require.NoError(t, err)
+
+	cfg := config.Config{MaxDisruptedNodes: 2, NodeLister: lister, NodeSelector: selector}
+	ok, desc, err := reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "2 of 4 nodes are already disrupted (budget: 2)", desc)
+
+	cfg.MaxDisruptedNodes = 3
+	ok, _, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	cfg.MaxDisruptedNodesPercentage = 50
+	ok, _, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// budget of a policy counts only nodes matching it
+	cfg = config.Config{PolicyName: "other", PolicyMaxDisruptedNodes: 1, NodeLister: lister, NodeSelector: labels.Everything(), DisruptionBudgetSelector: labels.SelectorFromSet(labels.Set{"pool": "other"})}
+	ok, desc, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "1 of 1 nodes of policy other are already disrupted (budget: 1)", desc)
+}
+
+// policy budget doesn't replace global budget - nodes of several policies can't exceed it together
+func TestReserveDisruptionBudgetPolicyAndGlobal(t *testing.T) {
+	defer releaseDisruptionBudget("node1")
+	lister := createNodeLister(t,
+		createListedNode("node1", nodepkg.NodeUnhealthy, map[string]string{"pool": "spot"}),
+		createListedNode("node2", nodepkg.NodeHealthy, map[string]string{"pool": "spot"}),
+		createListedNode("node3", nodepkg.NodeTainted, map[string]string{"pool": "system"}),
+		createListedNode("node4", nodepkg.NodeDraining, map[string]string{"pool": "system"}),
+	)
+	cfg := config.Config{
+		MaxDisruptedNodes:        2,
+		PolicyName:               "spot",
+		PolicyMaxDisruptedNodes:  2,
+		DisruptionBudgetSelector: labels.SelectorFromSet(labels.Set{"pool": "spot"}),
+		NodeLister:               lister,
+	}
+
+	ok, desc, err := reserveDisruptionBudget(&cfg, "node1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "2 of 4 nodes are already disrupted (budget: 2)", desc)
+
+	cfg.MaxDisruptedNodes = 3
+	ok, _, err = reserveDisruptionBudget(&cfg, "node1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// reservation of node1 is counted in both budgets
+	cfg.MaxDisruptedNodes = 0
+	cfg.PolicyMaxDisruptedNodes = 1
+	ok, desc, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "1 of 2 nodes of policy spot are already disrupted (budget: 1)", desc)
+}
+
+// nodes tainted concurrently are counted before lister observes their labels
+func TestReserveDisruptionBudgetConcurrent(t *testing.T) {
+	defer releaseDisruptionBudget("node1")
+	defer releaseDisruptionBudget("node2")
+	cfg := config.Config{
+		MaxDisruptedNodes: 1,
+		NodeLister: createNodeLister(t,
+			createListedNode("node1", nodepkg.NodeUnhealthy, nil),
+			createListedNode("node2", nodepkg.NodeUnhealthy, nil),
+		),
+	}
+
+	ok, _, err := reserveDisruptionBudget(&cfg, "node1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	// reserving the same node again doesn't count it twice
+	ok, _, err = reserveDisruptionBudget(&cfg, "node1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, desc, err := reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "1 of 2 nodes are already disrupted (budget: 1)", desc)
+
+	releaseDisruptionBudget("node1")
+	ok, _, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// reservation is dropped when lister shows the node as disrupted, so it isn't counted twice
+func TestReserveDisruptionBudgetObserved(t *testing.T) {
+	defer releaseDisruptionBudget("node1")
+	cfg := config.Config{
+		MaxDisruptedNodes: 2,
+		NodeLister: createNodeLister(t,
+			createListedNode("node1", nodepkg.NodeUnhealthy, nil),
+			createListedNode("node2", nodepkg.NodeUnhealthy, nil),
+		),
+	}
+	ok, _, err := reserveDisruptionBudget(&cfg, "node1")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cfg.NodeLister = createNodeLister(t,
+		createListedNode("node1", nodepkg.NodeTainted, nil),
+		createListedNode("node2", nodepkg.NodeUnhealthy, nil),
+	)
+	ok, _, err = reserveDisruptionBudget(&cfg, "node2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	releaseDisruptionBudget("node2")
+}
+
+// node grown up & with old lease & has unhealthy label & budget exhausted - should stay unhealthy & produce event
+func TestNodeUpdateInternalUnhealthyBudgetExceeded(t *testing.T) {
+	nodeName := "test-node-budget-exceeded"
+	namespaceName := "dummy-ns"
+	defer forgetSkipped(nodeName)
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(2)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeUnhealthy).Times(2)
+
+	cfg := config.Config{
+		K8sClient:         fake.NewClientset(),
+		Namespace:         namespaceName,
+		MaxDisruptedNodes: 1,
+		NodeLister: createNodeLister(t,
+			createListedNode(nodeName, nodepkg.NodeUnhealthy, nil),
+			createListedNode("node2", nodepkg.NodeDraining, nil),
+		),
+	}
+
+	// event is reported once
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+	assert.Equal(t, "Disruption budget exceeded", events.Items[0].Reason)
+}
@@ -0,0 +1,67 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// recoverableStates are states in which node with fresh lease is made healthy again
+var recoverableStates = map[string]bool{
+	nodepkg.NodeUnhealthy:    true,
+	nodepkg.NodeTainted:      true,
+	nodepkg.NodeDraining:     true,
+	nodepkg.NodeOutOfService: true,
+}
+
+// rollbackStates are states in which termination of node with fresh lease is rolled back (if enabled)
+var rollbackStates = map[string]bool{
+	nodepkg.NodePreparingTermination: true,
+	nodepkg.NodeTerminationPrepared:  true,
+	nodepkg.NodeTerminating:          true,
+	nodepkg.NodeTerminationFailed:    true,
+}
+
+// canRollback checks if termination of node with fresh lease is going to be rolled back. Node already terminated
+// by cloud provider can't recover
+func canRollback(cfg *config.Config, n nodepkg.NODE, fresh bool, nodeLabel string) bool {
+	return fresh && cfg.RollbackTermination && rollbackStates[nodeLabel] && !n.IsTerminated()
+}
+
+// isRecovery checks if node with fresh lease is going to be made healthy again
+func isRecovery(cfg *config.Config, n nodepkg.NODE, fresh bool, nodeLabel string) bool {
+	return (fresh && recoverableStates[nodeLabel]) || canRollback(cfg, n, fresh, nodeLabel)
+}
+
+// circuitBreakerAllows records stale nodes in circuit breaker and checks if node's state can be changed.
+// When circuit is open only recovery of nodes with fresh lease is allowed.
+func circuitBreakerAllows(ctx context.Context, cfg *config.Config, n nodepkg.NODE, fresh bool, nodeLabel string) bool {
+	if cfg.CircuitBreaker == nil {
+		return true
+	}
+	now := time.Now()
+
+	if fresh {
+		cfg.CircuitBreaker.Forget(n.GetName())
+	} else if nodeLabel == nodepkg.NodeHealthy {
+		nodes, err := listWatchedNodes(cfg)
+		if err != nil {
+			log.Errorf("Node %s: couldn't list watched nodes for circuit breaker: %v", n.GetName(), err)
+		} else if cfg.CircuitBreaker.RecordStale(ctx, n.GetName(), now, len(nodes)) {
+			desc := fmt.Sprintf("%d of %d watched nodes turned stale within %d seconds", cfg.CircuitBreaker.StaleNodes(now), len(nodes), cfg.CircuitBreakerWindow)
+			nodepkg.ReportClusterEvent(ctx, cfg, log.ErrorLevel, "CircuitBreaker", "Circuit breaker opened - node remediation frozen", desc)
+		}
+	}
+
+	if !cfg.CircuitBreaker.IsOpen(ctx, now) {
+		return true
+	}
+	if isRecovery(cfg, n, fresh, nodeLabel) {
+		return true
+	}
+	log.Warnf("%s/%s: circuit breaker is open - skipping state change", n.GetKind(), n.GetName())
+	return false
+}
@@ -0,0 +1,154 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/circuitbreaker"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+// healthy node with old lease opens circuit breaker - should report cluster event and not label the node
+func TestNodeUpdateInternalCircuitBreakerOpens(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeHealthy).Times(1)
+
+	cfg := config.Config{
+		K8sClient:               fake.NewClientset(),
+		Namespace:               namespaceName,
+		LeaseLockName:           "lease-lock",
+		CircuitBreakerThreshold: 40,
+		CircuitBreakerWindow:    60,
+		CircuitBreaker:          circuitbreaker.New(40, time.Minute, time.Hour),
+		NodeLister: createNodeLister(t,
+			createListedNode(nodeName, nodepkg.NodeHealthy, nil),
+			createListedNode("node2", nodepkg.NodeHealthy, nil),
+		),
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+	assert.Equal(t, "Lease", events.Items[0].Regarding.Kind)
+	assert.Equal(t, "lease-lock", events.Items[0].Regarding.Name)
+	assert.True(t, cfg.CircuitBreaker.IsOpen(context.TODO(), time.Now()))
+}
+
+// healthy node with old lease in a small policy group - circuit breaker counts all watched nodes, not only nodes of the policy
+func TestNodeUpdateInternalCircuitBreakerCountsAllWatchedNodes(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeHealthy).Times(1)
+	node.EXPECT().SetLabel(nodepkg.NodeUnhealthy).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient:                fake.NewClientset(),
+		Namespace:                namespaceName,
+		LeaseLockName:            "lease-lock",
+		CircuitBreakerThreshold:  40,
+		CircuitBreakerWindow:     60,
+		CircuitBreaker:           circuitbreaker.New(40, time.Minute, time.Hour),
+		DisruptionBudgetSelector: labels.SelectorFromSet(labels.Set{"pool": "spot"}),
+		NodeLister: createNodeLister(t,
+			createListedNode(nodeName, nodepkg.NodeHealthy, map[string]string{"pool": "spot"}),
+			createListedNode("node2", nodepkg.NodeHealthy, nil),
+			createListedNode("node3", nodepkg.NodeHealthy, nil),
+			createListedNode("node4", nodepkg.NodeHealthy, nil),
+		),
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.False(t, cfg.CircuitBreaker.IsOpen(context.TODO(), time.Now()))
+}
+
+// tainted node with old lease & open circuit - should do nothing
+func TestNodeUpdateInternalCircuitBreakerOpenBlocks(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTainted).Times(1)
+
+	cb := circuitbreaker.New(10, time.Minute, time.Hour)
+	cb.RecordStale(context.TODO(), "other-node", time.Now(), 1)
+	cfg := config.Config{
+		K8sClient:      fake.NewClientset(),
+		Namespace:      namespaceName,
+		CircuitBreaker: cb,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 0)
+}
+
+// tainted node with fresh lease & open circuit - should still recover
+func TestNodeUpdateInternalCircuitBreakerOpenAllowsRecovery(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTainted).Times(1)
+	node.EXPECT().Uncordon().Times(1)
+	node.EXPECT().Untaint().Times(1)
+	node.EXPECT().RemoveActionTimestamp().Times(1)
+	node.EXPECT().RemoveDrainAnnotations().Times(1)
+	node.EXPECT().RemoveLabel().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	cb := circuitbreaker.New(10, time.Minute, time.Hour)
+	cb.RecordStale(context.TODO(), "other-node", time.Now(), 1)
+	cfg := config.Config{
+		K8sClient:      fake.NewClientset(),
+		Namespace:      namespaceName,
+		CircuitBreaker: cb,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
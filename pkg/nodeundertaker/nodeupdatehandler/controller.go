@@ -0,0 +1,138 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sync"
+	"time"
+)
+
+// Controller processes names of nodes from work queue. Failed nodes are retried with exponential backoff
+// and nodes waiting for a delay are processed again once the delay passes
+type Controller struct {
+	cfg    *config.Config
+	drains *nodepkg.DrainManager
+	queue  workqueue.TypedRateLimitingInterface[string]
+	// deleted keeps last known state of removed nodes until their removal is processed
+	deleted      map[string]*v1.Node
+	deletedMutex sync.Mutex
+	// leaseTimers enqueue nodes when their leases expire, they are reset whenever lease is renewed
+	leaseTimers      map[string]*time.Timer
+	leaseTimersMutex sync.Mutex
+}
+
+func NewController(cfg *config.Config, drains *nodepkg.DrainManager) *Controller {
+	return &Controller{
+		cfg:         cfg,
+		drains:      drains,
+		deleted:     make(map[string]*v1.Node),
+		leaseTimers: make(map[string]*time.Timer),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "nodes"},
+		),
+	}
+}
+
+// HandlerFuncs returns node informer handlers that enqueue updated nodes
+func (c *Controller) HandlerFuncs() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.Enqueue(newObj.(*v1.Node).Name)
+		},
+		AddFunc: func(obj interface{}) {
+			c.Enqueue(obj.(*v1.Node).Name)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if nv1, ok := obj.(*v1.Node); ok {
+				c.deletedMutex.Lock()
+				c.deleted[nv1.Name] = nv1
+				c.deletedMutex.Unlock()
+				c.Enqueue(nv1.Name)
+			}
+		},
+	}
+}
+
+// Drains returns manager running drains of nodes processed by the controller
+func (c *Controller) Drains() *nodepkg.DrainManager {
+	return c.drains
+}
+
+// Enqueue adds node to the queue, node that is already queued is processed only once
+func (c *Controller) Enqueue(nodeName string) {
+	c.queue.Add(nodeName)
+}
+
+// Run starts workers and blocks until ctx is done
+func (c *Controller) Run(ctx context.Context, workers int) {
+	defer c.queue.ShutDown()
+	for i := 0; i < max(workers, 1); i++ {
+		go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	}
+	<-ctx.Done()
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	nodeName, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(nodeName)
+
+	requeueAfter, err := c.reconcile(ctx, nodeName)
+	if err != nil {
+		log.Warnf("Node %s: processing failed (%d retries so far), retrying with backoff: %v", nodeName, c.queue.NumRequeues(nodeName), err)
+		c.queue.AddRateLimited(nodeName)
+		return true
+	}
+	c.queue.Forget(nodeName)
+	if requeueAfter > 0 {
+		log.Debugf("Node %s: processing again in %s", nodeName, requeueAfter)
+		c.queue.AddAfter(nodeName, requeueAfter)
+	}
+	return true
+}
+
+// reconcile processes node. Returns time after which node has to be processed again
+func (c *Controller) reconcile(ctx context.Context, nodeName string) (time.Duration, error) {
+	if deleted := c.popDeleted(nodeName); deleted != nil {
+		onNodeDelete(ctx, c.cfg, c.drains, deleted)
+	}
+	nv1, err := c.cfg.NodeLister.Get(nodeName)
+	if errors.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	n := createNode(c.cfg, nv1)
+	err = nodeUpdateInternal(ctx, c.cfg, c.drains, n)
+	if err != nil {
+		return 0, err
+	}
+	return nextDeadline(c.cfg, n), nil
+}
+
+// popDeleted returns last known state of the node if it was removed
+func (c *Controller) popDeleted(nodeName string) *v1.Node {
+	c.deletedMutex.Lock()
+	defer c.deletedMutex.Unlock()
+	nv1 := c.deleted[nodeName]
+	delete(c.deleted, nodeName)
+	return nv1
+}
@@ -0,0 +1,157 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+	"time"
+)
+
+func TestControllerHandlerFuncs(t *testing.T) {
+	c := NewController(&config.Config{}, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+	nv1 := createListedNode("node1", nodepkg.NodeHealthy, nil)
+
+	funcs := c.HandlerFuncs()
+	funcs.AddFunc(nv1)
+	funcs.UpdateFunc(nv1, nv1)
+
+	assert.Equal(t, 1, c.queue.Len())
+	nodeName, _ := c.queue.Get()
+	assert.Equal(t, "node1", nodeName)
+}
+
+func TestControllerDeleteFunc(t *testing.T) {
+	c := NewController(&config.Config{}, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+	funcs := c.HandlerFuncs()
+
+	funcs.DeleteFunc(createListedNode("node1", nodepkg.NodeHealthy, nil))
+	funcs.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "node2", Obj: createListedNode("node2", nodepkg.NodeHealthy, nil)})
+	funcs.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "node3"})
+
+	assert.Equal(t, 2, c.queue.Len())
+	assert.NotNil(t, c.popDeleted("node1"))
+	assert.NotNil(t, c.popDeleted("node2"))
+	assert.Nil(t, c.popDeleted("node2"))
+}
+
+func TestControllerReconcileNodeNotFound(t *testing.T) {
+	c := NewController(&config.Config{NodeLister: createNodeLister(t)}, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+
+	requeueAfter, err := c.reconcile(context.TODO(), "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, requeueAfter)
+}
+
+// node that isn't grown up - should do nothing
+func TestControllerReconcileNotGrownUp(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "test-dummy-ns"
+	nv1 := createListedNode(nodeName, nodepkg.NodeHealthy, nil)
+	nv1.CreationTimestamp = metav1.NewTime(time.Now().Add(-20 * time.Second))
+	cfg := &config.Config{
+		K8sClient:            fake.NewClientset(),
+		NodeLister:           createNodeLister(t, nv1),
+		Namespace:            namespaceName,
+		NodeInitialThreshold: 1000,
+	}
+	c := NewController(cfg, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+
+	_, err := c.reconcile(context.TODO(), nodeName)
+	assert.NoError(t, err)
+
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 0)
+}
+
+// node with old lease in dry-run mode - should walk through whole state machine without modifying the node
+func TestControllerReconcileDryRun(t *testing.T) {
+	nodeName := "test-dry-run-node1"
+	namespaceName := "dummy-ns"
+	leaseNamespace := "dummy-lease-ns"
+	leaseDuration := int32(40)
+	renewTime := metav1.NewMicroTime(time.Now().Add(-1000 * time.Second))
+	defer dryRunStore.Forget(nodeName)
+
+	nv1 := createListedNode(nodeName, nodepkg.NodeHealthy, nil)
+	nv1.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	lease := coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName, Namespace: leaseNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			LeaseDurationSeconds: &leaseDuration,
+			RenewTime:            &renewTime,
+		},
+	}
+
+	cfg := &config.Config{
+		K8sClient:          fake.NewClientset(nv1, &lease),
+		NodeLister:         createNodeLister(t, nv1),
+		Namespace:          namespaceName,
+		NodeLeaseNamespace: leaseNamespace,
+		DryRun:             true,
+	}
+	c := NewController(cfg, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+
+	expectedLabels := []string{
+		nodepkg.NodeUnhealthy,
+		nodepkg.NodeTainted,
+		nodepkg.NodeDraining,
+		nodepkg.NodePreparingTermination,
+		nodepkg.NodeTerminationPrepared,
+		nodepkg.NodeTerminating,
+	}
+	for _, expectedLabel := range expectedLabels {
+		_, err := c.reconcile(context.TODO(), nodeName)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedLabel, createNode(cfg, nv1).GetLabel())
+	}
+	_, err := c.reconcile(context.TODO(), nodeName)
+	assert.NoError(t, err)
+
+	ret, err := cfg.K8sClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, ret.Labels)
+	assert.Empty(t, ret.Spec.Taints)
+
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 7)
+	for i := range events.Items {
+		assert.Equal(t, "true", events.Items[i].Labels[nodepkg.DryRunEventLabel])
+	}
+}
+
+// node without lease is labeled unhealthy, but save fails - node should be retried
+func TestControllerProcessNextItemRetries(t *testing.T) {
+	nv1 := createListedNode("node1", nodepkg.NodeHealthy, nil)
+	nv1.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	cfg := &config.Config{
+		K8sClient:          fake.NewClientset(),
+		NodeLister:         createNodeLister(t, nv1),
+		Namespace:          "test-ns",
+		NodeLeaseNamespace: "kube-node-lease",
+	}
+	c := NewController(cfg, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+
+	c.Enqueue("node1")
+	assert.True(t, c.processNextItem(context.TODO()))
+	assert.Equal(t, 1, c.queue.NumRequeues("node1"))
+}
+
+func TestControllerProcessNextItemShutdown(t *testing.T) {
+	c := NewController(&config.Config{}, nodepkg.NewDrainManager())
+	c.queue.ShutDown()
+	assert.False(t, c.processNextItem(context.TODO()))
+}
@@ -0,0 +1,48 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/remediation"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// onNodeDelete cleans up state kept for the removed node and reports removal of node that was being remediated
+func onNodeDelete(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, nv1 *v1.Node) {
+	// created before dry-run state is forgotten, so simulated state is reported
+	n := createNode(cfg, nv1)
+	nodeLabel := n.GetLabel()
+
+	drains.Cancel(nv1.Name)
+	dryRunStore.Forget(nv1.Name)
+	forgetSignals(nv1.Name)
+	forgetSkipped(nv1.Name)
+	releaseDisruptionBudget(nv1.Name)
+	collectors.ForgetNode(nv1.Name)
+	if cfg.HealthChecker != nil {
+		healthcheck.Forget(cfg.HealthChecker, nv1.Name)
+	}
+	if cfg.CircuitBreaker != nil {
+		cfg.CircuitBreaker.Forget(nv1.Name)
+	}
+
+	if nodeLabel == nodepkg.NodeHealthy {
+		log.Debugf("%s/%s: removed", n.GetKind(), n.GetName())
+		return
+	}
+	if cfg.HasPolicies() {
+		cfg = cfg.ForNode(n.GetLabels())
+	}
+	msg := fmt.Sprintf("node removed in state %s", nodeLabel)
+	if unhealthySince, err := n.GetUnhealthySince(); err == nil {
+		msg = fmt.Sprintf("node removed %s after it became unhealthy, last state: %s", time.Since(unhealthySince).Round(time.Second), nodeLabel)
+	}
+	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "NodeRemoved", "Node removed", "", msg)
+	remediation.RecordRemoval(ctx, cfg, nv1, msg)
+}
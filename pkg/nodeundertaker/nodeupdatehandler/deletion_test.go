@@ -0,0 +1,55 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/circuitbreaker"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestOnNodeDeleteRemediated(t *testing.T) {
+	namespace := "test-ns"
+	cfg := &config.Config{K8sClient: fake.NewClientset(), Namespace: namespace}
+	nv1 := createListedNode("deleted-node1", nodepkg.NodeTerminating, nil)
+	nv1.Annotations = map[string]string{nodepkg.UnhealthySinceAnnotation: time.Now().Add(-10 * time.Minute).Format(time.RFC3339)}
+	collectors.HealthSignals.WithLabelValues("deleted-node1", "lease").Set(0)
+	disagreements.Lock()
+	disagreements.failing["deleted-node1"] = "lease"
+	disagreements.Unlock()
+	signals := testutil.CollectAndCount(collectors.HealthSignals)
+
+	onNodeDelete(context.TODO(), cfg, nodepkg.NewDrainManager(), nv1)
+
+	events, err := cfg.K8sClient.EventsV1().Events(namespace).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "Node removed", events.Items[0].Reason)
+	assert.Regexp(t, "node removed 10m[0-9]s after it became unhealthy, last state: terminating", events.Items[0].Note)
+
+	assert.Equal(t, signals-1, testutil.CollectAndCount(collectors.HealthSignals))
+	disagreements.Lock()
+	_, found := disagreements.failing["deleted-node1"]
+	disagreements.Unlock()
+	assert.False(t, found)
+}
+
+func TestOnNodeDeleteHealthy(t *testing.T) {
+	namespace := "test-ns"
+	cfg := &config.Config{K8sClient: fake.NewClientset(), Namespace: namespace, CircuitBreaker: circuitbreaker.New(50, time.Minute, time.Hour)}
+	cfg.CircuitBreaker.RecordStale(context.TODO(), "deleted-node2", time.Now(), 4)
+
+	onNodeDelete(context.TODO(), cfg, nodepkg.NewDrainManager(), createListedNode("deleted-node2", nodepkg.NodeHealthy, nil))
+
+	events, err := cfg.K8sClient.EventsV1().Events(namespace).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, events.Items)
+	assert.Equal(t, 0, cfg.CircuitBreaker.StaleNodes(time.Now()))
+}
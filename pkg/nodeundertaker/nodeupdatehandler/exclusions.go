@@ -0,0 +1,123 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/maintenance"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	// DisabledAnnotation excludes node from remediation. Value is 'true' or RFC3339 time until which node is excluded
+	DisabledAnnotation = "node-undertaker.dbschenker.com/disabled"
+
+	SkipReasonOptOut            = "opt_out"
+	SkipReasonMaintenanceWindow = "maintenance_window"
+	SkipReasonScaleDown         = "scale_down"
+	// skipReasonDisruptionBudget is used only to throttle events, skips due to budget aren't counted in metrics
+	skipReasonDisruptionBudget = "disruption_budget"
+
+	// skippedEventInterval limits how often the same skip reason is reported for a node
+	skippedEventInterval = time.Hour
+)
+
+// skipped keeps last reported skip of nodes, so skip events are throttled
+var skipped = struct {
+	sync.Mutex
+	reports map[string]skipReport
+}{reports: make(map[string]skipReport)}
+
+type skipReport struct {
+	reason string
+	time   time.Time
+}
+
+// forgetSkipped removes last reported skip of the removed node
+func forgetSkipped(nodeName string) {
+	skipped.Lock()
+	defer skipped.Unlock()
+	delete(skipped.reports, nodeName)
+}
+
+// exclusionsAllow checks if node's state can be moved forward. Recovery is always allowed. Skipped transition is counted
+// and reported with throttled event
+func exclusionsAllow(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, n nodepkg.NODE, fresh bool, nodeLabel string) bool {
+	if isRecovery(cfg, n, fresh, nodeLabel) || (fresh && nodeLabel == nodepkg.NodeHealthy) {
+		return true
+	}
+	reason, msg, excluded := getExclusion(cfg, n, time.Now())
+	if !excluded {
+		return true
+	}
+	log.Infof("%s/%s: skipping state change - %s", n.GetKind(), n.GetName(), msg)
+	if reason == SkipReasonScaleDown {
+		// node is drained by the controller removing it
+		drains.Cancel(n.GetName())
+	}
+	if cfg.DryRun {
+		collectors.DryRunSkippedTransitions.WithLabelValues(reason).Inc()
+	} else {
+		collectors.SkippedTransitions.WithLabelValues(reason).Inc()
+	}
+	if shouldReportSkip(n.GetName(), reason, time.Now()) {
+		nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "RemediationSkipped", "Remediation skipped", msg, "")
+	}
+	return false
+}
+
+// getExclusion checks if node is removed by another controller, opted out from remediation or maintenance window is active.
+// Returns skip reason and its description
+func getExclusion(cfg *config.Config, n nodepkg.NODE, now time.Time) (string, string, bool) {
+	if controller := n.RemovedBy(); controller != "" {
+		return SkipReasonScaleDown, fmt.Sprintf("node is being removed by %s - remediation is handed off", controller), true
+	}
+	if window, end, active := maintenance.Active(cfg.MaintenanceWindows, now); active {
+		return SkipReasonMaintenanceWindow, fmt.Sprintf("maintenance window '%s' is active until %s", window, end.Format(time.RFC3339)), true
+	}
+	value, found := n.GetAnnotations()[DisabledAnnotation]
+	if !found || value == "false" {
+		return "", "", false
+	}
+	if value == "true" {
+		return SkipReasonOptOut, fmt.Sprintf("node is excluded with %s annotation", DisabledAnnotation), true
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// node owner wanted to exclude the node - invalid expiry doesn't make it eligible for termination
+		return SkipReasonOptOut, fmt.Sprintf("node is excluded with %s annotation (invalid expiry '%s')", DisabledAnnotation, value), true
+	}
+	if !until.After(now) {
+		return "", "", false
+	}
+	return SkipReasonOptOut, fmt.Sprintf("node is excluded with %s annotation until %s", DisabledAnnotation, until.Format(time.RFC3339)), true
+}
+
+// untilExclusionEnds returns time left until exclusion of the node expires, 0 when node isn't excluded or exclusion doesn't expire
+func untilExclusionEnds(cfg *config.Config, n nodepkg.NODE) time.Duration {
+	now := time.Now()
+	var end time.Time
+	if _, windowEnd, active := maintenance.Active(cfg.MaintenanceWindows, now); active {
+		end = windowEnd
+	}
+	if until, err := time.Parse(time.RFC3339, n.GetAnnotations()[DisabledAnnotation]); err == nil && until.After(end) {
+		end = until
+	}
+	return max(end.Sub(now), 0)
+}
+
+// shouldReportSkip checks if skip should be reported - when its reason changed or it wasn't reported recently
+func shouldReportSkip(nodeName, reason string, now time.Time) bool {
+	skipped.Lock()
+	defer skipped.Unlock()
+	last, found := skipped.reports[nodeName]
+	if found && last.reason == reason && now.Sub(last.time) < skippedEventInterval {
+		return false
+	}
+	skipped.reports[nodeName] = skipReport{reason: reason, time: now}
+	return true
+}
@@ -0,0 +1,197 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/maintenance"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func createExclusionTestNode(annotations map[string]string) nodepkg.NODE {
+	return nodepkg.CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: annotations,
+		},
+	})
+}
+
+func TestGetExclusion(t *testing.T) {
+	now := time.Now()
+	cfg := &config.Config{}
+
+	_, _, excluded := getExclusion(cfg, createExclusionTestNode(nil), now)
+	assert.False(t, excluded)
+	_, _, excluded = getExclusion(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: "false"}), now)
+	assert.False(t, excluded)
+	_, _, excluded = getExclusion(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: now.Add(-time.Minute).Format(time.RFC3339)}), now)
+	assert.False(t, excluded)
+
+	reason, _, excluded := getExclusion(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: "true"}), now)
+	assert.True(t, excluded)
+	assert.Equal(t, SkipReasonOptOut, reason)
+
+	reason, msg, excluded := getExclusion(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: now.Add(time.Hour).Format(time.RFC3339)}), now)
+	assert.True(t, excluded)
+	assert.Equal(t, SkipReasonOptOut, reason)
+	assert.Contains(t, msg, "until")
+
+	reason, msg, excluded = getExclusion(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: "tomorrow"}), now)
+	assert.True(t, excluded)
+	assert.Equal(t, SkipReasonOptOut, reason)
+	assert.Contains(t, msg, "invalid expiry")
+}
+
+func TestGetExclusionMaintenanceWindow(t *testing.T) {
+	windows, err := maintenance.ParseWindows("0 22 * * * 2h")
+	require.NoError(t, err)
+	cfg := &config.Config{MaintenanceWindows: windows}
+
+	reason, msg, excluded := getExclusion(cfg, createExclusionTestNode(nil), time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC))
+	assert.True(t, excluded)
+	assert.Equal(t, SkipReasonMaintenanceWindow, reason)
+	assert.Equal(t, "maintenance window '0 22 * * * 2h' is active until 2024-03-02T00:00:00Z", msg)
+
+	_, _, excluded = getExclusion(cfg, createExclusionTestNode(nil), time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC))
+	assert.False(t, excluded)
+}
+
+func TestGetExclusionScaleDown(t *testing.T) {
+	n := nodepkg.CreateNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node1",
+			Annotations: map[string]string{DisabledAnnotation: "true"},
+		},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: nodepkg.ClusterAutoscalerTaintKey, Effect: v1.TaintEffectNoSchedule}},
+		},
+	})
+
+	reason, msg, excluded := getExclusion(&config.Config{}, n, time.Now())
+	assert.True(t, excluded)
+	assert.Equal(t, SkipReasonScaleDown, reason)
+	assert.Equal(t, "node is being removed by cluster-autoscaler - remediation is handed off", msg)
+}
+
+func TestUntilExclusionEnds(t *testing.T) {
+	cfg := &config.Config{}
+	assert.Equal(t, time.Duration(0), untilExclusionEnds(cfg, createExclusionTestNode(nil)))
+	assert.Equal(t, time.Duration(0), untilExclusionEnds(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: "true"})))
+
+	until := untilExclusionEnds(cfg, createExclusionTestNode(map[string]string{DisabledAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)}))
+	assert.Greater(t, until, 59*time.Minute)
+	assert.LessOrEqual(t, until, time.Hour)
+}
+
+func TestShouldReportSkip(t *testing.T) {
+	now := time.Now()
+	nodeName := "skip-report-node"
+	defer forgetSkipped(nodeName)
+
+	assert.True(t, shouldReportSkip(nodeName, SkipReasonOptOut, now))
+	assert.False(t, shouldReportSkip(nodeName, SkipReasonOptOut, now.Add(time.Minute)))
+	// reason changed
+	assert.True(t, shouldReportSkip(nodeName, SkipReasonMaintenanceWindow, now.Add(2*time.Minute)))
+	assert.True(t, shouldReportSkip(nodeName, SkipReasonMaintenanceWindow, now.Add(2*time.Minute+skippedEventInterval)))
+
+	forgetSkipped(nodeName)
+	assert.True(t, shouldReportSkip(nodeName, SkipReasonMaintenanceWindow, now.Add(3*time.Minute)))
+}
+
+// tainted node with old lease & opt-out annotation - should do nothing, report event once
+func TestNodeUpdateInternalOptOut(t *testing.T) {
+	nodeName := "test-node-opt-out"
+	namespaceName := "dummy-ns"
+	defer forgetSkipped(nodeName)
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(map[string]string{DisabledAnnotation: "true"}).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(2)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTainted).Times(2)
+
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: namespaceName,
+	}
+	skippedBefore := testutil.ToFloat64(collectors.SkippedTransitions.WithLabelValues(SkipReasonOptOut))
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, "Remediation skipped", events.Items[0].Reason)
+	}
+	assert.Equal(t, skippedBefore+2, testutil.ToFloat64(collectors.SkippedTransitions.WithLabelValues(SkipReasonOptOut)))
+}
+
+// tainted node with old lease & opt-out annotation in dry-run mode - skip should be counted in dry-run metric
+func TestNodeUpdateInternalOptOutDryRun(t *testing.T) {
+	nodeName := "test-node-opt-out-dry-run"
+	defer forgetSkipped(nodeName)
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(map[string]string{DisabledAnnotation: "true"}).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTainted).Times(1)
+
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: "dummy-ns",
+		DryRun:    true,
+	}
+	skippedBefore := testutil.ToFloat64(collectors.SkippedTransitions.WithLabelValues(SkipReasonOptOut))
+	dryRunSkippedBefore := testutil.ToFloat64(collectors.DryRunSkippedTransitions.WithLabelValues(SkipReasonOptOut))
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	assert.Equal(t, skippedBefore, testutil.ToFloat64(collectors.SkippedTransitions.WithLabelValues(SkipReasonOptOut)))
+	assert.Equal(t, dryRunSkippedBefore+1, testutil.ToFloat64(collectors.DryRunSkippedTransitions.WithLabelValues(SkipReasonOptOut)))
+}
+
+// tainted node with fresh lease & opt-out annotation - recovery isn't blocked
+func TestNodeUpdateInternalOptOutRecovery(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(map[string]string{DisabledAnnotation: "true"}).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTainted).Times(1)
+	node.EXPECT().Uncordon().Times(1)
+	node.EXPECT().Untaint().Times(1)
+	node.EXPECT().RemoveActionTimestamp().Times(1)
+	node.EXPECT().RemoveDrainAnnotations().Times(1)
+	node.EXPECT().RemoveLabel().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: "dummy-ns",
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+}
@@ -0,0 +1,118 @@
+package nodeupdatehandler
+
+import (
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	log "github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// leaseExpiryMargin delays processing of node after its lease expiry, so the lease is already stale when node is processed
+const leaseExpiryMargin = time.Second
+
+// LeaseHandlerFuncs returns handlers enqueuing node when its lease is created, deleted or its freshness changes.
+// Leases becoming stale don't generate events - node is enqueued when its lease expires without being renewed
+func (c *Controller) LeaseHandlerFuncs(sources []healthcheck.LeaseSource) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldLease, newLease := oldObj.(*coordinationv1.Lease), newObj.(*coordinationv1.Lease)
+			if healthcheck.IsLeaseFresh(oldLease) != healthcheck.IsLeaseFresh(newLease) {
+				c.onLeaseChange(sources, newLease)
+			}
+			c.onLeaseRenew(sources, newLease)
+		},
+		AddFunc: func(obj interface{}) {
+			lease := obj.(*coordinationv1.Lease)
+			c.onLeaseChange(sources, lease)
+			c.onLeaseRenew(sources, lease)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if lease, ok := obj.(*coordinationv1.Lease); ok {
+				c.stopLeaseTimer(lease)
+				c.onLeaseChange(sources, lease)
+			}
+		},
+	}
+}
+
+func (c *Controller) onLeaseChange(sources []healthcheck.LeaseSource, lease *coordinationv1.Lease) {
+	if n := findLeaseNode(c.cfg, sources, lease); n != nil {
+		log.Debugf("Lease %s/%s changed, enqueuing node %s", lease.Namespace, lease.Name, n.Name)
+		c.Enqueue(n.Name)
+	}
+}
+
+// onLeaseRenew (re)starts timer enqueuing node of fresh lease when the lease expires. Timer is reset on every renewal,
+// so node is enqueued only when its lease turns stale
+func (c *Controller) onLeaseRenew(sources []healthcheck.LeaseSource, lease *coordinationv1.Lease) {
+	expiry, ok := healthcheck.LeaseExpiry(lease)
+	if !ok || !expiry.After(time.Now()) {
+		c.stopLeaseTimer(lease)
+		return
+	}
+	n := findLeaseNode(c.cfg, sources, lease)
+	if n == nil {
+		return
+	}
+	key := lease.Namespace + "/" + lease.Name
+	nodeName := n.Name
+	delay := time.Until(expiry) + leaseExpiryMargin
+
+	c.leaseTimersMutex.Lock()
+	defer c.leaseTimersMutex.Unlock()
+	if timer, found := c.leaseTimers[key]; found {
+		timer.Reset(delay)
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		c.leaseTimersMutex.Lock()
+		if c.leaseTimers[key] == timer {
+			delete(c.leaseTimers, key)
+		}
+		c.leaseTimersMutex.Unlock()
+		log.Debugf("Lease %s expired, enqueuing node %s", key, nodeName)
+		c.Enqueue(nodeName)
+	})
+	c.leaseTimers[key] = timer
+}
+
+// stopLeaseTimer stops timer of the lease that was removed or isn't fresh anymore
+func (c *Controller) stopLeaseTimer(lease *coordinationv1.Lease) {
+	key := lease.Namespace + "/" + lease.Name
+	c.leaseTimersMutex.Lock()
+	defer c.leaseTimersMutex.Unlock()
+	if timer, found := c.leaseTimers[key]; found {
+		timer.Stop()
+		delete(c.leaseTimers, key)
+	}
+}
+
+// findLeaseNode returns watched node owning the lease or nil when there is none
+func findLeaseNode(cfg *config.Config, sources []healthcheck.LeaseSource, lease *coordinationv1.Lease) *v1.Node {
+	if cfg.NodeLister == nil {
+		return nil
+	}
+	for _, source := range sources {
+		nodeName, matches := source.NodeName(lease.Name)
+		if !matches {
+			continue
+		}
+		n, err := cfg.NodeLister.Get(nodeName)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			log.Errorf("Couldn't get node %s of lease %s/%s: %v", nodeName, lease.Namespace, lease.Name, err)
+			return nil
+		}
+		return n
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package nodeupdatehandler
+
+import (
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestFindLeaseNode(t *testing.T) {
+	sources, err := healthcheck.ParseLeaseSources("apps:{{.NodeName}}-app")
+	require.NoError(t, err)
+	cfg := &config.Config{
+		NodeLister: createNodeLister(t, createListedNode("node1", nodepkg.NodeHealthy, nil)),
+	}
+	lease := func(name string) *coordinationv1.Lease {
+		return &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"}}
+	}
+
+	n := findLeaseNode(cfg, sources, lease("node1-app"))
+	require.NotNil(t, n)
+	assert.Equal(t, "node1", n.Name)
+
+	assert.Nil(t, findLeaseNode(cfg, sources, lease("node2-app")))
+	assert.Nil(t, findLeaseNode(cfg, sources, lease("node1")))
+	assert.Nil(t, findLeaseNode(&config.Config{}, sources, lease("node1-app")))
+}
+
+// renewed lease doesn't change freshness - node is enqueued when the lease expires
+func TestLeaseHandlerFuncsEnqueueOnExpiry(t *testing.T) {
+	sources, err := healthcheck.ParseLeaseSources("kube-node-lease")
+	require.NoError(t, err)
+	c := NewController(&config.Config{
+		NodeLister: createNodeLister(t, createListedNode("node1", nodepkg.NodeHealthy, nil)),
+	}, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+	duration := int32(40)
+	renewTime := metav1.NewMicroTime(time.Now().Add(-39800 * time.Millisecond))
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "kube-node-lease"},
+		Spec:       coordinationv1.LeaseSpec{RenewTime: &renewTime, LeaseDurationSeconds: &duration},
+	}
+
+	c.LeaseHandlerFuncs(sources).UpdateFunc(lease, lease)
+	c.LeaseHandlerFuncs(sources).UpdateFunc(lease, lease)
+	assert.Equal(t, 0, c.queue.Len())
+
+	assert.Eventually(t, func() bool { return c.queue.Len() == 1 }, 3*time.Second, 50*time.Millisecond)
+	nodeName, _ := c.queue.Get()
+	assert.Equal(t, "node1", nodeName)
+	assert.False(t, healthcheck.IsLeaseFresh(lease))
+}
+
+// lease renewed before expiry - node shouldn't be enqueued
+func TestLeaseHandlerFuncsRenewedBeforeExpiry(t *testing.T) {
+	sources, err := healthcheck.ParseLeaseSources("kube-node-lease")
+	require.NoError(t, err)
+	c := NewController(&config.Config{
+		NodeLister: createNodeLister(t, createListedNode("node1", nodepkg.NodeHealthy, nil)),
+	}, nodepkg.NewDrainManager())
+	defer c.queue.ShutDown()
+	duration := int32(1)
+	renewTime := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "kube-node-lease"},
+		Spec:       coordinationv1.LeaseSpec{RenewTime: &renewTime, LeaseDurationSeconds: &duration},
+	}
+	c.LeaseHandlerFuncs(sources).UpdateFunc(lease, lease)
+
+	renewed := lease.DeepCopy()
+	renewedDuration := int32(10)
+	renewed.Spec.LeaseDurationSeconds = &renewedDuration
+	c.LeaseHandlerFuncs(sources).UpdateFunc(lease, renewed)
+
+	time.Sleep(2500 * time.Millisecond)
+	assert.Equal(t, 0, c.queue.Len())
+	c.stopLeaseTimer(renewed)
+}
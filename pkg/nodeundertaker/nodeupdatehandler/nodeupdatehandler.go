@@ -4,95 +4,133 @@ import (
 	"context"
 	"fmt"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
 	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/remediation"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/tools/cache"
 	"time"
 )
 
-func OnNodeUpdate(ctx context.Context, cfg *config.Config, nv1 *v1.Node) {
-	n := nodepkg.CreateNode(nv1)
-	nodeUpdateInternal(ctx, cfg, n)
+// dryRunStore holds simulated node states when running in dry-run mode
+var dryRunStore = nodepkg.NewDryRunStore()
+
+func createNode(cfg *config.Config, nv1 *v1.Node) nodepkg.NODE {
+	if cfg.DryRun {
+		return nodepkg.CreateDryRunNode(nv1, dryRunStore)
+	}
+	return nodepkg.CreateNode(nv1)
 }
 
-func nodeUpdateInternal(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+// nodeUpdateInternal moves node to the next state if needed. Returns error when it should be retried
+func nodeUpdateInternal(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, n nodepkg.NODE) error {
 	if !isAfterInitialDelay(cfg) {
 		log.Debugf("Node udertaker is not running at least %d seconds", cfg.InitialDelay)
-		return
+		return nil
+	}
+	if cfg.HasPolicies() {
+		cfg = cfg.ForNode(n.GetLabels())
 	}
 	if !n.IsGrownUp(cfg) {
 		log.Debugf("%s/%s: is not old enough (%d seconds) - might be not fully initialized.", n.GetKind(), n.GetName(), cfg.NodeInitialThreshold)
-		return
+		return nil
 	}
 
-	// check if lease is fresh
-	fresh, err := n.HasFreshLease(ctx, cfg)
+	health, err := checkHealth(ctx, cfg, n)
+	fresh := health.Healthy
 	if err != nil {
-		log.Errorf("Node %s update failed: %v", n.GetName(), err)
-		return
+		return err
 	}
+	reportSignals(ctx, cfg, n, health)
 
 	nodeLabel := n.GetLabel()
 
+	if !circuitBreakerAllows(ctx, cfg, n, fresh, nodeLabel) {
+		return nil
+	}
+	if !exclusionsAllow(ctx, cfg, drains, n, fresh, nodeLabel) {
+		return nil
+	}
+
+	if canRollback(cfg, n, fresh, nodeLabel) {
+		return rollbackTermination(ctx, cfg, drains, n)
+	}
+
 	if nodeLabel == nodepkg.NodeTerminating {
-		reason, err := n.Terminate(ctx, cfg)
-		if err != nil {
-			nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Termination", reason, err.Error(), "")
-		}
-		nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Termination", reason, "", "")
-		return
+		return nodeTerminating(ctx, cfg, n)
+	} else if nodeLabel == nodepkg.NodeTerminationFailed {
+		return nodeTerminationFailed(ctx, cfg, n)
 	} else if nodeLabel == nodepkg.NodePreparingTermination {
-		nodePreparingTermination(ctx, cfg, n)
-		return
+		return nodePreparingTermination(ctx, cfg, n)
 	} else if nodeLabel == nodepkg.NodeTerminationPrepared {
-		nodeTerminationPrepared(ctx, cfg, n)
-		return
+		return nodeTerminationPrepared(ctx, cfg, n)
 	}
 
 	if fresh {
 		if nodeLabel != nodepkg.NodeHealthy {
-			makeNodeHealthy(ctx, cfg, n)
-		} else {
-			log.Debugf("%s/%s: has fresh lease", n.GetKind(), n.GetName())
-		}
-	} else { // node has old lease
-		switch label := nodeLabel; label {
-		case nodepkg.NodeHealthy:
-			makeNodeUnhealthy(ctx, cfg, n)
-		case nodepkg.NodeUnhealthy:
-			taintNode(ctx, cfg, n)
-		case nodepkg.NodeTainted:
-			drainNode(ctx, cfg, n)
-		case nodepkg.NodeDraining:
-			makePrepareNodeTermination(ctx, cfg, n)
-		default:
-			nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "NodeUpdate", "Node Update Failed", fmt.Sprintf("unknown label value found: %s", label), "")
+			return makeNodeHealthy(ctx, cfg, drains, n)
 		}
+		log.Debugf("%s/%s: has fresh lease", n.GetKind(), n.GetName())
+		return nil
+	}
+	// node has old lease
+	switch label := nodeLabel; label {
+	case nodepkg.NodeHealthy:
+		return makeNodeUnhealthy(ctx, cfg, n, health)
+	case nodepkg.NodeUnhealthy:
+		return taintNode(ctx, cfg, n)
+	case nodepkg.NodeTainted:
+		return drainNode(ctx, cfg, drains, n)
+	case nodepkg.NodeDraining:
+		return makePrepareNodeTermination(ctx, cfg, n)
+	case nodepkg.NodeOutOfService:
+		return taintOutOfService(ctx, cfg, n)
+	default:
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "NodeUpdate", "Node Update Failed", fmt.Sprintf("unknown label value found: %s", label), "")
+		return nil
 	}
 }
 
-func GetDefaultUpdateHandlerFuncs(ctx context.Context, cfg *config.Config) cache.ResourceEventHandlerFuncs {
-	return cache.ResourceEventHandlerFuncs{
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			OnNodeUpdate(ctx, cfg, newObj.(*v1.Node))
-		},
-		AddFunc: func(obj interface{}) {
-			OnNodeUpdate(ctx, cfg, obj.(*v1.Node))
-		},
-		DeleteFunc: nil,
+// checkHealth runs configured health checks. When there are none - only node's leases are checked
+func checkHealth(ctx context.Context, cfg *config.Config, n nodepkg.NODE) (healthcheck.Result, error) {
+	checker := cfg.HealthChecker
+	if checker == nil && len(cfg.NodeLeases) == 0 {
+		fresh, err := n.HasFreshLease(ctx, cfg)
+		result := healthcheck.Result{Check: healthcheck.LeaseCheck, Healthy: fresh}
+		if !fresh {
+			result.Message = "lease is not renewed"
+			result.StaleLease = &healthcheck.LeaseRef{Namespace: cfg.NodeLeaseNamespace, Name: n.GetName()}
+		}
+		return result, err
+	} else if checker == nil {
+		// result of the lease source that failed is needed as evidence, so leases are checked here instead of by node
+		checker = healthcheck.NewLeaseChecker(healthcheck.LeaseCheck, "")
 	}
+	return checker.Check(ctx, healthcheck.Target{
+		Node:         n.GetNode(),
+		Client:       cfg.K8sClient,
+		LeaseSources: cfg.LeaseSources(),
+		LeasesMode:   cfg.NodeLeasesMode,
+		LeaseListers: cfg.LeaseListers,
+	})
 }
 
 func isAfterInitialDelay(cfg *config.Config) bool {
 	return cfg.StartupTime.Add(time.Duration(cfg.InitialDelay) * time.Second).Before(time.Now())
 }
 
-func nodePreparingTermination(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func nodePreparingTermination(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	forceCleanup(ctx, cfg, n)
 	reason, err := n.PrepareTermination(ctx, cfg)
+	remediation.RecordCloudProviderResponse(ctx, cfg, n, "PrepareTermination", reason, err)
 	if err != nil {
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Prepare Termination", reason, err.Error(), "")
-		return
+		// traffic sources detached before the failure are remembered, so they can be restored on rollback
+		if saveErr := n.Save(ctx, cfg); saveErr != nil {
+			log.Errorf("Received error while saving node %s: %v", n.GetName(), saveErr)
+		}
+		return err
 	}
 
 	n.SetActionTimestamp(time.Now())
@@ -101,22 +139,26 @@ func nodePreparingTermination(ctx context.Context, cfg *config.Config, n nodepkg
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Prepare Termination", "Prepare Termination failed", err.Error(), "")
-		return
+		return err
 	}
 
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Termination prepared", reason, "", "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeTerminationPrepared, reason)
+	return nil
 }
 
-func nodeTerminationPrepared(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+// nodeTerminationPrepared labels node terminating after termination delay
+func nodeTerminationPrepared(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	forceCleanup(ctx, cfg, n)
 	nodeModificationTimestamp, err := n.GetActionTimestamp()
 	if err != nil {
 		log.Errorf("Node %s: timestamp is not parsed properly: %v", n.GetName(), err)
-		return
+		return nil
 	}
 	timestampShouldBeBefore := time.Now().Add(-time.Duration(cfg.CloudTerminationDelay) * time.Second)
 	if nodeModificationTimestamp.After(timestampShouldBeBefore) {
 		log.Infof("%s/%s: prepared for termintaion less than %d seconds ago", n.GetKind(), n.GetName(), cfg.CloudTerminationDelay)
-		return
+		return nil
 	}
 
 	n.SetLabel(nodepkg.NodeTerminating)
@@ -124,93 +166,240 @@ func nodeTerminationPrepared(ctx context.Context, cfg *config.Config, n nodepkg.
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", "Label terminating failed", err.Error(), "")
-		return
+		return err
 	}
 
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "LabelTerminating", "Labeled terminating", "", "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeTerminating, fmt.Sprintf("prepared for termination more than %d seconds ago", cfg.CloudTerminationDelay))
+	return nil
 }
 
-func makeNodeHealthy(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func makeNodeHealthy(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, n nodepkg.NODE) error {
+	// drain would cordon the node again
+	drains.Cancel(n.GetName())
+	n.Uncordon()
 	n.Untaint()
 	n.RemoveActionTimestamp()
+	n.RemoveDrainAnnotations()
 	n.RemoveLabel()
 	err := n.Save(ctx, cfg)
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Untaint", "Untaint failed", err.Error(), "")
-		return
+		return err
 	}
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Untaint", "Untainted", "", "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeHealthy, "health checks passed")
+	return nil
+}
+
+// rollbackTermination registers node that recovered before termination back in traffic sources and makes it healthy again
+func rollbackTermination(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, n nodepkg.NODE) error {
+	reason, err := n.RollbackTermination(ctx, cfg)
+	remediation.RecordCloudProviderResponse(ctx, cfg, n, "RollbackTermination", reason, err)
+	if err != nil {
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "RemediationAborted", reason, err.Error(), "")
+		return err
+	}
+
+	drains.Cancel(n.GetName())
+	n.Uncordon()
+	n.Untaint()
+	n.RemoveActionTimestamp()
+	n.RemoveDrainAnnotations()
+	n.RemoveLabel()
+	err = n.Save(ctx, cfg)
+	if err != nil {
+		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "RemediationAborted", "Remediation abort failed", err.Error(), "")
+		return err
+	}
+	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "RemediationAborted", "Remediation aborted", fmt.Sprintf("node recovered before termination: %s", reason), "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeHealthy, "health checks passed before termination - termination rolled back")
+	return nil
 }
 
-func makeNodeUnhealthy(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func makeNodeUnhealthy(ctx context.Context, cfg *config.Config, n nodepkg.NODE, health healthcheck.Result) error {
 	n.SetLabel(nodepkg.NodeUnhealthy)
 	err := n.Save(ctx, cfg)
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", "Label unhealthy failed", err.Error(), "")
-		return
+		return err
 	}
-	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "LabeledUnhealthy", "Labeled unhealthy", "", "")
+	reason := "Labeled unhealthy"
+	if cfg.HealthChecker != nil {
+		reason = fmt.Sprintf("Labeled unhealthy: %s check failed", health.Check)
+	}
+	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "LabeledUnhealthy", reason, health.Message, "")
+	remediation.RecordUnhealthy(ctx, cfg, n, fmt.Sprintf("%s check failed: %s", health.Check, health.Message), health.StaleLease)
+	return nil
 }
 
-func taintNode(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func taintNode(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	if cfg.TaintDisabled {
+		log.Debugf("%s/%s: tainting is disabled by policy %s", n.GetKind(), n.GetName(), cfg.PolicyName)
+		return nil
+	}
+	withinBudget, budgetDesc, err := reserveDisruptionBudget(cfg, n.GetName())
+	if err != nil {
+		log.Errorf("Node %s: couldn't check disruption budget: %v", n.GetName(), err)
+		return err
+	}
+	if !withinBudget {
+		log.Infof("%s/%s: disruption budget exceeded - %s", n.GetKind(), n.GetName(), budgetDesc)
+		if shouldReportSkip(n.GetName(), skipReasonDisruptionBudget, time.Now()) {
+			nodepkg.ReportEvent(ctx, cfg, log.WarnLevel, n, "Taint", "Disruption budget exceeded", budgetDesc, "")
+		}
+		return nil
+	}
+
 	n.Taint()
 	n.SetActionTimestamp(time.Now())
 	n.SetLabel(nodepkg.NodeTainted)
-	err := n.Save(ctx, cfg)
+	err = n.Save(ctx, cfg)
 	if err != nil {
+		releaseDisruptionBudget(n.GetName())
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Tainted", "Failed", err.Error(), "")
-		return
+		return err
 	}
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Taint", "Tainted", "", "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeTainted, "Tainted")
+	return nil
 }
 
-func drainNode(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func drainNode(ctx context.Context, cfg *config.Config, drains *nodepkg.DrainManager, n nodepkg.NODE) error {
 	nodeModificationTimestamp, err := n.GetActionTimestamp()
 	if err != nil {
 		log.Errorf("Node %s: timestamp is not parsed properly: %v", n.GetName(), err)
-		return
+		return nil
 	}
 	timestampShouldBeBefore := time.Now().Add(-time.Duration(cfg.DrainDelay) * time.Second)
 	if nodeModificationTimestamp.After(timestampShouldBeBefore) {
 		log.Infof("%s/%s: tainted less than %d seconds ago", n.GetKind(), n.GetName(), cfg.DrainDelay)
-		return
+		return nil
 	}
 
-	n.StartDrain(ctx, cfg)
+	if !cfg.DrainDisabled {
+		n.StartDrain(ctx, cfg, drains)
+	}
 	n.SetActionTimestamp(time.Now())
 	n.SetLabel(nodepkg.NodeDraining)
 	err = n.Save(ctx, cfg)
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
 		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Drain", "Drain Start Failed", err.Error(), "")
-		return
+		return err
 	}
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Drain", "Drain started", "", "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeDraining, "Drain started")
+	return nil
 }
 
-func makePrepareNodeTermination(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+func makePrepareNodeTermination(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	if cfg.TerminationDisabled {
+		log.Debugf("%s/%s: termination is disabled by policy %s", n.GetKind(), n.GetName(), cfg.PolicyName)
+		return nil
+	}
 	nodeModificationTimestamp, err := n.GetActionTimestamp()
 	if err != nil {
 		log.Errorf("Node %s: timestamp is not parsed properly: %v", n.GetName(), err)
-		return
+		return nil
 	}
+
+	drainStatus := n.GetDrainStatus()
+	drainFinished := cfg.DrainDisabled || drainStatus == nodepkg.DrainStatusSucceeded || drainStatus == nodepkg.DrainStatusFailed
+	// CloudPrepareTerminationDelay is a hard timeout for the drain
 	timestampShouldBeBefore := time.Now().Add(-time.Duration(cfg.CloudPrepareTerminationDelay) * time.Second)
-	if nodeModificationTimestamp.After(timestampShouldBeBefore) {
-		log.Infof("%s/%s: drained less than %d seconds ago", n.GetKind(), n.GetName(), cfg.CloudPrepareTerminationDelay)
-		return
+	if !drainFinished && nodeModificationTimestamp.After(timestampShouldBeBefore) {
+		log.Infof("%s/%s: drain started less than %d seconds ago and is not finished yet", n.GetKind(), n.GetName(), cfg.CloudPrepareTerminationDelay)
+		return nil
+	}
+
+	// out_of_service state is optional
+	nextLabel := nodepkg.NodePreparingTermination
+	if cfg.OutOfServiceTaintDelay > 0 {
+		nextLabel = nodepkg.NodeOutOfService
 	}
 
 	n.SetActionTimestamp(time.Now())
-	n.SetLabel(nodepkg.NodePreparingTermination)
+	n.SetLabel(nextLabel)
 	err = n.Save(ctx, cfg)
 	if err != nil {
 		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
-		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", "Label Prepare Termination Failed", err.Error(), "")
-		return
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", fmt.Sprintf("Label %s Failed", nextLabel), err.Error(), "")
+		return err
 	}
 
+	forceCleanup(ctx, cfg, n)
+	transitionReason := fmt.Sprintf("drain %s", drainStatus)
+	if !drainFinished {
+		transitionReason = fmt.Sprintf("drain not finished within %d seconds", cfg.CloudPrepareTerminationDelay)
+		// simulated drains succeed immediately - dry-run doesn't report outcome of real drains
+		if !cfg.DryRun {
+			collectors.DrainOutcomes.WithLabelValues(nodepkg.DrainOutcomeTimedOut).Inc()
+		}
+		nodepkg.ReportEvent(ctx, cfg, log.WarnLevel, n, "Drain", "Drain Timed Out", transitionReason, "")
+	} else if cfg.DrainDisabled {
+		transitionReason = fmt.Sprintf("drain is disabled by policy %s", cfg.PolicyName)
+	}
+	remediation.RecordTransition(ctx, cfg, n, nextLabel, transitionReason)
+	if nextLabel == nodepkg.NodeOutOfService {
+		nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "LabelOutOfService", "Labeled out of service", "", "")
+		return nil
+	}
 	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Prepare Termination", "Instance preparing for termination", "", "")
+	return nil
+}
+
+// taintOutOfService applies out-of-service taint once node is unhealthy long enough and moves it to termination preparation
+func taintOutOfService(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	forceCleanup(ctx, cfg, n)
+	unhealthySince, err := n.GetUnhealthySince()
+	if err != nil {
+		// node became unhealthy before unhealthy-since annotation was introduced
+		log.Warnf("Node %s: %v - using time of entering out_of_service state", n.GetName(), err)
+		unhealthySince, err = n.GetActionTimestamp()
+		if err != nil {
+			log.Errorf("Node %s: timestamp is not parsed properly: %v", n.GetName(), err)
+			return nil
+		}
+	}
+	timestampShouldBeBefore := time.Now().Add(-time.Duration(cfg.OutOfServiceTaintDelay) * time.Second)
+	if unhealthySince.After(timestampShouldBeBefore) {
+		log.Infof("%s/%s: unhealthy less than %d seconds", n.GetKind(), n.GetName(), cfg.OutOfServiceTaintDelay)
+		return nil
+	}
+
+	n.TaintOutOfService()
+	n.SetActionTimestamp(time.Now())
+	n.SetLabel(nodepkg.NodePreparingTermination)
+	err = n.Save(ctx, cfg)
+	if err != nil {
+		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "OutOfService", "Out Of Service Taint Failed", err.Error(), "")
+		return err
+	}
+	reason := fmt.Sprintf("node is unhealthy for more than %d seconds", cfg.OutOfServiceTaintDelay)
+	nodepkg.ReportEvent(ctx, cfg, log.WarnLevel, n, "OutOfService", "Out Of Service Tainted", reason, "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodePreparingTermination, reason)
+	return nil
+}
+
+// forceCleanup releases resources that are stuck on drained node (if enabled). It runs on every evaluation of node
+// waiting for termination, so pods reaching the threshold later are deleted and failed deletions are retried
+func forceCleanup(ctx context.Context, cfg *config.Config, n nodepkg.NODE) {
+	if cfg.ForceDeleteTerminatingPodsAfter > 0 {
+		err := n.ForceDeleteTerminatingPods(ctx, cfg)
+		if err != nil {
+			log.Errorf("Node %s: couldn't force delete terminating pods: %v", n.GetName(), err)
+		}
+	}
+	if cfg.DeleteVolumeAttachments {
+		err := n.DeleteVolumeAttachments(ctx, cfg)
+		if err != nil {
+			log.Errorf("Node %s: couldn't delete volume attachments: %v", n.GetName(), err)
+		}
+	}
 }
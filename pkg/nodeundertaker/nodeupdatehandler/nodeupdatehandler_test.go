@@ -2,44 +2,25 @@ package nodeupdatehandler
 
 import (
 	"context"
+	"fmt"
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	mockhealthcheck "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck/mocks"
 	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
 	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/fake"
 	"testing"
 	"time"
 )
 
-func TestOnNodeUpdate(t *testing.T) {
-	nodeName := "test-node1"
-	namespaceName := "test-dummy-ns"
-	creationTime := metav1.Now().Add(-20 * time.Second).UTC()
-
-	nv1 := v1.Node{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:              nodeName,
-			Namespace:         namespaceName,
-			CreationTimestamp: metav1.NewTime(creationTime),
-		},
-	}
-
-	cfg := config.Config{
-		K8sClient:            fake.NewClientset(),
-		Namespace:            namespaceName,
-		NodeInitialThreshold: 1000,
-	}
-
-	OnNodeUpdate(context.TODO(), &cfg, &nv1)
-
-	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
-	assert.NoError(t, evErr)
-	assert.Len(t, events.Items, 0)
-}
-
 // unknown node label, node with old lease - should do nothin
 func TestUnknownLabel(t *testing.T) {
 	nodeName := "test-node1"
@@ -49,6 +30,8 @@ func TestUnknownLabel(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().GetLabel().Return("unknown-label").Times(1)
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
@@ -59,7 +42,7 @@ func TestUnknownLabel(t *testing.T) {
 		Namespace: namespaceName,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
@@ -72,7 +55,7 @@ func TestNodeUpdateInternalNotAfterInitialDelay(t *testing.T) {
 		InitialDelay: 100,
 	}
 	n := nodepkg.Node{}
-	nodeUpdateInternal(context.TODO(), &cfg, &n)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), &n)
 }
 
 // node not grown up - should do nothing
@@ -83,6 +66,8 @@ func TestNodeUpdateInternalNotGrownUp(t *testing.T) {
 	node := mocknode.NewMockNODE(mockCtrl)
 	node.EXPECT().GetName().Return(nodeName)
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(false).Times(1)
 
 	cfg := config.Config{
@@ -90,7 +75,7 @@ func TestNodeUpdateInternalNotGrownUp(t *testing.T) {
 		Namespace: namespaceName,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
@@ -107,13 +92,15 @@ func TestNodeUpdateInternalHealthyNoLabel(t *testing.T) {
 	node := mocknode.NewMockNODE(mockCtrl)
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
 
 	cfg := config.Config{}
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 }
 
 // node grown up & with recent lease & has label - should remove label, taint and annotation
@@ -129,19 +116,23 @@ func TestNodeUpdateInternalHealthyUnhealthyLabel(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
 
+	node.EXPECT().Uncordon().Times(1)
 	node.EXPECT().Untaint().Times(1)
 	node.EXPECT().RemoveActionTimestamp().Times(1)
+	node.EXPECT().RemoveDrainAnnotations().Times(1)
 	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(saveErr).Times(1)
 	node.EXPECT().RemoveLabel().Times(1)
 
 	cfg := config.Config{K8sClient: fake.NewClientset(), Namespace: namespaceName}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
@@ -160,6 +151,8 @@ func TestNodeUpdateInternalUnhealthyNoLabel(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -170,10 +163,44 @@ func TestNodeUpdateInternalUnhealthyNoLabel(t *testing.T) {
 
 	cfg := config.Config{K8sClient: fake.NewClientset(), Namespace: namespaceName}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
+
+// node grown up & failing condition check & has no label - should add label & produce event naming the failed check
+func TestNodeUpdateInternalUnhealthyHealthChecker(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+	nv1 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().GetNode().Return(nv1).AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeHealthy).Times(1)
+	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodeUnhealthy).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(setLabelCall)
+
+	client := fake.NewClientset()
+	checker := mockhealthcheck.NewMockHEALTHCHECKER(mockCtrl)
+	checker.EXPECT().Check(gomock.Any(), healthcheck.Target{Node: nv1, Client: client, LeaseSources: []healthcheck.LeaseSource{{Namespace: "kube-node-lease"}}}).
+		Return(healthcheck.Result{Check: healthcheck.ConditionCheck, Healthy: false, Message: "Ready=False for 5m0s"}, nil).Times(1)
+
+	cfg := config.Config{K8sClient: client, Namespace: namespaceName, NodeLeaseNamespace: "kube-node-lease", HealthChecker: checker}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
+	assert.Equal(t, "Labeled unhealthy: condition check failed", events.Items[0].Reason)
+	assert.Contains(t, events.Items[0].Note, "Ready=False for 5m0s")
 }
 
 // node grown up & with old lease & has unhealthy label - should add timestamp, taint & change label + save + produce event
@@ -189,6 +216,8 @@ func TestNodeUpdateInternalUnhealthyUnhealthyLabel(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -201,7 +230,7 @@ func TestNodeUpdateInternalUnhealthyUnhealthyLabel(t *testing.T) {
 
 	cfg := config.Config{K8sClient: fake.NewClientset(), Namespace: namespaceName}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
@@ -220,6 +249,8 @@ func TestNodeUpdateInternalUnhealthyTaintedLabelRecent(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -233,7 +264,7 @@ func TestNodeUpdateInternalUnhealthyTaintedLabelRecent(t *testing.T) {
 		DrainDelay: 90,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 0)
@@ -253,6 +284,8 @@ func TestNodeUpdateInternalUnhealthyTaintedLabelOld(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -260,7 +293,7 @@ func TestNodeUpdateInternalUnhealthyTaintedLabelOld(t *testing.T) {
 
 	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-100*time.Second), getTimestampErr).Times(1)
 
-	drainCall := node.EXPECT().StartDrain(gomock.Any(), gomock.Any()).Times(1)
+	drainCall := node.EXPECT().StartDrain(gomock.Any(), gomock.Any(), gomock.Any()).Times(1)
 	drainingCall := node.EXPECT().SetLabel(nodepkg.NodeDraining).Times(1)
 	timestampCall := node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
 	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(saveErr).Times(1).After(drainingCall).After(timestampCall).After(drainCall)
@@ -271,7 +304,7 @@ func TestNodeUpdateInternalUnhealthyTaintedLabelOld(t *testing.T) {
 		DrainDelay: 90,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
@@ -290,12 +323,15 @@ func TestNodeUpdateInternalUnhealthyDrainingLabelRecent(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
 
 	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-5*time.Second), getTimestampErr).Times(1)
+	node.EXPECT().GetDrainStatus().Return(nodepkg.DrainStatusRunning).Times(1)
 
 	cfg := config.Config{
 		K8sClient:                    fake.NewClientset(),
@@ -303,13 +339,13 @@ func TestNodeUpdateInternalUnhealthyDrainingLabelRecent(t *testing.T) {
 		CloudPrepareTerminationDelay: 90,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 0)
 }
 
-// node grown up & with old lease & label=draining + timestamp less than threshold - should terminate the node in cloud + label + annotate + produce event
+// node grown up & with old lease & label=draining + timestamp older than threshold + drain still running - should label, annotate + produce timeout event
 func TestNodeUpdateInternalUnhealthyDrainingLabelOld(t *testing.T) {
 	nodeName := "test-node1"
 	namespaceName := "dummy-ns"
@@ -323,10 +359,13 @@ func TestNodeUpdateInternalUnhealthyDrainingLabelOld(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
+	node.EXPECT().GetDrainStatus().Return(nodepkg.DrainStatusRunning).Times(1)
 
 	getTimestampCall := node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-100*time.Second), getTimestampErr).Times(1)
 	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodePreparingTermination)
@@ -338,11 +377,50 @@ func TestNodeUpdateInternalUnhealthyDrainingLabelOld(t *testing.T) {
 		Namespace:                    namespaceName,
 		CloudPrepareTerminationDelay: 90,
 	}
+	timedOutBefore := testutil.ToFloat64(collectors.DrainOutcomes.WithLabelValues(nodepkg.DrainOutcomeTimedOut))
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
-	assert.Len(t, events.Items, 1)
+	assert.Len(t, events.Items, 2)
+	assert.Equal(t, timedOutBefore+1, testutil.ToFloat64(collectors.DrainOutcomes.WithLabelValues(nodepkg.DrainOutcomeTimedOut)))
+}
+
+// node grown up & with old lease & label=draining + timestamp less than threshold + drain finished - should label, annotate + produce event
+func TestNodeUpdateInternalUnhealthyDrainingDrainFinished(t *testing.T) {
+	for _, drainStatus := range []string{nodepkg.DrainStatusSucceeded, nodepkg.DrainStatusFailed} {
+		t.Run(drainStatus, func(t *testing.T) {
+			nodeName := "test-node1"
+			namespaceName := "dummy-ns"
+			mockCtrl := gomock.NewController(t)
+			node := mocknode.NewMockNODE(mockCtrl)
+
+			node.EXPECT().GetName().Return(nodeName).AnyTimes()
+			node.EXPECT().GetKind().Return("Node").AnyTimes()
+			node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+			node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+			node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+			node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+			node.EXPECT().GetLabel().Return(nodepkg.NodeDraining).Times(1)
+			node.EXPECT().GetDrainStatus().Return(drainStatus).Times(1)
+			node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-5*time.Second), nil).Times(1)
+			setLabelCall := node.EXPECT().SetLabel(nodepkg.NodePreparingTermination)
+			setTimestampCall := node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+			node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(setLabelCall).After(setTimestampCall)
+
+			cfg := config.Config{
+				K8sClient:                    fake.NewClientset(),
+				Namespace:                    namespaceName,
+				CloudPrepareTerminationDelay: 90,
+			}
+
+			nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+			events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+			assert.NoError(t, evErr)
+			assert.Len(t, events.Items, 1)
+		})
+	}
 }
 
 // node grown up &with old lease & label=preparing_termination - should prepare termination and label: termination_prepared
@@ -359,6 +437,8 @@ func TestNodeUpdateInternalPrepareTermination(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -374,12 +454,176 @@ func TestNodeUpdateInternalPrepareTermination(t *testing.T) {
 		Namespace: namespaceName,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
 }
 
+// node grown up & with fresh lease & label=termination_prepared & rollback enabled - should roll back termination and make node healthy
+func TestNodeUpdateInternalRollbackTermination(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminationPrepared).Times(1)
+	node.EXPECT().IsTerminated().Return(false).AnyTimes()
+	rollbackCall := node.EXPECT().RollbackTermination(gomock.Any(), gomock.Any()).Return("Instance Termination Rolled Back", nil).Times(1)
+	uncordonCall := node.EXPECT().Uncordon().Times(1)
+	untaintCall := node.EXPECT().Untaint().Times(1)
+	removeTimestampCall := node.EXPECT().RemoveActionTimestamp().Times(1)
+	removeDrainCall := node.EXPECT().RemoveDrainAnnotations().Times(1)
+	removeLabelCall := node.EXPECT().RemoveLabel().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(rollbackCall).After(uncordonCall).After(untaintCall).After(removeTimestampCall).After(removeDrainCall).After(removeLabelCall)
+
+	cfg := config.Config{
+		K8sClient:           fake.NewClientset(),
+		Namespace:           namespaceName,
+		RollbackTermination: true,
+	}
+
+	err := nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.NoError(t, err)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, "Remediation aborted", events.Items[0].Reason)
+	}
+}
+
+// node grown up & with fresh lease & label=termination_prepared & rollback enabled - failed rollback is retried and node is kept in its state
+func TestNodeUpdateInternalRollbackTerminationFailed(t *testing.T) {
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminationPrepared).Times(1)
+	node.EXPECT().IsTerminated().Return(false).AnyTimes()
+	node.EXPECT().RollbackTermination(gomock.Any(), gomock.Any()).Return("Instance Termination Rollback Failed", fmt.Errorf("test error")).Times(1)
+	node.EXPECT().RemoveLabel().Times(0)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient:           fake.NewClientset(),
+		Namespace:           namespaceName,
+		RollbackTermination: true,
+	}
+
+	err := nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.Error(t, err)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
+
+// node grown up & with fresh lease & label=termination_failed & rollback enabled - should roll back termination and make node healthy
+func TestNodeUpdateInternalRollbackTerminationFailedState(t *testing.T) {
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminationFailed).Times(1)
+	node.EXPECT().IsTerminated().Return(false).AnyTimes()
+	rollbackCall := node.EXPECT().RollbackTermination(gomock.Any(), gomock.Any()).Return("Instance Termination Rolled Back", nil).Times(1)
+	node.EXPECT().Uncordon().Times(1)
+	node.EXPECT().Untaint().Times(1)
+	node.EXPECT().RemoveActionTimestamp().Times(1)
+	node.EXPECT().RemoveDrainAnnotations().Times(1)
+	removeLabelCall := node.EXPECT().RemoveLabel().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(rollbackCall).After(removeLabelCall)
+
+	cfg := config.Config{
+		K8sClient:                fake.NewClientset(),
+		Namespace:                namespaceName,
+		RollbackTermination:      true,
+		TerminationFailedTimeout: 60,
+	}
+
+	err := nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.NoError(t, err)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, "Remediation aborted", events.Items[0].Reason)
+	}
+}
+
+// node grown up & with fresh lease & label=terminating & already terminated & rollback enabled - terminated node isn't rolled back
+func TestNodeUpdateInternalRollbackTerminationTerminated(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminating).Times(1)
+	node.EXPECT().IsTerminated().Return(true).AnyTimes()
+	node.EXPECT().RollbackTermination(gomock.Any(), gomock.Any()).Times(0)
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Times(0)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient:           fake.NewClientset(),
+		Namespace:           "dummy-ns",
+		RollbackTermination: true,
+	}
+
+	err := nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.NoError(t, err)
+}
+
+// node grown up & with fresh lease & label=termination_prepared & rollback disabled - termination continues
+func TestNodeUpdateInternalRollbackTerminationDisabled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminationPrepared).Times(1)
+	node.EXPECT().RollbackTermination(gomock.Any(), gomock.Any()).Times(0)
+	node.EXPECT().GetActionTimestamp().Return(time.Now(), nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient:             fake.NewClientset(),
+		Namespace:             "dummy-ns",
+		CloudTerminationDelay: 90,
+	}
+
+	err := nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	assert.NoError(t, err)
+}
+
 // node grown up &with old lease & label=prepared_termination + timestamp is older than CloudPrepareTerminationDelay - should prepare termination and label: terminating
 func TestNodeUpdateInternalPreparedTerminationOld(t *testing.T) {
 	nodeName := "test-node1"
@@ -393,6 +637,8 @@ func TestNodeUpdateInternalPreparedTerminationOld(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -407,7 +653,7 @@ func TestNodeUpdateInternalPreparedTerminationOld(t *testing.T) {
 		CloudTerminationDelay: 90,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
@@ -425,6 +671,8 @@ func TestNodeUpdateInternalPreparedTerminationRecent(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
@@ -437,7 +685,7 @@ func TestNodeUpdateInternalPreparedTerminationRecent(t *testing.T) {
 		CloudTerminationDelay: 90,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 0) //no node was saved
@@ -457,19 +705,26 @@ func TestNodeUpdateInternalUnhealthyDeletingOldLease(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
 
+	node.EXPECT().IsTerminated().Return(false).Times(1)
+	node.EXPECT().GetTerminationAttempts().Return(0, time.Time{}).Times(1)
 	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Return(terminationAction, terminationErr).Times(1)
+	node.EXPECT().SetTerminated(gomock.Any()).Times(1)
+	node.EXPECT().RemoveTerminationAttempts().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
 
 	cfg := config.Config{
 		K8sClient: fake.NewClientset(),
 		Namespace: namespaceName,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
@@ -490,34 +745,31 @@ func TestNodeUpdateInternalUnhealthyDeletingFreshLease(t *testing.T) {
 
 	node.EXPECT().GetName().Return(nodeName).AnyTimes()
 	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
 
 	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
 	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(hasFreshLease, hasFreshLeaseErr).Times(1)
 	node.EXPECT().GetLabel().Return(nodeLabel).Times(1)
 
+	node.EXPECT().IsTerminated().Return(false).Times(1)
+	node.EXPECT().GetTerminationAttempts().Return(0, time.Time{}).Times(1)
 	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Return(terminationAction, terminationErr).Times(1)
+	node.EXPECT().SetTerminated(gomock.Any()).Times(1)
+	node.EXPECT().RemoveTerminationAttempts().Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
 
 	cfg := config.Config{
 		K8sClient: fake.NewClientset(),
 		Namespace: namespaceName,
 	}
 
-	nodeUpdateInternal(context.TODO(), &cfg, node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
 	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
 	assert.NoError(t, evErr)
 	assert.Len(t, events.Items, 1)
 }
 
-func TestGetDefaultUpdateHandlerFuncs(t *testing.T) {
-	ctx := context.TODO()
-	cfg := &config.Config{}
-
-	result := GetDefaultUpdateHandlerFuncs(ctx, cfg)
-	assert.Nil(t, result.DeleteFunc)
-	assert.NotNil(t, result.AddFunc)
-	assert.NotNil(t, result.UpdateFunc)
-}
-
 func TestIsAfterInitialDelayOk(t *testing.T) {
 	cfg := config.Config{
 		StartupTime:  time.Now().Add(-50 * time.Second),
@@ -535,3 +787,282 @@ func TestIsAfterInitialDelayNok(t *testing.T) {
 	ret := isAfterInitialDelay(&cfg)
 	assert.False(t, ret)
 }
+
+// node grown up & with old lease & label=termination_prepared + force cleanup enabled - should force delete pods & volume attachments again
+func TestNodeUpdateInternalPreparedTerminationForceCleanup(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeTerminationPrepared).Times(1)
+	node.EXPECT().ForceDeleteTerminatingPods(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	node.EXPECT().DeleteVolumeAttachments(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-5*time.Second), nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient:                       fake.NewClientset(),
+		Namespace:                       namespaceName,
+		CloudTerminationDelay:           90,
+		ForceDeleteTerminatingPodsAfter: 60,
+		DeleteVolumeAttachments:         true,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 0)
+}
+
+// node grown up & with old lease & label=draining + drain finished + force cleanup enabled - should label preparing_termination & force delete pods & volume attachments
+func TestNodeUpdateInternalDrainingForceCleanup(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeDraining).Times(1)
+	node.EXPECT().GetDrainStatus().Return(nodepkg.DrainStatusSucceeded).Times(1)
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-5*time.Second), nil).Times(1)
+	node.EXPECT().SetLabel(nodepkg.NodePreparingTermination).Times(1)
+	node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+	saveCall := node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	node.EXPECT().ForceDeleteTerminatingPods(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(saveCall)
+	node.EXPECT().DeleteVolumeAttachments(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(saveCall)
+
+	cfg := config.Config{
+		K8sClient:                       fake.NewClientset(),
+		Namespace:                       namespaceName,
+		CloudPrepareTerminationDelay:    90,
+		ForceDeleteTerminatingPodsAfter: 60,
+		DeleteVolumeAttachments:         true,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
+
+// node grown up & with old lease & label=draining + drain finished + out-of-service enabled - should label out_of_service
+func TestNodeUpdateInternalDrainingToOutOfService(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeDraining).Times(1)
+	node.EXPECT().GetDrainStatus().Return(nodepkg.DrainStatusSucceeded).Times(1)
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-5*time.Second), nil).Times(1)
+	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodeOutOfService).Times(1)
+	setTimestampCall := node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(setLabelCall).After(setTimestampCall)
+
+	cfg := config.Config{
+		K8sClient:                    fake.NewClientset(),
+		Namespace:                    namespaceName,
+		CloudPrepareTerminationDelay: 90,
+		OutOfServiceTaintDelay:       600,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
+
+// node grown up & with old lease & label=out_of_service + unhealthy for short time - should do nothing
+func TestNodeUpdateInternalOutOfServiceRecent(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeOutOfService).Times(1)
+	node.EXPECT().GetUnhealthySince().Return(time.Now().Add(-100*time.Second), nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient:              fake.NewClientset(),
+		Namespace:              namespaceName,
+		OutOfServiceTaintDelay: 600,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 0)
+}
+
+// node grown up & with old lease & label=out_of_service + unhealthy for short time + force cleanup enabled - should retry cleanup
+func TestNodeUpdateInternalOutOfServiceForceCleanupRetry(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(2)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeOutOfService).Times(2)
+	node.EXPECT().GetUnhealthySince().Return(time.Now().Add(-100*time.Second), nil).Times(2)
+	failedCall := node.EXPECT().ForceDeleteTerminatingPods(gomock.Any(), gomock.Any()).Return(fmt.Errorf("test error")).Times(1)
+	node.EXPECT().ForceDeleteTerminatingPods(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(failedCall)
+
+	cfg := config.Config{
+		K8sClient:                       fake.NewClientset(),
+		Namespace:                       namespaceName,
+		OutOfServiceTaintDelay:          600,
+		ForceDeleteTerminatingPodsAfter: 60,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+}
+
+// node grown up & with old lease & label=out_of_service + unhealthy long enough - should taint out-of-service and label preparing_termination
+func TestNodeUpdateInternalOutOfServiceOld(t *testing.T) {
+	nodeName := "test-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(nodepkg.NodeOutOfService).Times(1)
+	// unhealthy-since annotation is missing - time of entering out_of_service state is used
+	node.EXPECT().GetUnhealthySince().Return(time.Now(), fmt.Errorf("missing annotation")).Times(1)
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-700*time.Second), nil).Times(1)
+	taintCall := node.EXPECT().TaintOutOfService().Times(1)
+	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodePreparingTermination).Times(1)
+	setTimestampCall := node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(taintCall).After(setLabelCall).After(setTimestampCall)
+
+	cfg := config.Config{
+		K8sClient:              fake.NewClientset(),
+		Namespace:              namespaceName,
+		OutOfServiceTaintDelay: 600,
+	}
+
+	nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Len(t, events.Items, 1)
+}
+
+// node matching policy with disabled actions - should stop before disabled action
+func TestNodeUpdateInternalPolicyDisabledActions(t *testing.T) {
+	policyCfg := func(disabledActions ...string) config.Config {
+		cfg := config.Config{
+			K8sClient:     fake.NewClientset(),
+			Namespace:     "dummy-ns",
+			LeaseLockName: "test",
+			DrainDelay:    90,
+			Policies: []config.Policy{
+				{
+					Name:            "system",
+					NodeSelector:    "node-role=system",
+					Selector:        labels.SelectorFromSet(labels.Set{"node-role": "system"}),
+					DisabledActions: disabledActions,
+				},
+			},
+		}
+		return cfg
+	}
+	createPolicyNode := func(t *testing.T, label string) *mocknode.MockNODE {
+		mockCtrl := gomock.NewController(t)
+		node := mocknode.NewMockNODE(mockCtrl)
+		node.EXPECT().GetName().Return("test-node1").AnyTimes()
+		node.EXPECT().GetKind().Return("Node").AnyTimes()
+		node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+		node.EXPECT().RemovedBy().Return("").AnyTimes()
+		node.EXPECT().GetLabels().Return(map[string]string{"node-role": "system"}).Times(1)
+		node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+		node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+		node.EXPECT().GetLabel().Return(label).Times(1)
+		return node
+	}
+
+	t.Run("taint", func(t *testing.T) {
+		cfg := policyCfg(config.ActionTaint)
+		node := createPolicyNode(t, nodepkg.NodeUnhealthy)
+		nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	})
+	t.Run("terminate", func(t *testing.T) {
+		cfg := policyCfg(config.ActionTerminate)
+		node := createPolicyNode(t, nodepkg.NodeDraining)
+		nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	})
+	t.Run("drain", func(t *testing.T) {
+		cfg := policyCfg(config.ActionDrain)
+		node := createPolicyNode(t, nodepkg.NodeTainted)
+		node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-100*time.Second), nil).Times(1)
+		node.EXPECT().SetLabel(nodepkg.NodeDraining).Times(1)
+		node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+		node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+		nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node)
+	})
+}
+
+func TestCheckHealthStaleLease(t *testing.T) {
+	duration := int32(40)
+	fresh := metav1.NewMicroTime(time.Now())
+	stale := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	client := fake.NewClientset(
+		&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "kube-node-lease"}, Spec: coordinationv1.LeaseSpec{RenewTime: &fresh, LeaseDurationSeconds: &duration}},
+		&coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "node1-app", Namespace: "apps"}, Spec: coordinationv1.LeaseSpec{RenewTime: &stale, LeaseDurationSeconds: &duration}},
+	)
+	sources, err := healthcheck.ParseLeaseSources("kube-node-lease,apps:{{.NodeName}}-app")
+	assert.NoError(t, err)
+	n := nodepkg.CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+
+	// stale lease is the one of lease source that failed
+	cfg := config.Config{K8sClient: client, NodeLeaseNamespace: "kube-node-lease", NodeLeases: sources, NodeLeasesMode: healthcheck.LeasesModeAll}
+	result, err := checkHealth(context.TODO(), &cfg, n)
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, &healthcheck.LeaseRef{Namespace: "apps", Name: "node1-app"}, result.StaleLease)
+
+	// single lease namespace
+	cfg = config.Config{K8sClient: client, NodeLeaseNamespace: "apps"}
+	result, err = checkHealth(context.TODO(), &cfg, n)
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, &healthcheck.LeaseRef{Namespace: "apps", Name: "node1"}, result.StaleLease)
+}
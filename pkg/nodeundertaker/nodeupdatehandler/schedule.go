@@ -0,0 +1,73 @@
+package nodeupdatehandler
+
+import (
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"time"
+)
+
+// nextDeadline returns time left until node has to be processed again, because a delay blocking its next transition passes.
+// Returns 0 when there is no such delay or it has already passed - then node is processed on the next update or resync
+func nextDeadline(cfg *config.Config, n nodepkg.NODE) time.Duration {
+	if !isAfterInitialDelay(cfg) {
+		return untilDelayPasses(cfg.StartupTime, cfg.InitialDelay)
+	}
+	if cfg.HasPolicies() {
+		cfg = cfg.ForNode(n.GetLabels())
+	}
+	if !n.IsGrownUp(cfg) {
+		return untilDelayPasses(n.GetNode().CreationTimestamp.Time, cfg.NodeInitialThreshold)
+	}
+	// transitions are skipped until exclusion ends
+	if untilEnd := untilExclusionEnds(cfg, n); untilEnd > 0 {
+		return untilEnd
+	}
+	// transitions are skipped until circuit breaker closes - held nodes are processed right after cooldown
+	if cfg.CircuitBreaker != nil {
+		if untilClosed := cfg.CircuitBreaker.UntilClosed(time.Now()); untilClosed > 0 {
+			return untilClosed
+		}
+	}
+
+	var delay int
+	since, err := n.GetActionTimestamp()
+	switch n.GetLabel() {
+	case nodepkg.NodeUnhealthy:
+		// node still unhealthy after processing is held by exhausted disruption budget
+		if !cfg.TaintDisabled && (hasGlobalBudget(cfg) || hasPolicyBudget(cfg)) {
+			return budgetRetryInterval
+		}
+		return 0
+	case nodepkg.NodeTainted:
+		delay = cfg.DrainDelay
+	case nodepkg.NodeDraining:
+		// drain finishing updates the node, this is only the drain timeout
+		delay = cfg.CloudPrepareTerminationDelay
+	case nodepkg.NodeTerminationPrepared:
+		delay = cfg.CloudTerminationDelay
+	case nodepkg.NodeTerminating:
+		_, nextAttempt := n.GetTerminationAttempts()
+		return max(time.Until(nextAttempt), 0)
+	case nodepkg.NodeTerminationFailed:
+		if cfg.TerminationFailedTimeout == 0 {
+			return 0
+		}
+		delay = cfg.TerminationFailedTimeout
+	case nodepkg.NodeOutOfService:
+		delay = cfg.OutOfServiceTaintDelay
+		if unhealthySince, unhealthyErr := n.GetUnhealthySince(); unhealthyErr == nil {
+			since, err = unhealthySince, nil
+		}
+	default:
+		return 0
+	}
+	if err != nil {
+		return 0
+	}
+	return untilDelayPasses(since, delay)
+}
+
+// untilDelayPasses returns time left until delay in seconds counted from since passes, 0 if it has already passed
+func untilDelayPasses(since time.Time, delay int) time.Duration {
+	return max(time.Until(since.Add(time.Duration(delay)*time.Second)), 0)
+}
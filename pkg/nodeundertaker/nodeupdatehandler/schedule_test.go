@@ -0,0 +1,108 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/circuitbreaker"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/maintenance"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func createScheduledNode(label string, created, actionTimestamp time.Time) nodepkg.NODE {
+	n := nodepkg.CreateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", CreationTimestamp: metav1.NewTime(created)}})
+	n.SetLabel(label)
+	n.SetActionTimestamp(actionTimestamp)
+	return n
+}
+
+func assertDeadline(t *testing.T, expected, actual time.Duration) {
+	t.Helper()
+	// timestamps in annotations have precision of one second
+	assert.Greater(t, actual, expected-2*time.Second)
+	assert.LessOrEqual(t, actual, expected)
+}
+
+func TestNextDeadline(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cfg := &config.Config{DrainDelay: 100, CloudPrepareTerminationDelay: 200, CloudTerminationDelay: 300, OutOfServiceTaintDelay: 300}
+
+	assertDeadline(t, 100*time.Second, nextDeadline(cfg, createScheduledNode(nodepkg.NodeTainted, old, time.Now())))
+	assertDeadline(t, 200*time.Second, nextDeadline(cfg, createScheduledNode(nodepkg.NodeDraining, old, time.Now())))
+	assertDeadline(t, 300*time.Second, nextDeadline(cfg, createScheduledNode(nodepkg.NodeTerminationPrepared, old, time.Now())))
+
+	assert.Zero(t, nextDeadline(cfg, createScheduledNode(nodepkg.NodeTainted, old, old)))
+	assert.Zero(t, nextDeadline(cfg, createScheduledNode(nodepkg.NodeUnhealthy, old, time.Now())))
+
+	// out of service delay is counted from the time node became unhealthy
+	outOfService := createScheduledNode(nodepkg.NodeOutOfService, old, time.Now())
+	outOfService.GetNode().Annotations[nodepkg.UnhealthySinceAnnotation] = time.Now().Add(-200 * time.Second).Format(time.RFC3339)
+	assertDeadline(t, 100*time.Second, nextDeadline(cfg, outOfService))
+}
+
+func TestNextDeadlineTermination(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cfg := &config.Config{}
+
+	terminating := createScheduledNode(nodepkg.NodeTerminating, old, old)
+	assert.Zero(t, nextDeadline(cfg, terminating))
+	terminating.SetTerminationAttempts(2, time.Now().Add(60*time.Second))
+	assertDeadline(t, 60*time.Second, nextDeadline(cfg, terminating))
+
+	// failed termination waits for manual intervention unless timeout is set
+	failed := createScheduledNode(nodepkg.NodeTerminationFailed, old, time.Now())
+	assert.Zero(t, nextDeadline(cfg, failed))
+	cfg.TerminationFailedTimeout = 600
+	assertDeadline(t, 600*time.Second, nextDeadline(cfg, failed))
+}
+
+func TestNextDeadlineInitialDelays(t *testing.T) {
+	cfg := &config.Config{StartupTime: time.Now(), InitialDelay: 60, NodeInitialThreshold: 120, DrainDelay: 100}
+	n := createScheduledNode(nodepkg.NodeTainted, time.Now(), time.Now())
+
+	assertDeadline(t, 60*time.Second, nextDeadline(cfg, n))
+
+	cfg.InitialDelay = 0
+	assertDeadline(t, 120*time.Second, nextDeadline(cfg, n))
+}
+
+func TestNextDeadlineCircuitBreaker(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cfg := &config.Config{CircuitBreaker: circuitbreaker.New(10, time.Minute, 10*time.Minute)}
+	n := createScheduledNode(nodepkg.NodeUnhealthy, old, old)
+	assert.Zero(t, nextDeadline(cfg, n))
+
+	// held nodes are processed again when cooldown ends
+	cfg.CircuitBreaker.RecordStale(context.TODO(), "node1", time.Now(), 2)
+	assertDeadline(t, 10*time.Minute, nextDeadline(cfg, n))
+}
+
+func TestNextDeadlineDisruptionBudget(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	cfg := &config.Config{MaxDisruptedNodes: 1}
+
+	// held by exhausted budget
+	assert.Equal(t, budgetRetryInterval, nextDeadline(cfg, createScheduledNode(nodepkg.NodeUnhealthy, old, old)))
+	assert.Zero(t, nextDeadline(cfg, createScheduledNode(nodepkg.NodeHealthy, old, old)))
+
+	cfg.TaintDisabled = true
+	assert.Zero(t, nextDeadline(cfg, createScheduledNode(nodepkg.NodeUnhealthy, old, old)))
+}
+
+func TestNextDeadlineMaintenanceWindow(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	// window started an hour ago (cron has precision of one minute)
+	windows, err := maintenance.ParseWindows(fmt.Sprintf("%d %d * * * 2h", old.UTC().Minute(), old.UTC().Hour()))
+	assert.NoError(t, err)
+	cfg := &config.Config{MaintenanceWindows: windows}
+
+	// held nodes are processed again when the window ends
+	deadline := nextDeadline(cfg, createScheduledNode(nodepkg.NodeUnhealthy, old, old))
+	assert.Greater(t, deadline, time.Hour-time.Minute)
+	assert.LessOrEqual(t, deadline, time.Hour)
+}
@@ -0,0 +1,66 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// disagreements keeps failing signals of nodes whose health signals disagree, so disagreement is reported only when it changes
+var disagreements = struct {
+	sync.Mutex
+	failing map[string]string
+}{failing: make(map[string]string)}
+
+// forgetSignals removes disagreement of the removed node
+func forgetSignals(nodeName string) {
+	disagreements.Lock()
+	defer disagreements.Unlock()
+	delete(disagreements.failing, nodeName)
+}
+
+// reportSignals exports result of each health signal as a metric and reports event when signals start to disagree
+func reportSignals(ctx context.Context, cfg *config.Config, n nodepkg.NODE, result healthcheck.Result) {
+	if len(result.Signals) == 0 {
+		return
+	}
+	failing := make([]string, 0)
+	passing := make([]string, 0)
+	for signal, healthy := range result.Signals {
+		value := 0.0
+		if healthy {
+			value = 1
+			passing = append(passing, signal)
+		} else {
+			failing = append(failing, signal)
+		}
+		collectors.HealthSignals.WithLabelValues(n.GetName(), signal).Set(value)
+	}
+	sort.Strings(failing)
+	sort.Strings(passing)
+
+	disagreement := ""
+	if result.Disagree() {
+		disagreement = strings.Join(failing, ",")
+	}
+	disagreements.Lock()
+	last := disagreements.failing[n.GetName()]
+	if disagreement == "" {
+		delete(disagreements.failing, n.GetName())
+	} else {
+		disagreements.failing[n.GetName()] = disagreement
+	}
+	disagreements.Unlock()
+
+	if disagreement != "" && disagreement != last {
+		msg := fmt.Sprintf("failing: %s (%s), passing: %s", disagreement, result.Message, strings.Join(passing, ","))
+		nodepkg.ReportEvent(ctx, cfg, log.WarnLevel, n, "HealthCheck", "Health Signals Disagree", msg, "")
+	}
+}
@@ -0,0 +1,53 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestReportSignals(t *testing.T) {
+	nodeName := "signals-node1"
+	namespaceName := "dummy-ns"
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+	node.EXPECT().GetName().Return(nodeName).AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	cfg := config.Config{K8sClient: fake.NewClientset(), Namespace: namespaceName}
+
+	countEvents := func() int {
+		events, err := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+		assert.NoError(t, err)
+		return len(events.Items)
+	}
+	disagreeing := healthcheck.Result{Healthy: true, Check: "lease", Message: "lease: lease renewed at ...", Signals: map[string]bool{"lease": false, "http": true, "condition": true}}
+
+	reportSignals(context.TODO(), &cfg, node, disagreeing)
+	assert.Equal(t, 1, countEvents())
+	assert.Equal(t, 0.0, testutil.ToFloat64(collectors.HealthSignals.WithLabelValues(nodeName, "lease")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectors.HealthSignals.WithLabelValues(nodeName, "http")))
+
+	// the same disagreement is reported once
+	reportSignals(context.TODO(), &cfg, node, disagreeing)
+	assert.Equal(t, 1, countEvents())
+
+	// agreement resets it
+	reportSignals(context.TODO(), &cfg, node, healthcheck.Result{Healthy: true, Signals: map[string]bool{"lease": true, "http": true, "condition": true}})
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectors.HealthSignals.WithLabelValues(nodeName, "lease")))
+	reportSignals(context.TODO(), &cfg, node, disagreeing)
+	assert.Equal(t, 2, countEvents())
+
+	// results without signals (only lease checked) aren't reported
+	reportSignals(context.TODO(), &cfg, node, healthcheck.Result{Healthy: false, Check: "lease"})
+	assert.Equal(t, 2, countEvents())
+}
@@ -0,0 +1,118 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/remediation"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// maxTerminationRetryBackoff limits time between retries of failed termination
+const maxTerminationRetryBackoff = time.Hour
+
+// nodeTerminating terminates node in cloud provider. Failed termination is retried with exponential backoff,
+// after TerminationMaxAttempts failures node is labeled termination_failed. Node terminated successfully isn't terminated
+// again - only its removal from the cluster is awaited
+func nodeTerminating(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	if n.IsTerminated() {
+		log.Debugf("%s/%s: already terminated - waiting for removal from cluster", n.GetKind(), n.GetName())
+		return nil
+	}
+	attempts, nextAttempt := n.GetTerminationAttempts()
+	if time.Now().Before(nextAttempt) {
+		log.Infof("%s/%s: termination failed %d times, next attempt at %s", n.GetKind(), n.GetName(), attempts, nextAttempt.Format(time.RFC3339))
+		return nil
+	}
+
+	reason, err := n.Terminate(ctx, cfg)
+	remediation.RecordCloudProviderResponse(ctx, cfg, n, "Terminate", reason, err)
+	if err == nil {
+		nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "Termination", reason, "", "")
+		n.SetTerminated(time.Now())
+		n.RemoveTerminationAttempts()
+		if saveErr := n.Save(ctx, cfg); saveErr != nil {
+			log.Errorf("Received error while saving node %s: %v", n.GetName(), saveErr)
+			return saveErr
+		}
+		return nil
+	}
+	nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Termination", reason, err.Error(), "")
+
+	attempts++
+	if cfg.TerminationMaxAttempts > 0 && attempts >= cfg.TerminationMaxAttempts {
+		return markTerminationFailed(ctx, cfg, n, attempts, err)
+	}
+	backoff := terminationBackoff(cfg, attempts)
+	n.SetTerminationAttempts(attempts, time.Now().Add(backoff))
+	n.SetTerminationLastError(err)
+	if saveErr := n.Save(ctx, cfg); saveErr != nil {
+		log.Errorf("Received error while saving node %s: %v", n.GetName(), saveErr)
+		return saveErr
+	}
+	log.Infof("%s/%s: termination failed %d times, retrying in %s", n.GetKind(), n.GetName(), attempts, backoff)
+	return nil
+}
+
+// markTerminationFailed stops retrying termination of the node until manual intervention or TerminationFailedTimeout
+func markTerminationFailed(ctx context.Context, cfg *config.Config, n nodepkg.NODE, attempts int, terminateErr error) error {
+	// last error is kept, attempts are counted from zero when node is labeled terminating again
+	n.SetTerminationLastError(terminateErr)
+	n.RemoveTerminationAttempts()
+	n.SetActionTimestamp(time.Now())
+	n.SetLabel(nodepkg.NodeTerminationFailed)
+	err := n.Save(ctx, cfg)
+	if err != nil {
+		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", "Label termination_failed failed", err.Error(), "")
+		return err
+	}
+
+	desc := fmt.Sprintf("termination failed %d times, last error: %v - manual intervention required", attempts, terminateErr)
+	if cfg.TerminationFailedTimeout > 0 {
+		desc = fmt.Sprintf("termination failed %d times, last error: %v - it will be retried in %d seconds", attempts, terminateErr, cfg.TerminationFailedTimeout)
+	}
+	nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "TerminationFailed", "Termination failed", desc, "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeTerminationFailed, desc)
+	return nil
+}
+
+// nodeTerminationFailed labels node terminating again when TerminationFailedTimeout passes
+func nodeTerminationFailed(ctx context.Context, cfg *config.Config, n nodepkg.NODE) error {
+	if cfg.TerminationFailedTimeout == 0 {
+		log.Debugf("%s/%s: termination failed - waiting for manual intervention", n.GetKind(), n.GetName())
+		return nil
+	}
+	failedAt, err := n.GetActionTimestamp()
+	if err != nil {
+		log.Errorf("Node %s: timestamp is not parsed properly: %v", n.GetName(), err)
+		return nil
+	}
+	if failedAt.After(time.Now().Add(-time.Duration(cfg.TerminationFailedTimeout) * time.Second)) {
+		log.Infof("%s/%s: termination failed less than %d seconds ago", n.GetKind(), n.GetName(), cfg.TerminationFailedTimeout)
+		return nil
+	}
+
+	n.SetLabel(nodepkg.NodeTerminating)
+	err = n.Save(ctx, cfg)
+	if err != nil {
+		log.Errorf("Received error while saving node %s: %v", n.GetName(), err)
+		nodepkg.ReportEvent(ctx, cfg, log.ErrorLevel, n, "Label", "Label terminating failed", err.Error(), "")
+		return err
+	}
+
+	nodepkg.ReportEvent(ctx, cfg, log.InfoLevel, n, "LabelTerminating", "Labeled terminating", fmt.Sprintf("termination failed more than %d seconds ago - retrying", cfg.TerminationFailedTimeout), "")
+	remediation.RecordTransition(ctx, cfg, n, nodepkg.NodeTerminating, fmt.Sprintf("termination failed more than %d seconds ago", cfg.TerminationFailedTimeout))
+	return nil
+}
+
+// terminationBackoff returns time after which termination is retried - TerminationRetryBackoff doubled with each failed attempt
+func terminationBackoff(cfg *config.Config, attempts int) time.Duration {
+	backoff := time.Duration(cfg.TerminationRetryBackoff) * time.Second
+	for i := 1; i < attempts && backoff < maxTerminationRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxTerminationRetryBackoff)
+}
@@ -0,0 +1,186 @@
+package nodeupdatehandler
+
+import (
+	"context"
+	"errors"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	mocknode "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func createTerminationTestNode(t *testing.T, label string) *mocknode.MockNODE {
+	mockCtrl := gomock.NewController(t)
+	node := mocknode.NewMockNODE(mockCtrl)
+
+	node.EXPECT().GetName().Return("test-node1").AnyTimes()
+	node.EXPECT().GetKind().Return("Node").AnyTimes()
+	node.EXPECT().GetAnnotations().Return(nil).AnyTimes()
+	node.EXPECT().RemovedBy().Return("").AnyTimes()
+	node.EXPECT().IsGrownUp(gomock.Any()).Return(true).Times(1)
+	node.EXPECT().HasFreshLease(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	node.EXPECT().GetLabel().Return(label).Times(1)
+	return node
+}
+
+func TestTerminationBackoff(t *testing.T) {
+	cfg := &config.Config{TerminationRetryBackoff: 30}
+	assert.Equal(t, 30*time.Second, terminationBackoff(cfg, 1))
+	assert.Equal(t, 60*time.Second, terminationBackoff(cfg, 2))
+	assert.Equal(t, 240*time.Second, terminationBackoff(cfg, 4))
+	assert.Equal(t, maxTerminationRetryBackoff, terminationBackoff(cfg, 20))
+
+	assert.Zero(t, terminationBackoff(&config.Config{}, 3))
+}
+
+// termination fails - attempt is recorded with backoff and only error event is created
+func TestNodeUpdateInternalTerminationRetried(t *testing.T) {
+	namespaceName := "dummy-ns"
+	terminationErr := errors.New("api error")
+	node := createTerminationTestNode(t, nodepkg.NodeTerminating)
+
+	node.EXPECT().IsTerminated().Return(false).Times(1)
+	node.EXPECT().GetTerminationAttempts().Return(1, time.Now().Add(-time.Second)).Times(1)
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Return("Instance Termination Failed", terminationErr).Times(1)
+	node.EXPECT().SetTerminationAttempts(2, gomock.Any()).Do(func(attempts int, nextAttempt time.Time) {
+		assert.WithinDuration(t, time.Now().Add(60*time.Second), nextAttempt, time.Second)
+	}).Times(1)
+	node.EXPECT().SetTerminationLastError(terminationErr).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+	cfg := config.Config{
+		K8sClient:               fake.NewClientset(),
+		Namespace:               namespaceName,
+		TerminationMaxAttempts:  5,
+		TerminationRetryBackoff: 30,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	if assert.Len(t, events.Items, 1) {
+		assert.Equal(t, "Warning", events.Items[0].Type)
+	}
+}
+
+// node was already terminated - cloud provider isn't called again
+func TestNodeUpdateInternalTerminatingAlreadyTerminated(t *testing.T) {
+	node := createTerminationTestNode(t, nodepkg.NodeTerminating)
+
+	node.EXPECT().IsTerminated().Return(true).Times(1)
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Times(0)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient:              fake.NewClientset(),
+		Namespace:              "dummy-ns",
+		TerminationMaxAttempts: 5,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	events, evErr := cfg.K8sClient.EventsV1().Events("dummy-ns").List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	assert.Empty(t, events.Items)
+}
+
+// termination failed recently - it isn't retried before backoff passes
+func TestNodeUpdateInternalTerminationBackoff(t *testing.T) {
+	node := createTerminationTestNode(t, nodepkg.NodeTerminating)
+
+	node.EXPECT().IsTerminated().Return(false).Times(1)
+	node.EXPECT().GetTerminationAttempts().Return(2, time.Now().Add(time.Minute)).Times(1)
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient:              fake.NewClientset(),
+		Namespace:              "dummy-ns",
+		TerminationMaxAttempts: 5,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+}
+
+// termination fails for the last allowed time - node is labeled termination_failed
+func TestNodeUpdateInternalTerminationMaxAttempts(t *testing.T) {
+	namespaceName := "dummy-ns"
+	terminationErr := errors.New("api error")
+	node := createTerminationTestNode(t, nodepkg.NodeTerminating)
+
+	node.EXPECT().IsTerminated().Return(false).Times(1)
+	node.EXPECT().GetTerminationAttempts().Return(4, time.Now().Add(-time.Second)).Times(1)
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Return("Instance Termination Failed", terminationErr).Times(1)
+	node.EXPECT().SetTerminationLastError(terminationErr).Times(1)
+	node.EXPECT().RemoveTerminationAttempts().Times(1)
+	node.EXPECT().SetActionTimestamp(gomock.Any()).Times(1)
+	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodeTerminationFailed).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(setLabelCall)
+
+	cfg := config.Config{
+		K8sClient:              fake.NewClientset(),
+		Namespace:              namespaceName,
+		TerminationMaxAttempts: 5,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+	events, evErr := cfg.K8sClient.EventsV1().Events(namespaceName).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, evErr)
+	reasons := make([]string, 0)
+	for i := range events.Items {
+		reasons = append(reasons, events.Items[i].Reason)
+	}
+	assert.ElementsMatch(t, []string{"Instance Termination Failed", "Termination failed"}, reasons)
+}
+
+// termination failed and timeout isn't set - node waits for manual intervention
+func TestNodeUpdateInternalTerminationFailedManual(t *testing.T) {
+	node := createTerminationTestNode(t, nodepkg.NodeTerminationFailed)
+
+	node.EXPECT().Terminate(gomock.Any(), gomock.Any()).Times(0)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient: fake.NewClientset(),
+		Namespace: "dummy-ns",
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+}
+
+// termination failed more than timeout ago - node is labeled terminating again
+func TestNodeUpdateInternalTerminationFailedTimeout(t *testing.T) {
+	node := createTerminationTestNode(t, nodepkg.NodeTerminationFailed)
+
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-time.Hour), nil).Times(1)
+	setLabelCall := node.EXPECT().SetLabel(nodepkg.NodeTerminating).Times(1)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil).Times(1).After(setLabelCall)
+
+	cfg := config.Config{
+		K8sClient:                fake.NewClientset(),
+		Namespace:                "dummy-ns",
+		TerminationFailedTimeout: 600,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+}
+
+// termination failed less than timeout ago - nothing happens
+func TestNodeUpdateInternalTerminationFailedBeforeTimeout(t *testing.T) {
+	node := createTerminationTestNode(t, nodepkg.NodeTerminationFailed)
+
+	node.EXPECT().GetActionTimestamp().Return(time.Now().Add(-time.Minute), nil).Times(1)
+	node.EXPECT().SetLabel(gomock.Any()).Times(0)
+	node.EXPECT().Save(gomock.Any(), gomock.Any()).Times(0)
+
+	cfg := config.Config{
+		K8sClient:                fake.NewClientset(),
+		Namespace:                "dummy-ns",
+		TerminationFailedTimeout: 600,
+	}
+
+	assert.NoError(t, nodeUpdateInternal(context.TODO(), &cfg, nodepkg.NewDrainManager(), node))
+}
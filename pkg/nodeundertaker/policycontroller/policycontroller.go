@@ -0,0 +1,255 @@
+package policycontroller
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/apis/v1alpha1"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sort"
+	"sync"
+)
+
+// PolicyController watches NodeRemediationPolicy resources, keeps valid ones in policy store of configuration and writes back their status
+type PolicyController struct {
+	cfg      *config.Config
+	client   dynamic.Interface
+	informer cache.SharedIndexInformer
+	mutex    sync.Mutex
+	// queue deduplicates requests to reconcile policies - burst of node or policy changes is reconciled once
+	queue workqueue.TypedInterface[string]
+	// dryRunStatuses keeps statuses of policies in dry-run mode, as they aren't written to the cluster
+	dryRunStatuses map[string]v1alpha1.NodeRemediationPolicyStatus
+}
+
+// reconcileKey is the only item of the queue - all policies are reconciled together
+const reconcileKey = "policies"
+
+func New(cfg *config.Config, client dynamic.Interface) *PolicyController {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, cfg.InformerResync)
+	return &PolicyController{
+		cfg:            cfg,
+		client:         client,
+		informer:       factory.ForResource(v1alpha1.NodeRemediationPolicyGVR).Informer(),
+		queue:          workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{Name: "policies"}),
+		dryRunStatuses: make(map[string]v1alpha1.NodeRemediationPolicyStatus),
+	}
+}
+
+// Start starts watching policies and waits until they are loaded
+func (c *PolicyController) Start(ctx context.Context) error {
+	_, err := c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.onChange() },
+		UpdateFunc: func(oldObj, newObj interface{}) { c.onChange() },
+		DeleteFunc: func(obj interface{}) { c.onChange() },
+	})
+	if err != nil {
+		return err
+	}
+	go c.informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for %s cache to sync", v1alpha1.NodeRemediationPolicyResource)
+	}
+	c.reconcile(ctx)
+	go c.run(ctx)
+	return nil
+}
+
+// run reconciles policies whenever it is requested, until ctx is done
+func (c *PolicyController) run(ctx context.Context) {
+	context.AfterFunc(ctx, c.queue.ShutDown)
+	for {
+		key, shutdown := c.queue.Get()
+		if shutdown {
+			return
+		}
+		c.reconcile(ctx)
+		c.queue.Done(key)
+	}
+}
+
+// NodeHandlerFuncs returns handler of node events - policies are reconciled when nodes matching them can change,
+// so number of matched nodes is kept up to date
+func (c *PolicyController) NodeHandlerFuncs() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.onChange() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, oldOk := oldObj.(*v1.Node)
+			newNode, newOk := newObj.(*v1.Node)
+			if oldOk && newOk && equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) {
+				return
+			}
+			c.onChange()
+		},
+		DeleteFunc: func(obj interface{}) { c.onChange() },
+	}
+}
+
+func (c *PolicyController) onChange() {
+	// before sync everything is reconciled once by Start
+	if c.informer.HasSynced() {
+		c.queue.Add(reconcileKey)
+	}
+}
+
+// reconcile validates all policies, replaces policies in the store with valid ones and updates status of each of them
+func (c *PolicyController) reconcile(ctx context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fileNames := make(map[string]bool)
+	for i := range c.cfg.Policies {
+		fileNames[c.cfg.Policies[i].Name] = true
+	}
+
+	objects := c.informer.GetStore().List()
+	resources := make([]*unstructured.Unstructured, 0, len(objects))
+	for i := range objects {
+		if u, ok := objects[i].(*unstructured.Unstructured); ok {
+			resources = append(resources, u)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].GetName() < resources[j].GetName() })
+
+	policies := make([]config.Policy, 0, len(resources))
+	statuses := make([]*v1alpha1.NodeRemediationPolicy, len(resources))
+	errs := make([]error, len(resources))
+	for i := range resources {
+		policy := &v1alpha1.NodeRemediationPolicy{}
+		converted := config.Policy{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(resources[i].Object, policy)
+		if err == nil {
+			converted = toPolicy(policy)
+			if fileNames[policy.Name] {
+				err = fmt.Errorf("policy %s: name is already used in policies file", policy.Name)
+			} else if converted.NodeLeaseNamespace != nil && !c.cfg.PolicyStore.IsLeaseNamespaceWatched(*converted.NodeLeaseNamespace) {
+				err = fmt.Errorf("policy %s: leases in namespace %s aren't watched - node-undertaker has to be restarted to use it", policy.Name, *converted.NodeLeaseNamespace)
+			} else {
+				err = config.ValidatePolicy(c.cfg, &converted)
+			}
+		} else {
+			err = fmt.Errorf("policy %s: couldn't be read: %w", resources[i].GetName(), err)
+		}
+		if err != nil {
+			log.Errorf("%s/%s: %v", v1alpha1.NodeRemediationPolicyKind, resources[i].GetName(), err)
+		} else {
+			policies = append(policies, converted)
+		}
+		statuses[i] = policy
+		errs[i] = err
+	}
+	c.cfg.PolicyStore.Set(policies)
+
+	matched := c.countMatchedNodes()
+	names := make(map[string]bool, len(resources))
+	for i := range resources {
+		names[resources[i].GetName()] = true
+		c.updateStatus(ctx, resources[i], statuses[i], matched[resources[i].GetName()], errs[i])
+	}
+	for name := range c.dryRunStatuses {
+		if !names[name] {
+			delete(c.dryRunStatuses, name)
+		}
+	}
+}
+
+// toPolicy converts NodeRemediationPolicy resource to policy named after the resource
+func toPolicy(policy *v1alpha1.NodeRemediationPolicy) config.Policy {
+	spec := &policy.Spec
+	return config.Policy{
+		Name:                              policy.Name,
+		NodeSelector:                      spec.NodeSelector,
+		NodeInitialThreshold:              spec.NodeInitialThreshold,
+		DrainDelay:                        spec.DrainDelay,
+		CloudPrepareTerminationDelay:      spec.CloudPrepareTerminationDelay,
+		CloudTerminationDelay:             spec.CloudTerminationDelay,
+		DrainTimeout:                      spec.DrainTimeout,
+		DrainGracePeriod:                  spec.DrainGracePeriod,
+		DrainDisableEvictionAfterAttempts: spec.DrainDisableEvictionAfterAttempts,
+		DrainPodSelector:                  spec.DrainPodSelector,
+		DrainSkipWaitForDeleteTimeout:     spec.DrainSkipWaitForDeleteTimeout,
+		DisabledActions:                   spec.DisabledActions,
+		NodeLeaseNamespace:                spec.NodeLeaseNamespace,
+		NotificationsSlackWebhook:         spec.NotificationsSlackWebhook,
+		MaxDisruptedNodes:                 spec.MaxDisruptedNodes,
+		MaxDisruptedNodesPercentage:       spec.MaxDisruptedNodesPercentage,
+	}
+}
+
+// countMatchedNodes returns number of watched nodes each policy is applied to
+func (c *PolicyController) countMatchedNodes() map[string]int {
+	ret := make(map[string]int)
+	if c.cfg.NodeLister == nil {
+		return ret
+	}
+	nodes, err := c.cfg.NodeLister.List(c.cfg.NodeSelector)
+	if err != nil {
+		log.Errorf("Couldn't list nodes to count nodes matching policies: %v", err)
+		return ret
+	}
+	for i := range nodes {
+		if name := c.cfg.ForNode(nodes[i].ObjectMeta.Labels).PolicyName; name != "" {
+			ret[name]++
+		}
+	}
+	return ret
+}
+
+// updateStatus writes status of the policy if it changed. In dry-run mode status is only kept in memory
+func (c *PolicyController) updateStatus(ctx context.Context, resource *unstructured.Unstructured, policy *v1alpha1.NodeRemediationPolicy, matchedNodes int, policyErr error) {
+	previous := policy.Status
+	if stored, found := c.dryRunStatuses[resource.GetName()]; found && c.cfg.DryRun {
+		previous = stored
+	}
+	status := previous
+	status.Conditions = append([]metav1.Condition{}, previous.Conditions...)
+	status.ObservedGeneration = resource.GetGeneration()
+	status.MatchedNodes = matchedNodes
+	condition := metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: resource.GetGeneration(),
+		Reason:             v1alpha1.ReasonValid,
+		Message:            "policy is applied",
+	}
+	status.LastError = ""
+	if policyErr != nil {
+		status.LastError = policyErr.Error()
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = v1alpha1.ReasonInvalid
+		condition.Message = policyErr.Error()
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+	if equality.Semantic.DeepEqual(previous, status) {
+		return
+	}
+	if c.cfg.DryRun {
+		c.dryRunStatuses[resource.GetName()] = status
+		log.Infof("%s%s/%s: status would be updated - matched nodes: %d, last error: '%s'", nodepkg.DryRunMessagePrefix, v1alpha1.NodeRemediationPolicyKind, resource.GetName(), status.MatchedNodes, status.LastError)
+		return
+	}
+
+	statusObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		log.Errorf("%s/%s: couldn't convert status: %v", v1alpha1.NodeRemediationPolicyKind, resource.GetName(), err)
+		return
+	}
+	updated := resource.DeepCopy()
+	updated.Object["status"] = statusObject
+	_, err = c.client.Resource(v1alpha1.NodeRemediationPolicyGVR).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		// policy is reconciled again when newer version is received by informer
+		log.Warnf("%s/%s: couldn't update status: %v", v1alpha1.NodeRemediationPolicyKind, resource.GetName(), err)
+	}
+}
@@ -0,0 +1,200 @@
+package policycontroller
+
+import (
+	"context"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/apis/v1alpha1"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+	"time"
+)
+
+func createPolicy(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1alpha1.Group + "/" + v1alpha1.Version,
+		"kind":       v1alpha1.NodeRemediationPolicyKind,
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"generation": int64(1),
+		},
+		"spec": spec,
+	}}
+}
+
+func createTestConfig(t *testing.T, nodes ...*v1.Node) *config.Config {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for i := range nodes {
+		require.NoError(t, indexer.Add(nodes[i]))
+	}
+	return &config.Config{
+		LeaseLockName:                "test",
+		DrainDelay:                   300,
+		CloudPrepareTerminationDelay: 300,
+		DrainGracePeriod:             -1,
+		InformerResync:               time.Minute,
+		NodeSelector:                 labels.Everything(),
+		NodeLister:                   corelisters.NewNodeLister(indexer),
+		PolicyStore:                  config.NewPolicyStore(),
+	}
+}
+
+func getStatus(t *testing.T, client *dynamicfake.FakeDynamicClient, name string) v1alpha1.NodeRemediationPolicyStatus {
+	u, err := client.Resource(v1alpha1.NodeRemediationPolicyGVR).Get(context.TODO(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	policy := v1alpha1.NodeRemediationPolicy{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &policy))
+	return policy.Status
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"capacity-type": "spot"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"capacity-type": "spot"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node3", Labels: map[string]string{"capacity-type": "on-demand"}}},
+	}
+	cfg := createTestConfig(t, nodes...)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot", "drainDelay": int64(10)}),
+		createPolicy("invalid", map[string]interface{}{"nodeSelector": "capacity-type=on-demand", "drainDelay": int64(-1)}),
+	)
+
+	err := New(cfg, client).Start(ctx)
+	require.NoError(t, err)
+
+	policies := cfg.PolicyStore.Get()
+	require.Len(t, policies, 1)
+	assert.Equal(t, "spot", policies[0].Name)
+	assert.Equal(t, 10, cfg.ForNode(nodes[0].Labels).DrainDelay)
+	assert.Equal(t, 300, cfg.ForNode(nodes[2].Labels).DrainDelay)
+
+	status := getStatus(t, client, "spot")
+	assert.Equal(t, 2, status.MatchedNodes)
+	assert.Equal(t, "", status.LastError)
+	assert.Equal(t, int64(1), status.ObservedGeneration)
+	assert.True(t, meta.IsStatusConditionTrue(status.Conditions, v1alpha1.ConditionReady))
+
+	status = getStatus(t, client, "invalid")
+	assert.Equal(t, 0, status.MatchedNodes)
+	assert.Contains(t, status.LastError, "drain-delay")
+	assert.True(t, meta.IsStatusConditionFalse(status.Conditions, v1alpha1.ConditionReady))
+}
+
+func TestStartDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cfg := createTestConfig(t, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"capacity-type": "spot"}}})
+	cfg.DryRun = true
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot"}),
+	)
+
+	controller := New(cfg, client)
+	err := controller.Start(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.PolicyStore.Get(), 1)
+	assert.Empty(t, getStatus(t, client, "spot"))
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+	assert.Equal(t, 1, controller.dryRunStatuses["spot"].MatchedNodes)
+}
+
+func TestReconcileNameUsedInPoliciesFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cfg := createTestConfig(t)
+	cfg.Policies = []config.Policy{{Name: "spot", NodeSelector: "capacity-type=spot"}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot"}),
+	)
+
+	err := New(cfg, client).Start(ctx)
+	require.NoError(t, err)
+
+	assert.Empty(t, cfg.PolicyStore.Get())
+	status := getStatus(t, client, "spot")
+	assert.Contains(t, status.LastError, "already used")
+}
+
+func TestReconcileLeaseNamespaceNotWatched(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cfg := createTestConfig(t)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot", "nodeLeaseNamespace": "spot-leases"}),
+	)
+	err := New(cfg, client).Start(ctx)
+	require.NoError(t, err)
+	require.Len(t, cfg.PolicyStore.Get(), 1)
+
+	cfg.PolicyStore.SetLeaseNamespaces([]string{"kube-node-lease", "spot-leases"})
+	_, err = client.Resource(v1alpha1.NodeRemediationPolicyGVR).Create(ctx,
+		createPolicy("gpu", map[string]interface{}{"nodeSelector": "gpu=true", "nodeLeaseNamespace": "gpu-leases"}), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return getStatus(t, client, "gpu").LastError != "" }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, getStatus(t, client, "gpu").LastError, "gpu-leases aren't watched")
+	require.Len(t, cfg.PolicyStore.Get(), 1)
+	assert.Equal(t, "spot", cfg.PolicyStore.Get()[0].Name)
+}
+
+func TestReconcileDeletedPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	cfg := createTestConfig(t)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot"}),
+	)
+	err := New(cfg, client).Start(ctx)
+	require.NoError(t, err)
+	require.Len(t, cfg.PolicyStore.Get(), 1)
+
+	err = client.Resource(v1alpha1.NodeRemediationPolicyGVR).Delete(ctx, "spot", metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(cfg.PolicyStore.Get()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNodeHandlerFuncsUpdateMatchedNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	node1 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"capacity-type": "spot"}}}
+	cfg := createTestConfig(t, node1)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationPolicyGVR: v1alpha1.NodeRemediationPolicyKind + "List"},
+		createPolicy("spot", map[string]interface{}{"nodeSelector": "capacity-type=spot"}),
+	)
+	controller := New(cfg, client)
+	require.NoError(t, controller.Start(ctx))
+	require.Equal(t, 1, getStatus(t, client, "spot").MatchedNodes)
+
+	// node relabeled to match the policy
+	node2 := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2", Labels: map[string]string{"capacity-type": "on-demand"}}}
+	relabeled := node2.DeepCopy()
+	relabeled.Labels["capacity-type"] = "spot"
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(node1))
+	require.NoError(t, indexer.Add(relabeled))
+	cfg.NodeLister = corelisters.NewNodeLister(indexer)
+
+	controller.NodeHandlerFuncs().OnUpdate(node2, relabeled)
+	assert.Eventually(t, func() bool { return getStatus(t, client, "spot").MatchedNodes == 2 }, 5*time.Second, 10*time.Millisecond)
+}
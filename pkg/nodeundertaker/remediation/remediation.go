@@ -0,0 +1,199 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/apis/v1alpha1"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	"strconv"
+)
+
+// maxHistory is the maximum number of transitions and cloud provider responses kept in status
+const maxHistory = 20
+
+// RecordTransition records in NodeRemediation that node entered the phase. NodeRemediation is created for unhealthy nodes only
+func RecordTransition(ctx context.Context, cfg *config.Config, n nodepkg.NODE, phase, reason string) {
+	recordTransition(ctx, cfg, n, phase, reason, nil)
+}
+
+// RecordUnhealthy records in NodeRemediation that node became unhealthy together with the stale lease found by health check
+func RecordUnhealthy(ctx context.Context, cfg *config.Config, n nodepkg.NODE, reason string, staleLease *healthcheck.LeaseRef) {
+	recordTransition(ctx, cfg, n, nodepkg.NodeUnhealthy, reason, staleLease)
+}
+
+func recordTransition(ctx context.Context, cfg *config.Config, n nodepkg.NODE, phase, reason string, staleLease *healthcheck.LeaseRef) {
+	if !enabled(cfg) {
+		return
+	}
+	node := n.GetNode()
+	statusPhase := phase
+	if phase == nodepkg.NodeHealthy {
+		statusPhase = v1alpha1.PhaseHealthy
+	}
+	err := updateStatus(ctx, cfg, node, phase != nodepkg.NodeHealthy, func(node *v1.Node, status *v1alpha1.NodeRemediationStatus) {
+		now := metav1.Now()
+		status.Phase = statusPhase
+		status.Transitions = appendLimited(status.Transitions, v1alpha1.PhaseTransition{Phase: statusPhase, Time: now, Reason: reason})
+		if phase == nodepkg.NodeUnhealthy {
+			// new incident starts - results of the previous one are kept only in history
+			status.Drain = nil
+			status.Retries = 0
+			status.StaleLease = getStaleLease(ctx, cfg, node.Name, staleLease, now)
+		}
+		if drain := getDrainResult(node); drain != nil {
+			status.Drain = drain
+		}
+	})
+	if err != nil {
+		log.Errorf("%s/%s: couldn't record transition to %s: %v", v1alpha1.NodeRemediationKind, node.Name, statusPhase, err)
+	}
+}
+
+// RecordCloudProviderResponse records in NodeRemediation response of cloud provider to the action. Failed actions are counted as retries
+func RecordCloudProviderResponse(ctx context.Context, cfg *config.Config, n nodepkg.NODE, action, response string, actionErr error) {
+	if !enabled(cfg) {
+		return
+	}
+	node := n.GetNode()
+	err := updateStatus(ctx, cfg, node, true, func(node *v1.Node, status *v1alpha1.NodeRemediationStatus) {
+		entry := v1alpha1.CloudProviderResponse{Time: metav1.Now(), Action: action, Response: response}
+		if actionErr != nil {
+			entry.Error = actionErr.Error()
+			status.Retries++
+		}
+		status.CloudProviderResponses = appendLimited(status.CloudProviderResponses, entry)
+	})
+	if err != nil {
+		log.Errorf("%s/%s: couldn't record cloud provider response: %v", v1alpha1.NodeRemediationKind, node.Name, err)
+	}
+}
+
+// RecordRemoval marks NodeRemediation of the removed node as completed, it isn't created if it doesn't exist
+func RecordRemoval(ctx context.Context, cfg *config.Config, node *v1.Node, reason string) {
+	if !enabled(cfg) {
+		return
+	}
+	err := updateStatus(ctx, cfg, node, false, func(node *v1.Node, status *v1alpha1.NodeRemediationStatus) {
+		status.Phase = v1alpha1.PhaseCompleted
+		status.Transitions = appendLimited(status.Transitions, v1alpha1.PhaseTransition{Phase: v1alpha1.PhaseCompleted, Time: metav1.Now(), Reason: reason})
+		if drain := getDrainResult(node); drain != nil {
+			status.Drain = drain
+		}
+	})
+	if err != nil {
+		log.Errorf("%s/%s: couldn't record node removal: %v", v1alpha1.NodeRemediationKind, node.Name, err)
+	}
+}
+
+// enabled returns true if NodeRemediation resources should be written. Nothing is written in dry-run mode
+func enabled(cfg *config.Config) bool {
+	return cfg.RemediationResources && !cfg.DryRun && cfg.DynamicClient != nil
+}
+
+// updateStatus modifies status of NodeRemediation of the node, creating it if needed
+func updateStatus(ctx context.Context, cfg *config.Config, node *v1.Node, create bool, modify func(node *v1.Node, status *v1alpha1.NodeRemediationStatus)) error {
+	nodeName := node.Name
+	client := cfg.DynamicClient.Resource(v1alpha1.NodeRemediationGVR).Namespace(cfg.Namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u, err := client.Get(ctx, nodeName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			if !create {
+				return nil
+			}
+			u, err = client.Create(ctx, newNodeRemediation(cfg, node), metav1.CreateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+
+		remediation := v1alpha1.NodeRemediation{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &remediation)
+		if err != nil {
+			return fmt.Errorf("couldn't read %s: %w", v1alpha1.NodeRemediationKind, err)
+		}
+		modify(node, &remediation.Status)
+		status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&remediation.Status)
+		if err != nil {
+			return err
+		}
+		u.Object["status"] = status
+		_, err = client.UpdateStatus(ctx, u, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// newNodeRemediation creates NodeRemediation owned by the node, so it is garbage collected together with it
+func newNodeRemediation(cfg *config.Config, node *v1.Node) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(v1alpha1.Group + "/" + v1alpha1.Version)
+	u.SetKind(v1alpha1.NodeRemediationKind)
+	u.SetName(node.Name)
+	u.SetNamespace(cfg.Namespace)
+	u.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       node.Name,
+		UID:        node.UID,
+	}})
+	return u
+}
+
+// getStaleLease reads the lease found stale by health check. Returns nil when node became unhealthy for other reasons
+func getStaleLease(ctx context.Context, cfg *config.Config, nodeName string, ref *healthcheck.LeaseRef, now metav1.Time) *v1alpha1.StaleLease {
+	if ref == nil {
+		return nil
+	}
+	ret := &v1alpha1.StaleLease{ObservedTime: now, Namespace: ref.Namespace, Name: ref.Name}
+	lease, err := cfg.LeaseListers.GetLease(ctx, cfg.K8sClient, ref.Namespace, ref.Name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Warnf("%s/%s: couldn't get lease: %v", v1alpha1.NodeRemediationKind, nodeName, err)
+		}
+		return ret
+	}
+	ret.Found = true
+	if lease.Spec.HolderIdentity != nil {
+		ret.HolderIdentity = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.RenewTime != nil {
+		renewTime := metav1.NewTime(lease.Spec.RenewTime.Time)
+		ret.RenewTime = &renewTime
+	}
+	if lease.Spec.LeaseDurationSeconds != nil {
+		ret.LeaseDurationSeconds = *lease.Spec.LeaseDurationSeconds
+	}
+	return ret
+}
+
+// getDrainResult reads drain result from node annotations. Returns nil if node wasn't drained
+func getDrainResult(node *v1.Node) *v1alpha1.DrainResult {
+	status, found := node.Annotations[nodepkg.DrainStatusAnnotation]
+	if !found {
+		return nil
+	}
+	attempts, _ := strconv.Atoi(node.Annotations[nodepkg.DrainAttemptsAnnotation])
+	return &v1alpha1.DrainResult{
+		Status:    status,
+		Started:   node.Annotations[nodepkg.DrainStartedAnnotation],
+		Attempts:  attempts,
+		LastError: node.Annotations[nodepkg.DrainLastErrorAnnotation],
+	}
+}
+
+func appendLimited[T any](list []T, item T) []T {
+	list = append(list, item)
+	if len(list) > maxHistory {
+		list = list[len(list)-maxHistory:]
+	}
+	return list
+}
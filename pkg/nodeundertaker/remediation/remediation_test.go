@@ -0,0 +1,239 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/apis/v1alpha1"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/config"
+	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/healthcheck"
+	nodepkg "github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+const (
+	testNamespace = "node-undertaker"
+	testNode      = "node1"
+)
+
+func createTestConfig(objects ...runtime.Object) *config.Config {
+	return &config.Config{
+		K8sClient:            fake.NewClientset(objects...),
+		DynamicClient:        dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{v1alpha1.NodeRemediationGVR: v1alpha1.NodeRemediationKind + "List"}),
+		Namespace:            testNamespace,
+		NodeLeaseNamespace:   "kube-node-lease",
+		RemediationResources: true,
+	}
+}
+
+func createTestNode(annotations map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNode, UID: types.UID("node1-uid"), Annotations: annotations}}
+}
+
+func getRemediation(t *testing.T, cfg *config.Config) *v1alpha1.NodeRemediation {
+	u, err := cfg.DynamicClient.Resource(v1alpha1.NodeRemediationGVR).Namespace(testNamespace).Get(context.TODO(), testNode, metav1.GetOptions{})
+	require.NoError(t, err)
+	ret := v1alpha1.NodeRemediation{}
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &ret))
+	return &ret
+}
+
+func TestRecordTransition(t *testing.T) {
+	renewTime := metav1.NewMicroTime(time.Now().Add(-10 * time.Minute))
+	holder := testNode
+	duration := int32(40)
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: testNode, Namespace: "kube-node-lease"},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, RenewTime: &renewTime, LeaseDurationSeconds: &duration},
+	}
+	cfg := createTestConfig(createTestNode(nil), lease)
+
+	RecordUnhealthy(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "lease is stale", &healthcheck.LeaseRef{Namespace: "kube-node-lease", Name: testNode})
+
+	remediation := getRemediation(t, cfg)
+	require.Len(t, remediation.OwnerReferences, 1)
+	assert.Equal(t, "Node", remediation.OwnerReferences[0].Kind)
+	assert.Equal(t, types.UID("node1-uid"), remediation.OwnerReferences[0].UID)
+	assert.Equal(t, nodepkg.NodeUnhealthy, remediation.Status.Phase)
+	require.Len(t, remediation.Status.Transitions, 1)
+	assert.Equal(t, "lease is stale", remediation.Status.Transitions[0].Reason)
+	require.NotNil(t, remediation.Status.StaleLease)
+	assert.True(t, remediation.Status.StaleLease.Found)
+	assert.Equal(t, "kube-node-lease", remediation.Status.StaleLease.Namespace)
+	assert.Equal(t, holder, remediation.Status.StaleLease.HolderIdentity)
+	assert.Equal(t, duration, remediation.Status.StaleLease.LeaseDurationSeconds)
+	assert.Nil(t, remediation.Status.Drain)
+}
+
+func TestRecordUnhealthyStaleLeaseSource(t *testing.T) {
+	renewTime := metav1.NewMicroTime(time.Now().Add(-10 * time.Minute))
+	duration := int32(40)
+	kubeletLease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: testNode, Namespace: "kube-node-lease"},
+		Spec:       coordinationv1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: time.Now()}, LeaseDurationSeconds: &duration},
+	}
+	appLease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: testNode + "-app", Namespace: "apps"},
+		Spec:       coordinationv1.LeaseSpec{RenewTime: &renewTime, LeaseDurationSeconds: &duration},
+	}
+	cfg := createTestConfig(createTestNode(nil), kubeletLease, appLease)
+
+	// the second lease source failed, so its lease is the evidence
+	RecordUnhealthy(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "lease check failed", &healthcheck.LeaseRef{Namespace: "apps", Name: testNode + "-app"})
+
+	remediation := getRemediation(t, cfg)
+	require.NotNil(t, remediation.Status.StaleLease)
+	assert.True(t, remediation.Status.StaleLease.Found)
+	assert.Equal(t, "apps", remediation.Status.StaleLease.Namespace)
+	assert.Equal(t, testNode+"-app", remediation.Status.StaleLease.Name)
+	assert.WithinDuration(t, renewTime.Time, remediation.Status.StaleLease.RenewTime.Time, time.Second)
+}
+
+func TestRecordUnhealthyWithoutStaleLease(t *testing.T) {
+	cfg := createTestConfig(createTestNode(nil))
+
+	RecordUnhealthy(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "condition check failed", nil)
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, nodepkg.NodeUnhealthy, remediation.Status.Phase)
+	assert.Nil(t, remediation.Status.StaleLease)
+}
+
+func TestRecordTransitionDrainResult(t *testing.T) {
+	node := nodepkg.CreateNode(createTestNode(map[string]string{
+		nodepkg.DrainStatusAnnotation:    nodepkg.DrainStatusFailed,
+		nodepkg.DrainAttemptsAnnotation:  "3",
+		nodepkg.DrainLastErrorAnnotation: "pdb",
+	}))
+	cfg := createTestConfig()
+
+	RecordTransition(context.TODO(), cfg, node, nodepkg.NodeDraining, "Drain started")
+	RecordTransition(context.TODO(), cfg, node, nodepkg.NodePreparingTermination, "drain failed")
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, nodepkg.NodePreparingTermination, remediation.Status.Phase)
+	assert.Len(t, remediation.Status.Transitions, 2)
+	require.NotNil(t, remediation.Status.Drain)
+	assert.Equal(t, nodepkg.DrainStatusFailed, remediation.Status.Drain.Status)
+	assert.Equal(t, 3, remediation.Status.Drain.Attempts)
+	assert.Equal(t, "pdb", remediation.Status.Drain.LastError)
+}
+
+func TestRecordTransitionHealthyNotCreated(t *testing.T) {
+	cfg := createTestConfig(createTestNode(nil))
+
+	RecordTransition(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), nodepkg.NodeHealthy, "lease is fresh")
+
+	list, err := cfg.DynamicClient.Resource(v1alpha1.NodeRemediationGVR).Namespace(testNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestRecordTransitionHealthy(t *testing.T) {
+	cfg := createTestConfig()
+	node := nodepkg.CreateNode(createTestNode(nil))
+
+	RecordTransition(context.TODO(), cfg, node, nodepkg.NodeUnhealthy, "lease is stale")
+	RecordTransition(context.TODO(), cfg, node, nodepkg.NodeHealthy, "lease is fresh")
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, v1alpha1.PhaseHealthy, remediation.Status.Phase)
+	require.Len(t, remediation.Status.Transitions, 2)
+	assert.Equal(t, v1alpha1.PhaseHealthy, remediation.Status.Transitions[1].Phase)
+}
+
+func TestRecordTransitionNewIncident(t *testing.T) {
+	cfg := createTestConfig()
+	drained := nodepkg.CreateNode(createTestNode(map[string]string{
+		nodepkg.DrainStatusAnnotation:   nodepkg.DrainStatusFailed,
+		nodepkg.DrainAttemptsAnnotation: "3",
+	}))
+
+	RecordTransition(context.TODO(), cfg, drained, nodepkg.NodeDraining, "Drain started")
+	RecordCloudProviderResponse(context.TODO(), cfg, drained, "Terminate", "", fmt.Errorf("throttled"))
+	RecordTransition(context.TODO(), cfg, drained, nodepkg.NodeHealthy, "health checks passed")
+	RecordUnhealthy(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "lease is stale", nil)
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, nodepkg.NodeUnhealthy, remediation.Status.Phase)
+	assert.Len(t, remediation.Status.Transitions, 3)
+	assert.Len(t, remediation.Status.CloudProviderResponses, 1)
+	assert.Nil(t, remediation.Status.Drain)
+	assert.Equal(t, 0, remediation.Status.Retries)
+}
+
+func TestRecordTransitionDisabled(t *testing.T) {
+	cfg := createTestConfig(createTestNode(nil))
+	cfg.DryRun = true
+
+	RecordTransition(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), nodepkg.NodeUnhealthy, "lease is stale")
+
+	list, err := cfg.DynamicClient.Resource(v1alpha1.NodeRemediationGVR).Namespace(testNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
+
+func TestRecordCloudProviderResponse(t *testing.T) {
+	cfg := createTestConfig(createTestNode(nil))
+
+	RecordCloudProviderResponse(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "Terminate", "", fmt.Errorf("throttled"))
+	RecordCloudProviderResponse(context.TODO(), cfg, nodepkg.CreateNode(createTestNode(nil)), "Terminate", "Instance Terminated", nil)
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, 1, remediation.Status.Retries)
+	require.Len(t, remediation.Status.CloudProviderResponses, 2)
+	assert.Equal(t, "throttled", remediation.Status.CloudProviderResponses[0].Error)
+	assert.Equal(t, "Instance Terminated", remediation.Status.CloudProviderResponses[1].Response)
+}
+
+func TestAppendLimited(t *testing.T) {
+	list := make([]int, 0)
+	for i := 0; i < maxHistory+5; i++ {
+		list = appendLimited(list, i)
+	}
+	assert.Len(t, list, maxHistory)
+	assert.Equal(t, 5, list[0])
+}
+
+func TestRecordRemoval(t *testing.T) {
+	node := createTestNode(map[string]string{nodepkg.DrainStatusAnnotation: nodepkg.DrainStatusSucceeded})
+	cfg := createTestConfig(node)
+	RecordTransition(context.TODO(), cfg, nodepkg.CreateNode(node), nodepkg.NodeTerminating, "terminating")
+
+	// node is already removed
+	RecordRemoval(context.TODO(), createTestConfigWithDynamicClient(cfg), node, "node removed")
+
+	remediation := getRemediation(t, cfg)
+	assert.Equal(t, v1alpha1.PhaseCompleted, remediation.Status.Phase)
+	require.Len(t, remediation.Status.Transitions, 2)
+	assert.Equal(t, "node removed", remediation.Status.Transitions[1].Reason)
+	require.NotNil(t, remediation.Status.Drain)
+	assert.Equal(t, nodepkg.DrainStatusSucceeded, remediation.Status.Drain.Status)
+}
+
+func TestRecordRemovalWithoutRemediation(t *testing.T) {
+	cfg := createTestConfig()
+
+	RecordRemoval(context.TODO(), cfg, createTestNode(nil), "node removed")
+
+	_, err := cfg.DynamicClient.Resource(v1alpha1.NodeRemediationGVR).Namespace(testNamespace).Get(context.TODO(), testNode, metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+// createTestConfigWithDynamicClient creates config without nodes sharing NodeRemediation resources with cfg
+func createTestConfigWithDynamicClient(cfg *config.Config) *config.Config {
+	ret := createTestConfig()
+	ret.DynamicClient = cfg.DynamicClient
+	return ret
+}
@@ -0,0 +1,92 @@
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Collectors used by the node-undertaker logic. They are kept in a separate package so they can be used by packages
+// that metrics package depends on (i.e. node package).
+
+const (
+	MetricsNamespace   = "node_undertaker"
+	DryRunSubsystem    = "dry_run"
+	MetricLabelNode    = "node"
+	MetricLabelAction  = "action"
+	MetricLabelOutcome = "outcome"
+	MetricLabelSignal  = "signal"
+	MetricLabelReason  = "reason"
+)
+
+var (
+	DryRunActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: DryRunSubsystem,
+			Name:      "actions_total",
+			Help:      "Number of actions that would be executed if node-undertaker wasn't running in dry-run mode",
+		},
+		[]string{MetricLabelAction},
+	)
+	DryRunCircuitOpen = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: DryRunSubsystem,
+			Name:      "circuit_open",
+			Help:      "Set to 1 when circuit breaker would be open if node-undertaker wasn't running in dry-run mode",
+		},
+	)
+	DryRunSkippedTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: DryRunSubsystem,
+			Name:      "skipped_transitions_total",
+			Help:      "Number of simulated node state transitions skipped because node opted out or maintenance window was active",
+		},
+		[]string{MetricLabelReason},
+	)
+	CircuitOpen = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "circuit_open",
+			Help:      "Set to 1 when circuit breaker is open and all node remediation is frozen",
+		},
+	)
+	NodeSaveConflicts = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "node_save_conflicts_total",
+			Help:      "Number of conflicts received from API server while saving nodes",
+		},
+	)
+	DrainOutcomes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "drain_outcomes_total",
+			Help:      "Number of finished node drains by outcome",
+		},
+		[]string{MetricLabelOutcome},
+	)
+	HealthSignals = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "health_signal",
+			Help:      "Result of each configured health check of the node: 1 - healthy, 0 - failing",
+		},
+		[]string{MetricLabelNode, MetricLabelSignal},
+	)
+	SkippedTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "skipped_transitions_total",
+			Help:      "Number of node state transitions skipped because node opted out or maintenance window was active",
+		},
+		[]string{MetricLabelReason},
+	)
+)
+
+// ForgetNode removes series of the node from per-node metrics
+func ForgetNode(nodeName string) {
+	nodeLabels := prometheus.Labels{MetricLabelNode: nodeName}
+	HealthSignals.DeletePartialMatch(nodeLabels)
+}
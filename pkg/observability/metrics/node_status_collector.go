@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"github.com/dbschenker/node-undertaker/pkg/nodeundertaker/node"
+	"github.com/dbschenker/node-undertaker/pkg/observability/metrics/collectors"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/labels"
@@ -10,7 +11,7 @@ import (
 
 const (
 	NodeHealthyLabelOverride = "healthy"
-	MetricsNamespace         = "node_undertaker"
+	MetricsNamespace         = collectors.MetricsNamespace
 	NodeMetricsSubsystem     = "node"
 	HealthMetricName         = "health"
 	MetricLabelNode          = "node"